@@ -2,15 +2,32 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/dkischenko/xm_app/internal/app"
 	"github.com/dkischenko/xm_app/internal/company"
 	"github.com/dkischenko/xm_app/internal/company/database"
 	"github.com/dkischenko/xm_app/internal/config"
+	"github.com/dkischenko/xm_app/internal/metrics"
+	"github.com/dkischenko/xm_app/internal/migrations"
+	"github.com/dkischenko/xm_app/internal/openapi"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"github.com/dkischenko/xm_app/internal/tracing"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/clientip"
 	"github.com/dkischenko/xm_app/pkg/database/postgres"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	"github.com/dkischenko/xm_app/pkg/health"
 	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/dkischenko/xm_app/pkg/requestid"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -30,22 +47,241 @@ func main() {
 		cfg = config.GetConfig(configPath, &config.Config{})
 	})
 
+	l.Entry.Infof("Set up tracing with %s exporter", cfg.Tracing.Exporter)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			l.Entry.Errorf("failed to flush spans: %s", err)
+		}
+	}()
+	var queryLogger pgx.Logger
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		queryLogger = postgres.NewTracer()
+	}
+
 	l.Entry.Info("Create database connection")
 	client, err := postgres.NewClient(context.Background(), cfg.Storage.Host, cfg.Storage.Port,
-		cfg.Storage.Username, cfg.Storage.Password, cfg.Storage.Database)
+		cfg.Storage.Username, cfg.Storage.Password, cfg.Storage.Database, queryLogger)
+
+	if err != nil {
+		panic(err)
+	}
+	metrics.RegisterPool(client)
 
+	migrator, err := migrations.NewMigrator(client, l)
 	if err != nil {
 		panic(err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), migrator, os.Args[2:]); err != nil {
+			l.Entry.Fatalf("migrate: %s", err)
+		}
+		return
+	}
+
+	if cfg.Storage.AutoMigrate {
+		l.Entry.Info("Apply database migrations")
+		if err := migrator.Up(context.Background()); err != nil {
+			panic(err)
+		}
+	}
+
 	storage := database.NewStorage(client, l)
 	accessTokenTTL, err := time.ParseDuration(cfg.Auth.AccessTokenTTL)
 	if err != nil {
 		panic(err)
 	}
 
-	service := company.NewService(l, storage, accessTokenTTL)
-	handler := company.NewHandler(l, service, cfg)
+	refreshTokenTTL, err := time.ParseDuration(cfg.Auth.RefreshTokenTTL)
+	if err != nil {
+		panic(err)
+	}
+
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		panic(err)
+	}
+	if keys != nil {
+		go reloadKeysOnHangup(keys, cfg, l)
+	}
+
+	service := company.NewService(l, storage, auth.Options{
+		TokenTTL:    accessTokenTTL,
+		GeoTokenTTL: cfg.Auth.GeoTokenTTL,
+		Issuer:      cfg.Auth.Issuer,
+		Audience:    cfg.Auth.Audience,
+		Leeway:      cfg.Auth.Leeway,
+		Keys:        keys,
+	}, refreshTokenTTL)
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		service = company.Traced(service)
+	}
+	l.Entry.Infof("Create %s geo locator", cfg.Geo.Provider)
+	geoMetrics := &geo.Metrics{}
+	metrics.RegisterGeo(geoMetrics)
+	locator, err := geo.New(geo.Options{
+		Provider:         cfg.Geo.Provider,
+		Path:             cfg.Geo.Path,
+		Timeout:          cfg.Geo.Timeout,
+		Retries:          cfg.Geo.Retries,
+		CacheSize:        cfg.Geo.CacheSize,
+		CacheTTL:         cfg.Geo.CacheTTL,
+		BreakerThreshold: cfg.Geo.BreakerThreshold,
+		BreakerCooldown:  cfg.Geo.BreakerCooldown,
+		Metrics:          geoMetrics,
+		Observe:          metrics.ObserveGeoLookup,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if closer, ok := locator.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	l.Entry.Info("Create access policy")
+	engine, err := policy.New(cfg.Policy, locator, cfg.Geo.FailPolicy == config.GeoFailOpen, l)
+	if err != nil {
+		panic(err)
+	}
+
+	resolver, err := clientip.NewResolver(cfg.Listen.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	router.Use(otelmux.Middleware(tracing.ServiceName), metrics.Middleware, requestid.Middleware, resolver.Middleware,
+		l.Middleware)
+	if cfg.OpenAPI.ValidateRequests || cfg.OpenAPI.ValidateResponses {
+		validator, err := openapi.NewValidator(l, cfg.OpenAPI.ValidateResponses)
+		if err != nil {
+			panic(err)
+		}
+		router.Use(validator.Middleware)
+	}
+
+	checker := health.NewChecker(
+		health.Check{Name: "postgres", Critical: true, Probe: client.Ping},
+		health.Check{Name: "migrations", Critical: true, Probe: migrator.Check},
+		// An unavailable geo service only affects callers let in by the access policy.
+		health.Check{Name: "geo", Probe: func(ctx context.Context) error {
+			if state := geoMetrics.Breaker(); state == geo.BreakerOpen {
+				return fmt.Errorf("circuit breaker is %s", state)
+			}
+			return nil
+		}},
+	)
+
+	handler := company.NewHandler(l, service, cfg, engine)
 	handler.Register(router)
-	app.Run(router, l, cfg)
+	openapi.Register(router)
+	checker.Register(router)
+	opsRouter := mux.NewRouter()
+	metrics.Register(opsRouter)
+	checker.RegisterVerbose(opsRouter)
+	app.Run(router, opsRouter, l, cfg, checker)
+}
+
+// readKeys reads the token keys of the configuration.
+func readKeys(cfg *config.Config) ([]auth.Key, error) {
+	var keys []auth.Key
+	for _, k := range cfg.Auth.Keys {
+		key, err := auth.LoadKeyFile(k.Id, k.Path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if cfg.Auth.KeyDir != "" {
+		dirKeys, err := auth.LoadKeyDir(cfg.Auth.KeyDir)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, dirKeys...)
+	}
+	return keys, nil
+}
+
+// loadKeys returns the token key set, nil when no keys are configured and tokens are signed with SIGNINKEY.
+func loadKeys(cfg *config.Config) (*auth.KeySet, error) {
+	keys, err := readKeys(cfg)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return auth.NewKeySet(cfg.Auth.SigningKey, keys...)
+}
+
+// reloadKeysOnHangup rereads the token keys on SIGHUP, so that keys can be rotated without a restart.
+// The config file is read again for the id of the signing key.
+func reloadKeysOnHangup(keys *auth.KeySet, cfg *config.Config, l *logger.Logger) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		var err error
+		if path := os.Getenv("CONFIG"); path != "" {
+			var reread *config.Config
+			if reread, err = config.ReadConfig(path); err == nil {
+				cfg = reread
+			}
+		}
+		var loaded []auth.Key
+		if err == nil {
+			loaded, err = readKeys(cfg)
+		}
+		if err == nil {
+			err = keys.Replace(cfg.Auth.SigningKey, loaded...)
+		}
+		if err != nil {
+			l.Entry.Errorf("can't reload token keys, the current ones are kept: %s", err)
+			continue
+		}
+		l.Entry.Infof("token keys reloaded, signing with %q", keys.Active().Id)
+	}
+}
+
+// runMigrate handles "app migrate up|down|status|to N".
+func runMigrate(ctx context.Context, migrator *migrations.Migrator, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down|status|to N")
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate to N")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.To(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != 0 {
+				applied = time.Unix(st.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", st.Version, st.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"github.com/dkischenko/xm_app/internal/config"
+	"github.com/dkischenko/xm_app/pkg/health"
 	"github.com/dkischenko/xm_app/pkg/logger"
 	"github.com/gorilla/mux"
 	"log"
@@ -12,14 +13,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 )
 
-func Run(router *mux.Router, logger *logger.Logger, config *config.Config) {
+// Run serves the router, and the operations router on the metrics listener, until the process
+// is interrupted and returns once in-flight requests are done.
+func Run(router *mux.Router, ops *mux.Router, logger *logger.Logger, config *config.Config, checker *health.Checker) {
 	logger.Entry.Info("start application")
 	logger.Entry.Info("listen TCP")
 	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%s", config.Listen.Ip, config.Listen.Port))
 
+	if err != nil {
+		panic(err)
+	}
+	opsListener, err := net.Listen("tcp", config.Metrics.Listen)
 	if err != nil {
 		panic(err)
 	}
@@ -29,24 +37,42 @@ func Run(router *mux.Router, logger *logger.Logger, config *config.Config) {
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
+	opsServer := &http.Server{
+		Handler:      ops,
+		WriteTimeout: 15 * time.Second,
+		ReadTimeout:  5 * time.Second,
+	}
 	logger.Entry.Infof("server listening address %s:%s", config.Listen.Ip, config.Listen.Port)
+	logger.Entry.Infof("metrics listening address %s", opsListener.Addr())
 
 	var wait time.Duration
 	flag.DurationVar(&wait, "graceful-timeout", time.Second*15,
 		"the duration for which the server gracefully wait for existing connections to finish - e.g. 15s or 1m")
+	var drain time.Duration
+	flag.DurationVar(&drain, "drain-delay", time.Second*5,
+		"the duration for which the server keeps serving while reporting not ready before it shuts down - e.g. 5s or 0s")
 	flag.Parse()
 	go func() {
 		if err := server.Serve(listener); err != nil {
 			log.Println(err)
 		}
 	}()
+	go func() {
+		if err := opsServer.Serve(opsListener); err != nil && err != http.ErrServerClosed {
+			logger.Entry.Errorf("metrics listener: %s", err)
+		}
+	}()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
+	logger.Entry.Infof("draining for %s", drain)
+	checker.Drain()
+	time.Sleep(drain)
 	ctx, cancel := context.WithTimeout(context.Background(), wait)
 	defer cancel()
 	server.Shutdown(ctx)
+	// The metrics listener stays up while the API drains, so the shutdown can be watched.
+	opsServer.Shutdown(ctx)
 	log.Println("shutting down")
-	os.Exit(0)
 }
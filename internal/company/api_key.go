@@ -0,0 +1,155 @@
+package company
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyScheme is the Authorization scheme of API keys.
+	apiKeyScheme = "ApiKey"
+	// apiKeyMark starts every API key, so that leaked keys are easy to spot.
+	apiKeyMark = "xm_"
+)
+
+func (s Service) CreateApiKey(ctx context.Context, req models.ApiKeyCreateRequest) (key models.ApiKey, secret string,
+	err error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !models.IsApiKeyScope(scope) {
+			return key, "", fmt.Errorf("error occurs: %w", uerrors.ErrUnknownScope.WithDetail("%q", scope))
+		}
+		if !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	now := time.Now().Unix()
+	if req.ExpiresAt != nil && *req.ExpiresAt <= now {
+		return key, "", fmt.Errorf("error occurs: %w", uerrors.ErrApiKeyExpiry)
+	}
+
+	prefix, secret, err := generateApiKey()
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to generate api key: %s", err)
+		return key, "", fmt.Errorf("error occurs: %w", uerrors.ErrApiKey)
+	}
+	key = models.ApiKey{
+		Name:      req.Name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		CreatedBy: models.ActorFromContext(ctx).Id,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+		KeyHash:   hashToken(secret),
+	}
+
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		if key.Id, err = s.storage.CreateApiKey(ctx, &key); err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityApiKey, key.Id, models.AuditActionCreate, nil, key)
+	})
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to create api key: %s", err)
+		return models.ApiKey{}, "", storageError(err, uerrors.ErrApiKey)
+	}
+	return key, secret, nil
+}
+
+func (s Service) GetApiKeys(ctx context.Context) (keys []models.ApiKey, err error) {
+	keys, err = s.storage.GetApiKeys(ctx)
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to get api keys: %s", err)
+		return nil, storageError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (s Service) RevokeApiKey(ctx context.Context, id string) (key models.ApiKey, err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		before, err := s.storage.GetApiKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		if before.RevokedAt != nil {
+			key = before
+			return nil
+		}
+		if err = s.storage.RevokeApiKey(ctx, id); err != nil {
+			return err
+		}
+		if key, err = s.storage.GetApiKey(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityApiKey, id, models.AuditActionRevoke, before, key)
+	})
+	if errors.Is(err, uerrors.ErrApiKeyNotFound) {
+		return key, fmt.Errorf("error occurs: %w", uerrors.ErrApiKeyNotFound)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to revoke api key: %s", err)
+		return key, storageError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (s Service) CheckApiKey(ctx context.Context, header string, ip string) (key models.ApiKey, err error) {
+	scheme, secret, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, apiKeyScheme) {
+		return key, fmt.Errorf("error occurs: %w", uerrors.ErrAuthScheme)
+	}
+	secret = strings.TrimSpace(secret)
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(secret, apiKeyMark), "_")
+	if !ok || !strings.HasPrefix(secret, apiKeyMark) {
+		return key, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidApiKey)
+	}
+
+	key, err = s.storage.FindApiKey(ctx, prefix)
+	if errors.Is(err, uerrors.ErrInvalidApiKey) {
+		return key, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidApiKey)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to find api key: %s", err)
+		return key, storageError(err, uerrors.ErrApiKey)
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashToken(secret))) != 1 ||
+		key.RevokedAt != nil || key.ExpiresAt != nil && *key.ExpiresAt <= time.Now().Unix() {
+		return models.ApiKey{}, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidApiKey)
+	}
+
+	// The key is good whether its use could be recorded or not.
+	if err := s.storage.TouchApiKey(ctx, key.Id, ip); err != nil {
+		s.logger.Ctx(ctx).Warnf("failed to record use of api key %s: %s", key.Id, err)
+	}
+	return key, nil
+}
+
+// generateApiKey returns a new API key and its prefix, the public part it is looked up by.
+func generateApiKey() (prefix string, key string, err error) {
+	b := make([]byte, 40)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(b[:8])
+	return prefix, apiKeyMark + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[8:]), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
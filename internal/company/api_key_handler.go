@@ -0,0 +1,71 @@
+package company
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func (h handler) CreateApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	req := &models.ApiKeyCreateRequest{}
+	if !h.decodeValid(w, r, req, "api key") {
+		return
+	}
+
+	key, secret, err := h.service.CreateApiKey(r.Context(), *req)
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't create api key: %w", err))
+		return
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.Header().Add("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(models.ApiKeyCreateResponse{ApiKey: key, Key: secret}); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't create api key: %+v", err)
+		return
+	}
+}
+
+func (h handler) GetApiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.GetApiKeys(r.Context())
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't get api keys: %w", err))
+		return
+	}
+	if keys == nil {
+		keys = []models.ApiKey{}
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(models.ApiKeysResponse{ApiKeys: keys}); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't get api keys: %+v", err)
+		return
+	}
+}
+
+func (h handler) RevokeApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := validator.New().Var(id, "uuid"); err != nil {
+		h.writeError(w, r, uerrors.ErrApiKeyNotFound)
+		return
+	}
+
+	key, err := h.service.RevokeApiKey(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't revoke api key: %w", err))
+		return
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(key); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't revoke api key: %+v", err)
+		return
+	}
+}
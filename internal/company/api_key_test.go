@@ -0,0 +1,333 @@
+package company_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const apiKeyId = "5d7e1f0a-3c2b-4a9d-8e6f-1a2b3c4d5e6f"
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestService_CreateApiKey(t *testing.T) {
+	t.Run("[Ok] Create key and authenticate with it", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := models.WithActor(context.Background(), models.Actor{Type: models.ActorTypeUser, Id: roleUserId})
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		expectTransaction(mockRepo)
+		var stored models.ApiKey
+		mockRepo.EXPECT().CreateApiKey(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, key *models.ApiKey) (string, error) {
+				stored = *key
+				stored.Id = apiKeyId
+				return apiKeyId, nil
+			})
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		key, secret, err := s.CreateApiKey(ctx, models.ApiKeyCreateRequest{Name: "billing",
+			Scopes: []string{models.PermissionCompanyWrite, models.PermissionCompanyRead, models.PermissionCompanyRead}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, apiKeyId, key.Id)
+		assert.Equal(t, roleUserId, key.CreatedBy)
+		assert.Equal(t, []string{models.PermissionCompanyRead, models.PermissionCompanyWrite}, key.Scopes)
+		assert.True(t, strings.HasPrefix(secret, "xm_"+key.Prefix+"_"))
+		assert.Equal(t, sha256Hex(secret), stored.KeyHash)
+
+		mockRepo.EXPECT().FindApiKey(gomock.Any(), key.Prefix).Return(stored, nil)
+		mockRepo.EXPECT().TouchApiKey(gomock.Any(), apiKeyId, "31.153.0.1").Return(nil)
+		checked, err := s.CheckApiKey(ctx, "ApiKey "+secret, "31.153.0.1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, apiKeyId, checked.Id)
+	})
+
+	t.Run("[Err] Unknown scope", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mock_company.NewMockRepository(ctrl), auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, _, err := s.CreateApiKey(context.Background(), models.ApiKeyCreateRequest{Name: "billing",
+			Scopes: []string{models.PermissionApiKeyManage}})
+		assert.True(t, errors.Is(err, uerrors.ErrUnknownScope))
+	})
+
+	t.Run("[Err] Expired", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mock_company.NewMockRepository(ctrl), auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		expiresAt := time.Now().Add(-time.Hour).Unix()
+		_, _, err := s.CreateApiKey(context.Background(), models.ApiKeyCreateRequest{Name: "billing",
+			Scopes: []string{models.PermissionCompanyRead}, ExpiresAt: &expiresAt})
+		assert.True(t, errors.Is(err, uerrors.ErrApiKeyExpiry))
+	})
+}
+
+func TestService_CheckApiKey(t *testing.T) {
+	const secret = "xm_0123456789abcdef_c2VjcmV0"
+	past := time.Now().Add(-time.Minute).Unix()
+	future := time.Now().Add(time.Hour).Unix()
+	valid := models.ApiKey{Id: apiKeyId, Prefix: "0123456789abcdef", KeyHash: sha256Hex(secret),
+		Scopes: []string{models.PermissionCompanyRead}}
+
+	testCases := []struct {
+		name    string
+		header  string
+		key     func(key models.ApiKey) models.ApiKey
+		findErr error
+		wantErr error
+	}{
+		{name: "[Ok] Valid key", header: "ApiKey " + secret},
+		{name: "[Ok] Not expired yet", header: "apikey " + secret,
+			key: func(key models.ApiKey) models.ApiKey { key.ExpiresAt = &future; return key }},
+		{name: "[Err] Other scheme", header: "Bearer " + secret, wantErr: uerrors.ErrAuthScheme},
+		{name: "[Err] Malformed key", header: "ApiKey secret", wantErr: uerrors.ErrInvalidApiKey},
+		{name: "[Err] Unknown prefix", header: "ApiKey " + secret,
+			findErr: fmt.Errorf("Error occurs: %w", uerrors.ErrInvalidApiKey), wantErr: uerrors.ErrInvalidApiKey},
+		{name: "[Err] Wrong secret", header: "ApiKey xm_0123456789abcdef_b3RoZXI", wantErr: uerrors.ErrInvalidApiKey},
+		{name: "[Err] Revoked", header: "ApiKey " + secret, wantErr: uerrors.ErrInvalidApiKey,
+			key: func(key models.ApiKey) models.ApiKey { key.RevokedAt = &past; return key }},
+		{name: "[Err] Expired", header: "ApiKey " + secret, wantErr: uerrors.ErrInvalidApiKey,
+			key: func(key models.ApiKey) models.ApiKey { key.ExpiresAt = &past; return key }},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			key := valid
+			if tcase.key != nil {
+				key = tcase.key(key)
+			}
+			mockRepo := mock_company.NewMockRepository(ctrl)
+			mockRepo.EXPECT().FindApiKey(gomock.Any(), "0123456789abcdef").Return(key, tcase.findErr).MaxTimes(1)
+			if tcase.wantErr == nil {
+				mockRepo.EXPECT().TouchApiKey(gomock.Any(), apiKeyId, "31.153.0.1").Return(nil)
+			}
+
+			l, _ := logger.GetLogger()
+			s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+			checked, err := s.CheckApiKey(context.Background(), tcase.header, "31.153.0.1")
+			if tcase.wantErr != nil {
+				assert.True(t, errors.Is(err, tcase.wantErr), "%v", err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			assert.Equal(t, apiKeyId, checked.Id)
+		})
+	}
+}
+
+func TestService_RevokeApiKey(t *testing.T) {
+	t.Run("[Ok] Revoke writes audit event", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		revokedAt := time.Now().Unix()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().WithinTransaction(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		gomock.InOrder(
+			mockRepo.EXPECT().GetApiKey(ctx, apiKeyId).Return(models.ApiKey{Id: apiKeyId, Name: "billing"}, nil),
+			mockRepo.EXPECT().RevokeApiKey(ctx, apiKeyId).Return(nil),
+			mockRepo.EXPECT().GetApiKey(ctx, apiKeyId).
+				Return(models.ApiKey{Id: apiKeyId, Name: "billing", RevokedAt: &revokedAt}, nil),
+		)
+		mockRepo.EXPECT().CreateAuditEvent(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, e *models.AuditEvent) error {
+				assert.Equal(t, models.AuditEntityApiKey, e.Entity)
+				assert.Equal(t, apiKeyId, e.EntityId)
+				assert.Equal(t, models.AuditActionRevoke, e.Action)
+				assert.Contains(t, string(e.After), fmt.Sprintf(`"revoked_at":%d`, revokedAt))
+				assert.NotContains(t, string(e.After), "hash")
+				return nil
+			})
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		key, err := s.RevokeApiKey(ctx, apiKeyId)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, &revokedAt, key.RevokedAt)
+	})
+
+	t.Run("[Ok] Revoke revoked key changes nothing", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		revokedAt := time.Now().Unix()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().WithinTransaction(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		mockRepo.EXPECT().GetApiKey(gomock.Any(), apiKeyId).Return(models.ApiKey{Id: apiKeyId, RevokedAt: &revokedAt}, nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.RevokeApiKey(context.Background(), apiKeyId)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("[Err] Unknown key", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		expectTransaction(mockRepo)
+		mockRepo.EXPECT().GetApiKey(gomock.Any(), apiKeyId).
+			Return(models.ApiKey{}, fmt.Errorf("Error occurs: %w", uerrors.ErrApiKeyNotFound))
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.RevokeApiKey(context.Background(), apiKeyId)
+		assert.True(t, errors.Is(err, uerrors.ErrApiKeyNotFound))
+	})
+}
+
+func TestHandler_CreateApiKey(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		roles      []string
+		serviceErr error
+		status     int
+	}{
+		{name: "[Ok] Create key", body: `{"name": "billing", "scopes": ["company:read"]}`,
+			roles: []string{models.RoleAdmin}, status: http.StatusCreated},
+		{name: "[Err] Not an admin", body: `{"name": "billing", "scopes": ["company:read"]}`,
+			roles: []string{models.RoleEditor}, status: http.StatusForbidden},
+		{name: "[Err] Without scopes", body: `{"name": "billing", "scopes": []}`,
+			roles: []string{models.RoleAdmin}, status: http.StatusBadRequest},
+		{name: "[Err] Unknown scope", body: `{"name": "billing", "scopes": ["role:manage"]}`,
+			roles:      []string{models.RoleAdmin},
+			serviceErr: fmt.Errorf("error occurs: %w", uerrors.ErrUnknownScope), status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/api-keys", strings.NewReader(tcase.body))
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").
+				Return(auth.Claims{Principal: auth.User(roleUserId), Roles: tcase.roles}, nil)
+			mockService.EXPECT().CreateApiKey(gomock.Any(), gomock.Any()).
+				Return(models.ApiKey{Id: apiKeyId, Name: "billing", Prefix: "0123456789abcdef"}, "xm_0123456789abcdef_secret",
+					tcase.serviceErr).MaxTimes(1)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			if tcase.status != http.StatusCreated {
+				return
+			}
+
+			var resp models.ApiKeyCreateResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			assert.Equal(t, apiKeyId, resp.Id)
+			assert.Equal(t, "xm_0123456789abcdef_secret", resp.Key)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestHandler_AuthenticateApiKey(t *testing.T) {
+	testCases := []struct {
+		name      string
+		method    string
+		target    string
+		scopes    []string
+		checkErr  error
+		status    int
+		challenge string
+	}{
+		{name: "[Ok] Key with the permission", method: http.MethodGet, target: "/v1/audit/verify",
+			scopes: []string{models.PermissionAuditRead}, status: http.StatusOK},
+		{name: "[Err] Key without the permission", method: http.MethodGet, target: "/v1/audit/verify",
+			scopes: []string{models.PermissionCompanyRead}, status: http.StatusForbidden},
+		{name: "[Err] Invalid key", method: http.MethodGet, target: "/v1/audit/verify",
+			checkErr: fmt.Errorf("error occurs: %w", uerrors.ErrInvalidApiKey), status: http.StatusUnauthorized,
+			challenge: `ApiKey realm="xm_app"`},
+		{name: "[Err] Key managing keys", method: http.MethodGet, target: "/v1/api-keys",
+			scopes: models.ApiKeyScopes, status: http.StatusForbidden},
+		{name: "[Err] Key logging out", method: http.MethodPost, target: "/v1/users/logout",
+			scopes: models.ApiKeyScopes, status: http.StatusForbidden},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(tcase.method, tcase.target, nil)
+			req.RemoteAddr = "31.153.0.1:1234"
+			req.Header.Set("Authorization", "ApiKey xm_key")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckApiKey(gomock.Any(), "ApiKey xm_key", "31.153.0.1").
+				Return(models.ApiKey{Id: apiKeyId, Name: "billing", Scopes: tcase.scopes}, tcase.checkErr)
+			mockService.EXPECT().VerifyAuditLog(gomock.Any()).
+				DoAndReturn(func(ctx context.Context) (models.AuditVerification, error) {
+					actor := models.ActorFromContext(ctx)
+					assert.Equal(t, models.ActorTypeApiKey, actor.Type)
+					assert.Equal(t, apiKeyId, actor.Id)
+					return models.AuditVerification{}, nil
+				}).MaxTimes(1)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			assert.Equal(t, tcase.challenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
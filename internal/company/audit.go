@@ -0,0 +1,92 @@
+package company
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"time"
+)
+
+// auditVerifyBatch is the number of events read at once while verifying the chain.
+const auditVerifyBatch = 500
+
+// audit records the mutation of the entity by the actor from ctx. It must be
+// called within the transaction of the mutation itself.
+func (s Service) audit(ctx context.Context, entity, entityId, action string, before, after interface{}) (err error) {
+	actor := models.ActorFromContext(ctx)
+	event := &models.AuditEvent{
+		Entity:    entity,
+		EntityId:  entityId,
+		Action:    action,
+		ActorType: actor.Type,
+		ActorId:   actor.Id,
+		Ip:        actor.Ip,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	if before != nil {
+		if event.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if event.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+
+	return s.storage.CreateAuditEvent(ctx, event)
+}
+
+func (s Service) GetAuditEvents(ctx context.Context, filter models.AuditFilter) (page models.AuditPage, err error) {
+	events, err := s.storage.GetAuditEvents(ctx, filter)
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to get audit events: %s", err)
+		return page, storageError(err, uerrors.ErrGetAuditEvents)
+	}
+
+	page.Events = make([]models.AuditEvent, 0, len(events))
+	if len(events) > filter.Limit {
+		events = events[:filter.Limit]
+		page.NextCursor = models.EncodeAuditCursor(events[len(events)-1].Id)
+	}
+	page.Events = append(page.Events, events...)
+
+	return
+}
+
+// VerifyAuditLog walks the whole audit log and checks that every event is
+// chained to the previous one and its content matches its hash.
+func (s Service) VerifyAuditLog(ctx context.Context) (result models.AuditVerification, err error) {
+	var (
+		lastId   int64
+		prevHash string
+	)
+
+	for {
+		events, err := s.storage.GetAuditChain(ctx, lastId, auditVerifyBatch)
+		if err != nil {
+			s.logger.Ctx(ctx).Errorf("failed to get audit events: %s", err)
+			return result, storageError(err, uerrors.ErrGetAuditEvents)
+		}
+
+		for _, e := range events {
+			hash, err := e.ComputeHash()
+			if err != nil || e.PrevHash != prevHash || hash != e.Hash {
+				s.logger.Ctx(ctx).Errorf("audit chain is broken at event %d", e.Id)
+				result.BrokenAt = e.Id
+				return result, nil
+			}
+			result.Checked++
+			lastId, prevHash = e.Id, e.Hash
+		}
+
+		if len(events) < auditVerifyBatch {
+			break
+		}
+	}
+
+	result.Valid = true
+	return result, nil
+}
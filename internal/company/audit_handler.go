@@ -0,0 +1,60 @@
+package company
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func (h handler) GetAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	h.writeAuditEvents(w, r, filter, err)
+}
+
+func (h handler) GetCompanyHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	filter.Entity = models.AuditEntityCompany
+	filter.EntityId = mux.Vars(r)["id"]
+	h.writeAuditEvents(w, r, filter, err)
+}
+
+func (h handler) VerifyAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.VerifyAuditLog(r.Context())
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't verify audit log: %w", err))
+		return
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't verify audit log: %+v", err)
+		return
+	}
+}
+
+func (h handler) writeAuditEvents(w http.ResponseWriter, r *http.Request, filter models.AuditFilter, err error) {
+	if err == nil {
+		err = validator.New().Struct(filter)
+	}
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong audit params: %+v", err))
+		return
+	}
+
+	page, err := h.service.GetAuditEvents(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't get audit events: %w", err))
+		return
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't get audit events: %+v", err)
+		return
+	}
+}
@@ -0,0 +1,132 @@
+package company_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestService_DeleteCompanyAudit(t *testing.T) {
+	t.Run("Delete company writes audit event", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		actor := models.Actor{Type: models.ActorTypeGeo, Id: "Cyprus", Ip: "31.153.0.1"}
+		ctx := models.WithActor(context.Background(), actor)
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().WithinTransaction(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		mockRepo.EXPECT().GetCompany(ctx, 7).Return(models.Company{Id: 7, Name: "Acme", Version: 2}, nil)
+		mockRepo.EXPECT().Delete(ctx, 7, 2).Return(nil)
+		mockRepo.EXPECT().CreateAuditEvent(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, e *models.AuditEvent) error {
+				assert.Equal(t, models.AuditEntityCompany, e.Entity)
+				assert.Equal(t, "7", e.EntityId)
+				assert.Equal(t, models.AuditActionDelete, e.Action)
+				assert.Equal(t, models.ActorTypeGeo, e.ActorType)
+				assert.Equal(t, "Cyprus", e.ActorId)
+				assert.Equal(t, "31.153.0.1", e.Ip)
+				assert.Contains(t, string(e.Before), `"name":"Acme"`)
+				assert.Empty(t, e.After)
+				return nil
+			})
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		if err := s.DeleteCompany(ctx, 7, 2); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+}
+
+func TestService_VerifyAuditLog(t *testing.T) {
+	chain := func() []models.AuditEvent {
+		events := []models.AuditEvent{
+			{Id: 1, Entity: "company", EntityId: "1", Action: "create", ActorType: "user", ActorId: "u1",
+				After: json.RawMessage(`{"id": 1, "name": "Acme"}`), CreatedAt: 1650995663},
+			{Id: 2, Entity: "company", EntityId: "1", Action: "delete", ActorType: "geo", ActorId: "Cyprus",
+				Before: json.RawMessage(`{"id": 1, "name": "Acme"}`), CreatedAt: 1650995664},
+		}
+		prev := ""
+		for i := range events {
+			events[i].PrevHash = prev
+			events[i].Hash, _ = events[i].ComputeHash()
+			prev = events[i].Hash
+		}
+		return events
+	}
+
+	testCases := []struct {
+		name    string
+		tamper  func(events []models.AuditEvent)
+		valid   bool
+		broken  int64
+		checked int
+	}{
+		{name: "Intact chain", tamper: func([]models.AuditEvent) {}, valid: true, checked: 2},
+		{name: "Reformatted JSON keeps chain", tamper: func(events []models.AuditEvent) {
+			events[0].After = json.RawMessage(`{"name":"Acme","id":1}`)
+		}, valid: true, checked: 2},
+		{name: "Changed content", tamper: func(events []models.AuditEvent) {
+			events[1].ActorId = "Greece"
+		}, broken: 2, checked: 1},
+		{name: "Removed event", tamper: func(events []models.AuditEvent) {
+			events[1].PrevHash = ""
+		}, broken: 2, checked: 1},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			events := chain()
+			tcase.tamper(events)
+			mockRepo := mock_company.NewMockRepository(ctrl)
+			mockRepo.EXPECT().GetAuditChain(context.Background(), int64(0), gomock.Any()).Return(events, nil)
+
+			l, _ := logger.GetLogger()
+			s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+			res, err := s.VerifyAuditLog(context.Background())
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			assert.Equal(t, tcase.valid, res.Valid)
+			assert.Equal(t, tcase.broken, res.BrokenAt)
+			assert.Equal(t, tcase.checked, res.Checked)
+		})
+	}
+}
+
+func TestService_GetAuditEvents(t *testing.T) {
+	t.Run("Get audit events next cursor", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		filter := models.AuditFilter{Entity: models.AuditEntityCompany, EntityId: "1", Limit: 2}
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().GetAuditEvents(context.Background(), filter).
+			Return([]models.AuditEvent{{Id: 9}, {Id: 8}, {Id: 5}}, nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		page, err := s.GetAuditEvents(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, 2, len(page.Events))
+		cursor, err := models.DecodeAuditCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(8), cursor)
+	})
+}
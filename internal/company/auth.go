@@ -0,0 +1,259 @@
+package company
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	headerWWWAuthenticate = "WWW-Authenticate"
+	authRealm             = "xm_app"
+)
+
+// access declares which callers a route accepts.
+type access struct {
+	// bearer accepts users with an access token and clients with an API key in the Authorization header.
+	bearer bool
+	// operation accepts callers without a token whom the access policy allows to run it.
+	operation string
+	// permission is required from users and API keys, the access policy alone decides about callers
+	// without a token. API keys are accepted only by routes requiring a permission.
+	permission string
+}
+
+// userAccess accepts users and API keys having the permission.
+func userAccess(permission string) access {
+	return access{bearer: true, permission: permission}
+}
+
+// geoAccess accepts users having the permission and callers the access policy allows to run the operation.
+func geoAccess(permission string, operation string) access {
+	return access{bearer: true, permission: permission, operation: operation}
+}
+
+type (
+	issuedTokenKey struct{}
+	tokenClaimsKey struct{}
+)
+
+// tokenClaims returns the claims of the access token authenticate accepted.
+func tokenClaims(ctx context.Context) auth.Claims {
+	claims, _ := ctx.Value(tokenClaimsKey{}).(auth.Claims)
+	return claims
+}
+
+// issuedToken returns the token authenticate issued to an anonymous caller, if any.
+func issuedToken(ctx context.Context) string {
+	token, _ := ctx.Value(issuedTokenKey{}).(string)
+	return token
+}
+
+// authenticate lets through the requests the access accepts with the authenticated principal
+// stored as the actor of the request context. Other requests get 401 with a challenge when
+// a token could help, or 403 when the principal is known but not allowed.
+func (h handler) authenticate(a access, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := models.ActorFromContext(r.Context())
+
+		if header := r.Header.Get(headerAuthorization); header != "" && a.bearer {
+			if scheme, _, _ := strings.Cut(header, " "); strings.EqualFold(scheme, apiKeyScheme) {
+				h.authenticateApiKey(w, r, a, header, next)
+				return
+			}
+			claims, err := h.service.CheckAuth(r.Context(), header)
+			if errors.Is(err, uerrors.ErrUnavailable) {
+				h.writeError(w, r, err)
+				return
+			}
+			if err != nil {
+				h.logger.Ctx(r.Context()).Infof("can't authenticate %s: %v", actor.Ip, err)
+				h.challenge(w, r, invalidTokenError(err), err)
+				return
+			}
+			ctx := context.WithValue(r.Context(), tokenClaimsKey{}, claims)
+
+			if claims.Principal.Type == auth.PrincipalGeo {
+				log := anonymousLog(r.Context(), h.logger, claims, actor.Ip)
+				if !claims.Allows(a.operation) {
+					log.Infof("anonymous token used for %s out of its scope", r.URL.Path)
+					h.forbid(w, r, "the anonymous token doesn't allow the operation")
+					return
+				}
+				if !h.boundTo(r.Context(), claims, actor.Ip) {
+					log.Infof("anonymous token used from outside its binding for %s", a.operation)
+					h.challenge(w, r, "invalid_token",
+						uerrors.ErrParseToken.WithDetail("the anonymous token was issued to another caller"))
+					return
+				}
+				log.Infof("anonymous token used for %s", a.operation)
+				actor.Type, actor.Id = models.ActorTypeGeo, claims.Principal.Subject
+				next(w, r.WithContext(h.withActor(ctx, actor)))
+				return
+			}
+
+			actor.Type, actor.Id, actor.Roles = models.ActorTypeUser, claims.Principal.Subject, claims.Roles
+			if a.permission != "" && !actor.Can(a.permission) {
+				h.forbid(w, r, fmt.Sprintf("the operation requires %s permission", a.permission))
+				return
+			}
+			next(w, r.WithContext(h.withActor(ctx, actor)))
+			return
+		}
+
+		if a.operation == "" {
+			h.challenge(w, r, "", uerrors.ErrAuthRequired)
+			return
+		}
+
+		now := time.Now()
+		ip := net.ParseIP(actor.Ip)
+		decision := h.policy.Evaluate(r.Context(), policy.Request{
+			Operation: a.operation,
+			IP:        ip,
+			Time:      now,
+		})
+		if !decision.Allowed {
+			h.logger.Ctx(r.Context()).Infof("%s from %s is denied: %s", a.operation, actor.Ip, decision.Reason)
+			h.challenge(w, r, "", uerrors.ErrAuthRequired)
+			return
+		}
+
+		// The country identifies anonymous callers, the address does when it is unknown.
+		subject := decision.CountryName
+		if subject == "" {
+			subject = actor.Ip
+		}
+		ctx := r.Context()
+		if decision.GrantToken {
+			// The token lets the caller run what the access policy grants it now, from where it is now.
+			claims := auth.Claims{
+				Principal: auth.Geo(subject),
+				Scope:     h.policy.Grants(r.Context(), ip, now),
+				Country:   decision.Country,
+			}
+			if claims.Country == "" {
+				claims.IP = actor.Ip
+			}
+			hash, err := h.service.CreateToken(claims)
+			if err != nil {
+				h.logger.Ctx(r.Context()).Errorf("error with create token: %v", err)
+			} else {
+				anonymousLog(r.Context(), h.logger, claims, actor.Ip).Infof("anonymous token granted for %v", claims.Scope)
+				w.Header().Add(headerXExpiresAfter, now.Local().Add(h.config.Auth.GeoTokenTTL).String())
+				w.Header().Add(headerAuthorization, hash)
+				ctx = context.WithValue(ctx, issuedTokenKey{}, hash)
+			}
+		}
+		actor.Type, actor.Id = models.ActorTypeGeo, subject
+		next(w, r.WithContext(h.withActor(ctx, actor)))
+	})
+}
+
+// authenticateApiKey lets through requests with a valid API key having the permission the access requires.
+func (h handler) authenticateApiKey(w http.ResponseWriter, r *http.Request, a access, header string,
+	next http.HandlerFunc) {
+	actor := models.ActorFromContext(r.Context())
+	key, err := h.service.CheckApiKey(r.Context(), header, actor.Ip)
+	if errors.Is(err, uerrors.ErrInvalidApiKey) {
+		h.logger.Ctx(r.Context()).Infof("can't authenticate %s: %v", actor.Ip, err)
+		w.Header().Set(headerWWWAuthenticate, fmt.Sprintf("%s realm=%q", apiKeyScheme, authRealm))
+	}
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't check api key: %w", err))
+		return
+	}
+
+	actor.Type, actor.Id, actor.Scopes = models.ActorTypeApiKey, key.Id, key.Scopes
+	if a.permission == "" {
+		h.writeError(w, r, uerrors.ErrForbidden.WithDetail("the operation is open to users only"))
+		return
+	}
+	if !actor.Can(a.permission) {
+		h.writeError(w, r, uerrors.ErrForbidden.WithDetail("the api key lacks %s permission", a.permission))
+		return
+	}
+	h.logger.Ctx(r.Context()).WithFields(logrus.Fields{"api_key": key.Id, "name": key.Name, "ip": actor.Ip}).
+		Infof("api key used for %s %s", r.Method, r.URL.Path)
+	next(w, r.WithContext(h.withActor(r.Context(), actor)))
+}
+
+// withActor returns a copy of ctx carrying the authenticated actor, whose principal is added
+// to the log lines of the request.
+func (h handler) withActor(ctx context.Context, actor models.Actor) context.Context {
+	ctx = models.WithActor(ctx, actor)
+	return h.logger.ContextWithFields(ctx, logrus.Fields{"principal": actor.Type + ":" + actor.Id})
+}
+
+// boundTo reports whether the caller at the address is the one the geo token was issued to.
+func (h handler) boundTo(ctx context.Context, claims auth.Claims, ip string) bool {
+	if claims.IP != "" {
+		return net.ParseIP(claims.IP).Equal(net.ParseIP(ip))
+	}
+	location, err := h.policy.Locate(ctx, net.ParseIP(ip))
+	if err != nil {
+		h.logger.Ctx(ctx).Infof("can't locate %s: %v", ip, err)
+		return false
+	}
+	return location.CountryCode == claims.Country
+}
+
+// anonymousLog returns the log of anonymous token usage, kept apart from user sessions by its session field.
+func anonymousLog(ctx context.Context, l *logger.Logger, claims auth.Claims, ip string) *logrus.Entry {
+	fields := logrus.Fields{"session": "anonymous", "subject": claims.Principal.Subject, "ip": ip}
+	if claims.TokenId != "" {
+		fields["jti"] = claims.TokenId
+	}
+	return l.Ctx(ctx).WithFields(fields)
+}
+
+// forbid writes 403 for a principal lacking the scope of the request.
+func (h handler) forbid(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set(headerWWWAuthenticate, fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope"`, authRealm))
+	h.writeError(w, r, uerrors.ErrForbidden.WithDetail("%s", detail))
+}
+
+// challenge writes 401 with the problem of err asking for a bearer token, errorCode is the RFC 6750 error if any.
+func (h handler) challenge(w http.ResponseWriter, r *http.Request, errorCode string, err error) {
+	value := fmt.Sprintf("Bearer realm=%q", authRealm)
+	if errorCode != "" {
+		value += fmt.Sprintf(", error=%q", errorCode)
+	}
+	w.Header().Set(headerWWWAuthenticate, value)
+	h.writeErrorStatus(w, r, http.StatusUnauthorized, err)
+}
+
+// invalidTokenError returns the RFC 6750 error code for a failed token check,
+// none for credentials of another scheme.
+func invalidTokenError(err error) string {
+	if errors.Is(err, uerrors.ErrAuthScheme) {
+		return ""
+	}
+	return "invalid_token"
+}
+
+// can reports whether the request was authenticated as a user or an API key having the permission.
+func (h handler) can(r *http.Request, permission string) bool {
+	return models.ActorFromContext(r.Context()).Can(permission)
+}
+
+// userRoles returns the stored roles of the user, with the admin role added for configured administrators.
+func (h handler) userRoles(user *models.User) []string {
+	roles := user.Roles
+	for _, admin := range h.config.Auth.Admins {
+		if admin == user.Id && !models.HasPermission(roles, models.PermissionRoleManage) {
+			roles = append(roles, models.RoleAdmin)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,193 @@
+package company_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/dkischenko/xm_app/pkg/requestid"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Authenticate(t *testing.T) {
+	var (
+		admin  = []string{models.RoleAdmin}
+		viewer = []string{models.RoleViewer}
+	)
+	testCases := []struct {
+		name      string
+		method    string
+		target    string
+		header    string
+		roles     []string
+		authErr   error
+		status    int
+		challenge string
+	}{
+		{name: "[Ok] User reads company history", method: http.MethodGet, target: "/v1/companies/1/history",
+			header: "Bearer token", roles: viewer, status: http.StatusOK},
+		{name: "[Ok] Admin reads audit log", method: http.MethodGet, target: "/v1/audit", header: "Bearer token",
+			roles: admin, status: http.StatusOK},
+		{name: "[Err] History without token", method: http.MethodGet, target: "/v1/companies/1/history",
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app"`},
+		{name: "[Err] Audit log without token", method: http.MethodGet, target: "/v1/audit",
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app"`},
+		{name: "[Err] Audit log of a viewer", method: http.MethodGet, target: "/v1/audit", header: "Bearer token",
+			roles: viewer, status: http.StatusForbidden, challenge: `Bearer realm="xm_app", error="insufficient_scope"`},
+		{name: "[Err] Update by a viewer", method: http.MethodPut, target: "/v1/companies/1", header: "Bearer token",
+			roles: viewer, status: http.StatusForbidden, challenge: `Bearer realm="xm_app", error="insufficient_scope"`},
+		{name: "[Err] Update with invalid token", method: http.MethodPut, target: "/v1/companies/1",
+			header: "Bearer token", authErr: fmt.Errorf("error occurs: %w", uerrors.ErrParseToken),
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app", error="invalid_token"`},
+		{name: "[Err] Update with empty token", method: http.MethodPut, target: "/v1/companies/1",
+			header: "Bearer", authErr: fmt.Errorf("error occurs: %w", uerrors.ErrEmptyToken),
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app", error="invalid_token"`},
+		{name: "[Err] Update with other scheme", method: http.MethodPut, target: "/v1/companies/1",
+			header: "Basic dXNlcjpwYXNz", authErr: fmt.Errorf("error occurs: %w", uerrors.ErrAuthScheme),
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app"`},
+		{name: "[Err] Token denylist unavailable", method: http.MethodGet, target: "/v1/audit", header: "Bearer token",
+			authErr: fmt.Errorf("error occurs: %w: can't check the token denylist", uerrors.ErrUnavailable),
+			status:  http.StatusServiceUnavailable},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(tcase.method, tcase.target, nil)
+			req.RemoteAddr = "192.168.0.1:5000"
+			if tcase.header != "" {
+				req.Header.Set("Authorization", tcase.header)
+			}
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			mockService := mock_company.NewMockIService(ctrl)
+			if tcase.header != "" {
+				mockService.EXPECT().CheckAuth(gomock.Any(), tcase.header).Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: tcase.roles}, tcase.authErr)
+			}
+			mockService.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any()).Return(models.AuditPage{}, nil).MaxTimes(1)
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			assert.Equal(t, tcase.challenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestHandler_AuthenticateLogsPrincipal(t *testing.T) {
+	t.Run("[Ok] Service logs with request fields and principal", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		nullLogger, hook := test.NewNullLogger()
+		l := &logger.Logger{Entry: logrus.NewEntry(nullLogger)}
+		mockService := mock_company.NewMockIService(ctrl)
+		mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").
+			Return(auth.Claims{Principal: auth.User(roleUserId), Roles: []string{models.RoleViewer}}, nil)
+		mockService.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error) {
+				l.Ctx(ctx).Info("reading history")
+				return models.AuditPage{}, nil
+			})
+		h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+		router := mux.NewRouter()
+		router.Use(requestid.Middleware, l.Middleware)
+		h.Register(router)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/companies/1/history", nil)
+		req.RemoteAddr = "192.168.0.1:5000"
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set(requestid.Header, "abc")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, logrus.Fields{"request_id": "abc", "route": "/v1/companies/{id:[0-9]+}/history",
+			"method": http.MethodGet, "ip": "192.168.0.1", "principal": "user:" + roleUserId}, hook.LastEntry().Data)
+	})
+}
+
+func TestHandler_AuthenticateGeoToken(t *testing.T) {
+	deleteScope := []string{policy.OperationCompanyDelete}
+	testCases := []struct {
+		name      string
+		target    string
+		claims    auth.Claims
+		location  geo.Location
+		status    int
+		challenge string
+	}{
+		{name: "[Ok] Bound country", target: "/v1/companies/1", location: geo.Location{CountryCode: "CY"},
+			claims: auth.Claims{Principal: auth.Geo("Cyprus"), Scope: deleteScope, Country: "CY"}, status: http.StatusOK},
+		{name: "[Ok] Bound address", target: "/v1/companies/1",
+			claims: auth.Claims{Principal: auth.Geo("31.153.0.1"), Scope: deleteScope, IP: "31.153.0.1"}, status: http.StatusOK},
+		{name: "[Err] Other country", target: "/v1/companies/1", location: geo.Location{CountryCode: "GR"},
+			claims: auth.Claims{Principal: auth.Geo("Cyprus"), Scope: deleteScope, Country: "CY"},
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app", error="invalid_token"`},
+		{name: "[Err] Other address", target: "/v1/companies/1",
+			claims: auth.Claims{Principal: auth.Geo("10.0.0.1"), Scope: deleteScope, IP: "10.0.0.1"},
+			status: http.StatusUnauthorized, challenge: `Bearer realm="xm_app", error="invalid_token"`},
+		{name: "[Err] Operation out of scope", target: "/v1/companies/1",
+			claims: auth.Claims{Principal: auth.Geo("Cyprus"), Scope: []string{policy.OperationCompanyCreate}, Country: "CY"},
+			status: http.StatusForbidden, challenge: `Bearer realm="xm_app", error="insufficient_scope"`},
+		{name: "[Err] User route", target: "/v1/companies/1/history",
+			claims: auth.Claims{Principal: auth.Geo("Cyprus"), Scope: deleteScope, Country: "CY"},
+			status: http.StatusForbidden, challenge: `Bearer realm="xm_app", error="insufficient_scope"`},
+		// A token issued to an anonymous caller never passes for a user, whatever its roles.
+		{name: "[Err] Hard delete", target: "/v1/companies/1?hard=true", location: geo.Location{CountryCode: "CY"},
+			claims: auth.Claims{Principal: auth.Geo("Cyprus"), Scope: deleteScope, Country: "CY",
+				Roles: []string{models.RoleAdmin}}, status: http.StatusForbidden},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			method := http.MethodDelete
+			if strings.HasSuffix(tcase.target, "/history") {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tcase.target, nil)
+			req.RemoteAddr = "31.153.0.1:5000"
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockLocator := mock_geo.NewMockGeoLocator(ctrl)
+			mockLocator.EXPECT().Locate(gomock.Any(), net.ParseIP("31.153.0.1")).Return(tcase.location, nil).MaxTimes(1)
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(tcase.claims, nil)
+			mockService.EXPECT().DeleteCompany(gomock.Any(), 1, 0).
+				DoAndReturn(func(ctx context.Context, id int, version int) error {
+					actor := models.ActorFromContext(ctx)
+					assert.Equal(t, models.ActorTypeGeo, actor.Type)
+					assert.Equal(t, tcase.claims.Principal.Subject, actor.Id)
+					return nil
+				}).MaxTimes(1)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mockLocator, false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			assert.Equal(t, tcase.challenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package company
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+)
+
+func (s Service) GetCountries(ctx context.Context, region string) (countries []models.Country, err error) {
+	countries, err = s.storage.GetCountries(ctx, region)
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to get countries: %s", err)
+		return nil, storageError(err, uerrors.ErrGetCountries)
+	}
+	if countries == nil {
+		countries = []models.Country{}
+	}
+	return
+}
+
+// GetCountry returns the country with the given ISO 3166-1 code or English name.
+func (s Service) GetCountry(ctx context.Context, ref string) (country models.Country, err error) {
+	country, err = s.storage.FindCountry(ctx, ref)
+	if errors.Is(err, uerrors.ErrCountryNotFound) {
+		return country, fmt.Errorf("error occurs: %w", uerrors.ErrCountryNotFound.WithDetail("%q", ref))
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to get country: %s", err)
+		return country, storageError(err, uerrors.ErrGetCountries)
+	}
+	return
+}
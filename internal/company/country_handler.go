@@ -0,0 +1,38 @@
+package company
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func (h handler) GetCountriesHandler(w http.ResponseWriter, r *http.Request) {
+	countries, err := h.service.GetCountries(r.Context(), r.URL.Query().Get(paramRegion))
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't get countries: %w", err))
+		return
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(CountriesResponse{Countries: countries}); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't get countries: %+v", err)
+		return
+	}
+}
+
+func (h handler) GetCountryHandler(w http.ResponseWriter, r *http.Request) {
+	country, err := h.service.GetCountry(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't get country: %w", err))
+		return
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(country); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't get country: %+v", err)
+		return
+	}
+}
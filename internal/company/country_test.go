@@ -0,0 +1,102 @@
+package company_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_GetCountries(t *testing.T) {
+	t.Run("[Ok] Countries of region", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/countries?region=Asia", nil)
+		req.Header.Set("Authorization", "ApiKey xm_key")
+		w := httptest.NewRecorder()
+		l, _ := logger.GetLogger()
+		mockService := mock_company.NewMockIService(ctrl)
+		mockService.EXPECT().CheckApiKey(gomock.Any(), "ApiKey xm_key", gomock.Any()).Return(models.ApiKey{Id: "key",
+			Scopes: []string{models.PermissionCompanyRead}}, nil)
+		mockService.EXPECT().GetCountries(gomock.Any(), "Asia").Return([]models.Country{cyprus}, nil)
+		h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+		router := mux.NewRouter()
+		h.Register(router)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var res company.CountriesResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		assert.Equal(t, []models.Country{cyprus}, res.Countries)
+	})
+}
+
+func TestHandler_GetCountry(t *testing.T) {
+	testCases := []struct {
+		name       string
+		code       string
+		serviceErr error
+		status     int
+	}{
+		{name: "[Ok] By alpha-3 code", code: "CYP", status: http.StatusOK},
+		{name: "[Err] Unknown country", code: "XX",
+			serviceErr: fmt.Errorf("error occurs: %w", uerrors.ErrCountryNotFound), status: http.StatusNotFound},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/countries/"+tcase.code, nil)
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleViewer}}, nil)
+			mockService.EXPECT().GetCountry(gomock.Any(), tcase.code).Return(cyprus, tcase.serviceErr)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_UpdateCompanyUnknownCountry(t *testing.T) {
+	t.Run("[Err] Unknown country", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		payload := `{"name": "Test", "code": 12345, "country": "Atlantis", "website": "https://example.com",
+			"phone": "+380662342437"}`
+		req := httptest.NewRequest(http.MethodPut, "/v1/companies/1", strings.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		l, _ := logger.GetLogger()
+		mockService := mock_company.NewMockIService(ctrl)
+		mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}}, nil)
+		mockService.EXPECT().UpdateCompany(gomock.Any(), 1, gomock.Any(), 0).
+			Return(0, fmt.Errorf("error occurs: %w", uerrors.ErrCountryNotFound))
+		h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+		router := mux.NewRouter()
+		h.Register(router)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, created_by, created_at, expires_at, revoked_at,
+	last_used_at, last_used_ip`
+
+func (p postgres) CreateApiKey(ctx context.Context, key *models.ApiKey) (id string, err error) {
+	q := `
+		INSERT INTO xm_db.api_keys (name, prefix, key_hash, scopes, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	err = p.db(ctx).QueryRow(ctx, q, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.CreatedBy, key.CreatedAt,
+		key.ExpiresAt).Scan(&id)
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return "", queryError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (p postgres) GetApiKeys(ctx context.Context) (keys []models.ApiKey, err error) {
+	q := "SELECT " + apiKeyColumns + " FROM xm_db.api_keys ORDER BY created_at DESC, id"
+
+	rows, err := p.db(ctx).Query(ctx, q)
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return nil, queryError(err, uerrors.ErrApiKey)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			p.logger.Ctx(ctx).Error(err)
+			return nil, queryError(err, uerrors.ErrApiKey)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return nil, queryError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (p postgres) GetApiKey(ctx context.Context, id string) (key models.ApiKey, err error) {
+	q := "SELECT " + apiKeyColumns + " FROM xm_db.api_keys WHERE id = $1 FOR UPDATE"
+
+	key, err = scanApiKey(p.db(ctx).QueryRow(ctx, q, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return key, queryError(err, uerrors.ErrApiKeyNotFound)
+	}
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return key, queryError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (p postgres) FindApiKey(ctx context.Context, prefix string) (key models.ApiKey, err error) {
+	q := "SELECT " + apiKeyColumns + " FROM xm_db.api_keys WHERE prefix = $1"
+
+	key, err = scanApiKey(p.db(ctx).QueryRow(ctx, q, prefix))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return key, queryError(err, uerrors.ErrInvalidApiKey)
+	}
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return key, queryError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (p postgres) RevokeApiKey(ctx context.Context, id string) (err error) {
+	q := `UPDATE xm_db.api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+
+	if _, err = p.db(ctx).Exec(ctx, q, time.Now().Unix(), id); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func (p postgres) TouchApiKey(ctx context.Context, id string, ip string) (err error) {
+	q := `UPDATE xm_db.api_keys SET last_used_at = $1, last_used_ip = $2 WHERE id = $3`
+
+	if _, err = p.db(ctx).Exec(ctx, q, time.Now().Unix(), ip, id); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrApiKey)
+	}
+	return
+}
+
+func scanApiKey(row pgx.Row) (key models.ApiKey, err error) {
+	err = row.Scan(&key.Id, &key.Name, &key.Prefix, &key.KeyHash, &key.Scopes, &key.CreatedBy, &key.CreatedAt,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.LastUsedIp)
+	return
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgx/v4"
+	"strconv"
+	"strings"
+)
+
+// auditLockKey is the advisory lock serializing writers of the audit hash chain.
+const auditLockKey = 7311
+
+const auditColumns = `id, entity, entity_id, action, actor_type, actor_id, ip, before, after,
+	created_at, prev_hash, hash`
+
+func (p postgres) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) (err error) {
+	err = p.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := p.db(ctx).Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+			return err
+		}
+
+		err := p.db(ctx).QueryRow(ctx, `SELECT hash FROM xm_db.audit_events ORDER BY id DESC LIMIT 1`).
+			Scan(&event.PrevHash)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		if event.Hash, err = event.ComputeHash(); err != nil {
+			return err
+		}
+
+		q := `
+			INSERT INTO xm_db.audit_events(entity, entity_id, action, actor_type, actor_id, ip, before, after,
+				created_at, prev_hash, hash)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id
+		`
+		return p.db(ctx).QueryRow(ctx, q, event.Entity, event.EntityId, event.Action, event.ActorType,
+			event.ActorId, event.Ip, nullableJSON(event.Before), nullableJSON(event.After), event.CreatedAt,
+			event.PrevHash, event.Hash).Scan(&event.Id)
+	})
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrCreateAuditEvent)
+	}
+	return nil
+}
+
+func (p postgres) GetAuditEvents(ctx context.Context, filter models.AuditFilter) (events []models.AuditEvent, err error) {
+	q := &listQuery{}
+	if filter.Entity != "" {
+		q.add("entity = ?", filter.Entity)
+	}
+	if filter.EntityId != "" {
+		q.add("entity_id = ?", filter.EntityId)
+	}
+	if filter.Cursor != 0 {
+		q.add("id < ?", filter.Cursor)
+	}
+
+	sql := "SELECT " + auditColumns + " FROM xm_db.audit_events"
+	if len(q.where) > 0 {
+		sql += " WHERE " + strings.Join(q.where, " AND ")
+	}
+	q.args = append(q.args, filter.Limit+1)
+	sql += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(q.args))
+
+	return p.queryAuditEvents(ctx, sql, q.args...)
+}
+
+func (p postgres) GetAuditChain(ctx context.Context, afterId int64, limit int) (events []models.AuditEvent, err error) {
+	sql := "SELECT " + auditColumns + " FROM xm_db.audit_events WHERE id > $1 ORDER BY id LIMIT $2"
+	return p.queryAuditEvents(ctx, sql, afterId, limit)
+}
+
+func (p postgres) queryAuditEvents(ctx context.Context, sql string, args ...interface{}) (events []models.AuditEvent, err error) {
+	rows, err := p.db(ctx).Query(ctx, sql, args...)
+	if err != nil {
+		p.logger.Ctx(ctx).Errorf("error while executing query: %s", err)
+		return nil, queryError(err, uerrors.ErrGetAuditEvents)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e             models.AuditEvent
+			before, after []byte
+		)
+		err = rows.Scan(&e.Id, &e.Entity, &e.EntityId, &e.Action, &e.ActorType, &e.ActorId, &e.Ip,
+			&before, &after, &e.CreatedAt, &e.PrevHash, &e.Hash)
+		if err != nil {
+			p.logger.Ctx(ctx).Errorf("Scan: %v", err)
+			return nil, queryError(err, uerrors.ErrGetAuditEvents)
+		}
+		e.Before, e.After = before, after
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func nullableJSON(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgx/v4"
+)
+
+const countryColumns = `id, alpha2, alpha3, numeric_code, name, region`
+
+func (p postgres) GetCountries(ctx context.Context, region string) (countries []models.Country, err error) {
+	q := "SELECT " + countryColumns + " FROM xm_db.countries WHERE ($1 = '' OR lower(region) = lower($1)) ORDER BY name"
+
+	rows, err := p.db(ctx).Query(ctx, q, region)
+	if err != nil {
+		p.logger.Ctx(ctx).Errorf("error while executing query: %s", err)
+		return nil, queryError(err, uerrors.ErrGetCountries)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Country
+		if err = rows.Scan(&c.Id, &c.Alpha2, &c.Alpha3, &c.Numeric, &c.Name, &c.Region); err != nil {
+			p.logger.Ctx(ctx).Errorf("Scan: %v", err)
+			return nil, queryError(err, uerrors.ErrGetCountries)
+		}
+		countries = append(countries, c)
+	}
+
+	return countries, rows.Err()
+}
+
+func (p postgres) FindCountry(ctx context.Context, ref string) (country models.Country, err error) {
+	q := "SELECT " + countryColumns + ` FROM xm_db.countries
+		WHERE alpha2 = upper($1) OR alpha3 = upper($1) OR numeric_code = $1 OR lower(name) = lower($1)`
+
+	err = p.db(ctx).QueryRow(ctx, q, ref).Scan(&country.Id, &country.Alpha2, &country.Alpha3, &country.Numeric,
+		&country.Name, &country.Region)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return country, queryError(err, uerrors.ErrCountryNotFound)
+	}
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return country, queryError(err, uerrors.ErrGetCountries)
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"strconv"
+	"strings"
+)
+
+// sortColumns maps public sort keys to SQL expressions. Only these
+// expressions are ever interpolated into the query text. The timestamp sorts
+// must match the expressions of their indexes, or pages are sorted instead of read in order.
+var sortColumns = map[string]string{
+	models.SortById:        "c.id",
+	models.SortByName:      "c.name",
+	models.SortByCode:      "c.code",
+	models.SortByCountry:   "co.name",
+	models.SortByCreatedAt: "coalesce(c.created_at, 0)",
+	models.SortByUpdatedAt: "coalesce(c.updated_at, 0)",
+}
+
+var numericSorts = map[string]bool{
+	models.SortById:        true,
+	models.SortByCode:      true,
+	models.SortByCreatedAt: true,
+	models.SortByUpdatedAt: true,
+}
+
+type listQuery struct {
+	where []string
+	args  []interface{}
+}
+
+func (q *listQuery) add(cond string, args ...interface{}) {
+	for _, a := range args {
+		q.args = append(q.args, a)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(q.args)), 1)
+	}
+	q.where = append(q.where, cond)
+}
+
+// buildListQuery returns the companies list query for the filter. It fetches
+// one row more than the limit, so the caller can tell whether a next page exists.
+func buildListQuery(filter models.CompanyFilter) (string, []interface{}, error) {
+	column, ok := sortColumns[filter.Sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort field %q", filter.Sort)
+	}
+
+	q := &listQuery{}
+	if filter.Trashed {
+		q.add("c.deleted_at IS NOT NULL")
+	} else {
+		q.add("c.deleted_at IS NULL")
+	}
+	if filter.NamePrefix != "" {
+		q.add("c.name ILIKE ? ESCAPE '\\'", escapeLike(filter.NamePrefix)+"%")
+	}
+	if filter.Code != 0 {
+		q.add("c.code = ?", filter.Code)
+	}
+	if filter.Country != "" {
+		q.add("(lower(co.name) = lower(?) OR co.alpha2 = upper(?) OR co.alpha3 = upper(?))",
+			filter.Country, filter.Country, filter.Country)
+	}
+	if filter.CreatedFrom != 0 {
+		q.add("c.created_at >= ?", filter.CreatedFrom)
+	}
+	if filter.CreatedTo != 0 {
+		q.add("c.created_at <= ?", filter.CreatedTo)
+	}
+	if filter.UpdatedFrom != 0 {
+		q.add("c.updated_at >= ?", filter.UpdatedFrom)
+	}
+	if filter.UpdatedTo != 0 {
+		q.add("c.updated_at <= ?", filter.UpdatedTo)
+	}
+
+	direction, op := "ASC", ">"
+	if filter.Desc {
+		direction, op = "DESC", "<"
+	}
+
+	if filter.Cursor != nil {
+		var value interface{} = filter.Cursor.Value
+		if numericSorts[filter.Sort] {
+			v, err := strconv.ParseInt(filter.Cursor.Value, 10, 64)
+			if err != nil {
+				return "", nil, models.ErrInvalidCursor
+			}
+			value = v
+		}
+		if filter.Sort == models.SortById {
+			q.add(fmt.Sprintf("c.id %s ?", op), filter.Cursor.Id)
+		} else {
+			q.add(fmt.Sprintf("(%s, c.id) %s (?, ?)", column, op), value, filter.Cursor.Id)
+		}
+	}
+
+	sql := `
+		SELECT c.id, c.name, c.code, co.id, co.alpha2, co.alpha3, co.numeric_code, co.name, co.region,
+			c.website, c.phone, c.created_at, c.updated_at, c.version, c.deleted_at
+		FROM xm_db.companies c
+		JOIN xm_db.countries co ON co.id = c.country_id
+	`
+	sql += " WHERE " + strings.Join(q.where, " AND ")
+	if filter.Sort == models.SortById {
+		sql += fmt.Sprintf(" ORDER BY c.id %s", direction)
+	} else {
+		sql += fmt.Sprintf(" ORDER BY %s %s, c.id %s", column, direction, direction)
+	}
+	q.args = append(q.args, filter.Limit+1)
+	sql += " LIMIT $" + strconv.Itoa(len(q.args))
+
+	return sql, q.args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
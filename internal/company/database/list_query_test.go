@@ -0,0 +1,55 @@
+package database
+
+import (
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestBuildListQuery(t *testing.T) {
+	t.Run("[Ok] Filters, keyset and order", func(t *testing.T) {
+		filter := models.CompanyFilter{
+			Limit:      10,
+			NamePrefix: "50%_",
+			Country:    "cy",
+			Sort:       models.SortByUpdatedAt,
+			Desc:       true,
+			Cursor:     &models.CompanyCursor{Sort: models.SortByUpdatedAt, Desc: true, Value: "1651002057", Id: 7},
+		}
+		q, args, err := buildListQuery(filter)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		assert.Contains(t, q, "WHERE c.deleted_at IS NULL AND c.name ILIKE $1")
+		assert.Contains(t, q, "(lower(co.name) = lower($2) OR co.alpha2 = upper($3) OR co.alpha3 = upper($4))")
+		assert.Contains(t, q, "(coalesce(c.updated_at, 0), c.id) < ($5, $6)")
+		assert.True(t, strings.HasSuffix(q, "ORDER BY coalesce(c.updated_at, 0) DESC, c.id DESC LIMIT $7"))
+		assert.Equal(t, []interface{}{`50\%\_%`, "cy", "cy", "cy", int64(1651002057), 7, 11}, args)
+	})
+
+	t.Run("[Ok] Trash", func(t *testing.T) {
+		q, args, err := buildListQuery(models.CompanyFilter{Limit: 10, Sort: models.SortById, Trashed: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		assert.Contains(t, q, "WHERE c.deleted_at IS NOT NULL ORDER BY c.id ASC LIMIT $1")
+		assert.Equal(t, []interface{}{11}, args)
+	})
+
+	t.Run("[Err] Unknown sort", func(t *testing.T) {
+		_, _, err := buildListQuery(models.CompanyFilter{Limit: 10, Sort: "phone; DROP TABLE"})
+		assert.Error(t, err)
+	})
+
+	t.Run("[Err] Non numeric cursor value", func(t *testing.T) {
+		_, _, err := buildListQuery(models.CompanyFilter{
+			Limit:  10,
+			Sort:   models.SortByCode,
+			Cursor: &models.CompanyCursor{Sort: models.SortByCode, Value: "abc", Id: 1},
+		})
+		assert.ErrorIs(t, err, models.ErrInvalidCursor)
+	})
+}
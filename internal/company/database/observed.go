@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/internal/company"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/metrics"
+	"time"
+)
+
+// observed records the duration of every call of the repository by method, and marks the
+// calls failed because the database couldn't be reached with uerrors.ErrUnavailable.
+type observed struct {
+	next company.Repository
+}
+
+func (o observed) Create(ctx context.Context, company models.CompanyCreateRequest, countryId int) (id int, err error) {
+	defer metrics.ObserveQuery("Create", time.Now())
+	defer unavailable(&err)
+	return o.next.Create(ctx, company, countryId)
+}
+
+func (o observed) GetList(ctx context.Context, filter models.CompanyFilter) (companies []models.Company, err error) {
+	defer metrics.ObserveQuery("GetList", time.Now())
+	defer unavailable(&err)
+	return o.next.GetList(ctx, filter)
+}
+
+func (o observed) Search(ctx context.Context, query string,
+	limit int) (companies []models.CompanySearchResult, err error) {
+	defer metrics.ObserveQuery("Search", time.Now())
+	defer unavailable(&err)
+	return o.next.Search(ctx, query, limit)
+}
+
+func (o observed) GetCompany(ctx context.Context, companyId int) (company models.Company, err error) {
+	defer metrics.ObserveQuery("GetCompany", time.Now())
+	defer unavailable(&err)
+	return o.next.GetCompany(ctx, companyId)
+}
+
+func (o observed) Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, countryId int,
+	version int) (newVersion int, err error) {
+	defer metrics.ObserveQuery("Update", time.Now())
+	defer unavailable(&err)
+	return o.next.Update(ctx, companyId, company, countryId, version)
+}
+
+func (o observed) Delete(ctx context.Context, companyId int, version int) (err error) {
+	defer metrics.ObserveQuery("Delete", time.Now())
+	defer unavailable(&err)
+	return o.next.Delete(ctx, companyId, version)
+}
+
+func (o observed) Restore(ctx context.Context, companyId int) (newVersion int, err error) {
+	defer metrics.ObserveQuery("Restore", time.Now())
+	defer unavailable(&err)
+	return o.next.Restore(ctx, companyId)
+}
+
+func (o observed) Purge(ctx context.Context, companyId int, version int) (company models.Company, err error) {
+	defer metrics.ObserveQuery("Purge", time.Now())
+	defer unavailable(&err)
+	return o.next.Purge(ctx, companyId, version)
+}
+
+func (o observed) GetCountries(ctx context.Context, region string) (countries []models.Country, err error) {
+	defer metrics.ObserveQuery("GetCountries", time.Now())
+	defer unavailable(&err)
+	return o.next.GetCountries(ctx, region)
+}
+
+func (o observed) FindCountry(ctx context.Context, ref string) (country models.Country, err error) {
+	defer metrics.ObserveQuery("FindCountry", time.Now())
+	defer unavailable(&err)
+	return o.next.FindCountry(ctx, ref)
+}
+
+func (o observed) CreateUser(ctx context.Context, user *models.User) (id string, err error) {
+	defer metrics.ObserveQuery("CreateUser", time.Now())
+	defer unavailable(&err)
+	return o.next.CreateUser(ctx, user)
+}
+
+func (o observed) FindOneUser(ctx context.Context, name string) (u *models.User, err error) {
+	defer metrics.ObserveQuery("FindOneUser", time.Now())
+	defer unavailable(&err)
+	return o.next.FindOneUser(ctx, name)
+}
+
+func (o observed) GetUserRoles(ctx context.Context, userId string) (roles []string, err error) {
+	defer metrics.ObserveQuery("GetUserRoles", time.Now())
+	defer unavailable(&err)
+	return o.next.GetUserRoles(ctx, userId)
+}
+
+func (o observed) AddUserRole(ctx context.Context, userId string, role string) (err error) {
+	defer metrics.ObserveQuery("AddUserRole", time.Now())
+	defer unavailable(&err)
+	return o.next.AddUserRole(ctx, userId, role)
+}
+
+func (o observed) RemoveUserRole(ctx context.Context, userId string, role string) (err error) {
+	defer metrics.ObserveQuery("RemoveUserRole", time.Now())
+	defer unavailable(&err)
+	return o.next.RemoveUserRole(ctx, userId, role)
+}
+
+func (o observed) CreateRefreshToken(ctx context.Context, tokenHash string, familyId string, userId string,
+	expiresAt int64) (newFamilyId string, err error) {
+	defer metrics.ObserveQuery("CreateRefreshToken", time.Now())
+	defer unavailable(&err)
+	return o.next.CreateRefreshToken(ctx, tokenHash, familyId, userId, expiresAt)
+}
+
+func (o observed) FindRefreshToken(ctx context.Context, tokenHash string) (token models.RefreshToken, err error) {
+	defer metrics.ObserveQuery("FindRefreshToken", time.Now())
+	defer unavailable(&err)
+	return o.next.FindRefreshToken(ctx, tokenHash)
+}
+
+func (o observed) UseRefreshToken(ctx context.Context, id int64) (err error) {
+	defer metrics.ObserveQuery("UseRefreshToken", time.Now())
+	defer unavailable(&err)
+	return o.next.UseRefreshToken(ctx, id)
+}
+
+func (o observed) RevokeTokenFamily(ctx context.Context, familyId string) (err error) {
+	defer metrics.ObserveQuery("RevokeTokenFamily", time.Now())
+	defer unavailable(&err)
+	return o.next.RevokeTokenFamily(ctx, familyId)
+}
+
+func (o observed) RevokeToken(ctx context.Context, jti string, expiresAt int64) (err error) {
+	defer metrics.ObserveQuery("RevokeToken", time.Now())
+	defer unavailable(&err)
+	return o.next.RevokeToken(ctx, jti, expiresAt)
+}
+
+func (o observed) IsTokenRevoked(ctx context.Context, jti string) (revoked bool, err error) {
+	defer metrics.ObserveQuery("IsTokenRevoked", time.Now())
+	defer unavailable(&err)
+	return o.next.IsTokenRevoked(ctx, jti)
+}
+
+func (o observed) CreateApiKey(ctx context.Context, key *models.ApiKey) (id string, err error) {
+	defer metrics.ObserveQuery("CreateApiKey", time.Now())
+	defer unavailable(&err)
+	return o.next.CreateApiKey(ctx, key)
+}
+
+func (o observed) GetApiKeys(ctx context.Context) (keys []models.ApiKey, err error) {
+	defer metrics.ObserveQuery("GetApiKeys", time.Now())
+	defer unavailable(&err)
+	return o.next.GetApiKeys(ctx)
+}
+
+func (o observed) GetApiKey(ctx context.Context, id string) (key models.ApiKey, err error) {
+	defer metrics.ObserveQuery("GetApiKey", time.Now())
+	defer unavailable(&err)
+	return o.next.GetApiKey(ctx, id)
+}
+
+func (o observed) FindApiKey(ctx context.Context, prefix string) (key models.ApiKey, err error) {
+	defer metrics.ObserveQuery("FindApiKey", time.Now())
+	defer unavailable(&err)
+	return o.next.FindApiKey(ctx, prefix)
+}
+
+func (o observed) RevokeApiKey(ctx context.Context, id string) (err error) {
+	defer metrics.ObserveQuery("RevokeApiKey", time.Now())
+	defer unavailable(&err)
+	return o.next.RevokeApiKey(ctx, id)
+}
+
+func (o observed) TouchApiKey(ctx context.Context, id string, ip string) (err error) {
+	defer metrics.ObserveQuery("TouchApiKey", time.Now())
+	defer unavailable(&err)
+	return o.next.TouchApiKey(ctx, id, ip)
+}
+
+// WithinTransaction isn't observed, its duration is the one of fn.
+func (o observed) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer unavailable(&err)
+	return o.next.WithinTransaction(ctx, fn)
+}
+
+func (o observed) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) (err error) {
+	defer metrics.ObserveQuery("CreateAuditEvent", time.Now())
+	defer unavailable(&err)
+	return o.next.CreateAuditEvent(ctx, event)
+}
+
+func (o observed) GetAuditEvents(ctx context.Context,
+	filter models.AuditFilter) (events []models.AuditEvent, err error) {
+	defer metrics.ObserveQuery("GetAuditEvents", time.Now())
+	defer unavailable(&err)
+	return o.next.GetAuditEvents(ctx, filter)
+}
+
+func (o observed) GetAuditChain(ctx context.Context, afterId int64, limit int) (events []models.AuditEvent, err error) {
+	defer metrics.ObserveQuery("GetAuditChain", time.Now())
+	defer unavailable(&err)
+	return o.next.GetAuditChain(ctx, afterId, limit)
+}
@@ -2,25 +2,73 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dkischenko/xm_app/internal/company"
 	"github.com/dkischenko/xm_app/internal/company/models"
 	uerrors "github.com/dkischenko/xm_app/internal/errors"
 	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"time"
 )
 
+// codeUniqueViolation is the SQLSTATE of writes breaking a unique constraint.
+const codeUniqueViolation = "23505"
+
 type postgres struct {
 	logger *logger.Logger
 	pool   *pgxpool.Pool
 }
 
+type txKey struct{}
+
+// querier is implemented by both the pool and a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 func NewStorage(pool *pgxpool.Pool, logger *logger.Logger) company.Repository {
-	return &postgres{
+	return observed{next: &postgres{
 		logger: logger,
 		pool:   pool,
+	}}
+}
+
+// db returns the transaction started by WithinTransaction, if any, or the pool.
+func (p postgres) db(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
 	}
+	return p.pool
+}
+
+func (p postgres) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return fmt.Errorf("Error occurs: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(ctx); rbErr != nil {
+				p.logger.Ctx(ctx).Errorf("rollback: %s", rbErr)
+			}
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (p postgres) Create(ctx context.Context, company models.CompanyCreateRequest, countryId int) (id int, err error) {
@@ -32,12 +80,12 @@ func (p postgres) Create(ctx context.Context, company models.CompanyCreateReques
 		RETURNING id
 	`
 
-	err = p.pool.QueryRow(ctx, q, company.Name, company.Code, countryId, company.Website, company.Phone, time.Now().Unix(), time.Now().Unix()).
+	err = p.db(ctx).QueryRow(ctx, q, company.Name, company.Code, countryId, company.Website, company.Phone, time.Now().Unix(), time.Now().Unix()).
 		Scan(&c.Id)
 
 	if err != nil {
-		p.logger.Entry.Error(err)
-		return 0, fmt.Errorf("Error occurs: %w. %w", err, uerrors.ErrCreateCompany)
+		p.logger.Ctx(ctx).Error(err)
+		return 0, queryError(err, uerrors.ErrCreateCompany)
 	}
 
 	return c.Id, nil
@@ -45,101 +93,219 @@ func (p postgres) Create(ctx context.Context, company models.CompanyCreateReques
 
 func (p postgres) GetCompany(ctx context.Context, companyId int) (company models.Company, err error) {
 	q := `
-		SELECT id, name, code, country_id, website, phone, created_at, updated_at
-		FROM xm_db.companies
-        WHERE id = $1 
+		SELECT c.id, c.name, c.code, co.id, co.alpha2, co.alpha3, co.numeric_code, co.name, co.region,
+			c.website, c.phone, c.created_at, c.updated_at, c.version
+		FROM xm_db.companies c
+		JOIN xm_db.countries co ON co.id = c.country_id
+        WHERE c.id = $1 AND c.deleted_at IS NULL
 	`
 
-	row := p.pool.QueryRow(ctx, q, companyId)
-	err = row.Scan(&company.Id, &company.Name, &company.Code, &company.CountryId, &company.Website,
-		&company.Phone, &company.CreatedAt, &company.UpdatedAt)
+	row := p.db(ctx).QueryRow(ctx, q, companyId)
+	err = row.Scan(&company.Id, &company.Name, &company.Code, &company.Country.Id, &company.Country.Alpha2,
+		&company.Country.Alpha3, &company.Country.Numeric, &company.Country.Name, &company.Country.Region,
+		&company.Website, &company.Phone, &company.CreatedAt, &company.UpdatedAt, &company.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return company, queryError(err, uerrors.ErrCompanyNotFound)
+	}
 	if err != nil {
-		p.logger.Entry.Error(err)
+		p.logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	return
 }
 
-func (p postgres) GetList(ctx context.Context) (companies []models.Company, err error) {
+func (p postgres) GetList(ctx context.Context, filter models.CompanyFilter) (companies []models.Company, err error) {
+	q, args, err := buildListQuery(filter)
+	if err != nil {
+		p.logger.Ctx(ctx).Errorf("error while building query: %s", err)
+		return nil, fmt.Errorf("Error occurs: %w", err)
+	}
+
+	rows, err := p.db(ctx).Query(ctx, q, args...)
+	if err != nil {
+		p.logger.Ctx(ctx).Errorf("error while executing query: %s", err)
+		return nil, queryError(err, uerrors.ErrGetCompanies)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Company
+		err = rows.Scan(&r.Id, &r.Name, &r.Code, &r.Country.Id, &r.Country.Alpha2, &r.Country.Alpha3,
+			&r.Country.Numeric, &r.Country.Name, &r.Country.Region, &r.Website, &r.Phone,
+			&r.CreatedAt, &r.UpdatedAt, &r.Version, &r.DeletedAt)
+		if err != nil {
+			p.logger.Ctx(ctx).Errorf("Scan: %v", err)
+			return nil, queryError(err, uerrors.ErrGetCompanies)
+		}
+		companies = append(companies, r)
+	}
+
+	return companies, rows.Err()
+}
+
+func (p postgres) Search(ctx context.Context, query string, limit int) (companies []models.CompanySearchResult, err error) {
 	q := `
-		SELECT id, name, code, country_id, website, phone, created_at, updated_at
-		FROM xm_db.companies
+		SELECT c.id, c.name, c.code, co.id, co.alpha2, co.alpha3, co.numeric_code, co.name, co.region,
+			c.website, c.phone, c.created_at, c.updated_at, c.version,
+			ts_rank(c.search_vector, websearch_to_tsquery('simple', $1)) +
+				greatest(similarity(c.name, $1), word_similarity($1, c.name), similarity(c.website, $1)) AS score
+		FROM xm_db.companies c
+		JOIN xm_db.countries co ON co.id = c.country_id
+		WHERE c.deleted_at IS NULL AND (
+			c.search_vector @@ websearch_to_tsquery('simple', $1)
+			OR c.name % $1
+			OR $1 <% c.name
+			OR c.website % $1
+		)
+		ORDER BY score DESC, c.id
+		LIMIT $2
 	`
-	rows, err := p.pool.Query(ctx, q)
+	rows, err := p.db(ctx).Query(ctx, q, query, limit)
 	if err != nil {
-		p.logger.Entry.Fatal("error while executing query")
-		return nil, fmt.Errorf("Error occurs: %w. %w", err, uerrors.ErrGetCompanies)
+		p.logger.Ctx(ctx).Errorf("error while executing query: %s", err)
+		return nil, queryError(err, uerrors.ErrSearchCompanies)
 	}
+	defer rows.Close()
+
 	for rows.Next() {
-		var r models.Company
-		err = rows.Scan(&r.Id, &r.Name, &r.Code, &r.CountryId, &r.Website, &r.Phone, &r.CreatedAt, &r.UpdatedAt)
+		var r models.CompanySearchResult
+		err = rows.Scan(&r.Id, &r.Name, &r.Code, &r.Country.Id, &r.Country.Alpha2, &r.Country.Alpha3,
+			&r.Country.Numeric, &r.Country.Name, &r.Country.Region, &r.Website, &r.Phone,
+			&r.CreatedAt, &r.UpdatedAt, &r.Version, &r.Score)
 		if err != nil {
-			p.logger.Entry.Fatalf("Scan: %v", err)
-			return nil, fmt.Errorf("Error occurs: %w. %w", err, uerrors.ErrGetCompanies)
+			p.logger.Ctx(ctx).Errorf("Scan: %v", err)
+			return nil, queryError(err, uerrors.ErrSearchCompanies)
 		}
 		companies = append(companies, r)
 	}
 
-	return
+	return companies, rows.Err()
 }
 
-func (p postgres) Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest) (err error) {
+func (p postgres) Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, countryId int,
+	version int) (newVersion int, err error) {
 	q := `
 		UPDATE xm_db.companies
-		SET name = $1, code = $2, country_id = $3, website = $4, phone = $5, updated_at = $6 
-		WHERE id = $7
+		SET name = $1, code = $2, country_id = $3, website = $4, phone = $5, updated_at = $6,
+			version = version + 1
+		WHERE id = $7 AND ($8 = 0 OR version = $8) AND deleted_at IS NULL
+		RETURNING version
 	`
-	_, err = p.pool.Exec(ctx, q, company.Name, company.Code, company.CountryId, company.Website,
-		company.Phone, time.Now().Unix(), companyId)
+	err = p.db(ctx).QueryRow(ctx, q, company.Name, company.Code, countryId, company.Website,
+		company.Phone, time.Now().Unix(), companyId, version).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = p.checkVersion(ctx, companyId)
+	}
+	// The company is missing or has another version.
+	if uerrors.IsDomain(err) {
+		return 0, fmt.Errorf("Error occurs: %w", err)
+	}
 	if err != nil {
-		p.logger.Entry.Error(err)
-		return fmt.Errorf("Error occurs: %w", uerrors.ErrUpdateCompany)
+		p.logger.Ctx(ctx).Error(err)
+		return 0, queryError(err, uerrors.ErrUpdateCompany)
 	}
 	return
 }
 
-func (p postgres) Delete(ctx context.Context, companyId int) (err error) {
+func (p postgres) Delete(ctx context.Context, companyId int, version int) (err error) {
 	q := `
-		DELETE from xm_db.companies
-		WHERE id = $1
+		UPDATE xm_db.companies
+		SET deleted_at = $1, version = version + 1
+		WHERE id = $2 AND ($3 = 0 OR version = $3) AND deleted_at IS NULL
 	`
 
-	_, err = p.pool.Exec(ctx, q, companyId)
+	tag, err := p.db(ctx).Exec(ctx, q, time.Now().Unix(), companyId, version)
+	if err == nil && tag.RowsAffected() == 0 && version != 0 {
+		err = p.checkVersion(ctx, companyId)
+	}
+	// The company is missing or has another version.
+	if uerrors.IsDomain(err) {
+		return fmt.Errorf("Error occurs: %w", err)
+	}
 	if err != nil {
-		p.logger.Entry.Error(err)
-		return fmt.Errorf("Error occurs: %w", uerrors.ErrDeleteCompany)
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrDeleteCompany)
 	}
 	return
 }
 
-func (p postgres) CreateCountry(ctx context.Context, company models.CompanyCreateRequest) (id int, err error) {
-	country := &models.Country{}
+func (p postgres) Restore(ctx context.Context, companyId int) (newVersion int, err error) {
 	q := `
-		SELECT id, name
-		FROM xm_db.countries WHERE name = $1
+		UPDATE xm_db.companies
+		SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NOT NULL
+		RETURNING version
 	`
 
-	row := p.pool.QueryRow(ctx, q, company.Country)
-	err = row.Scan(&country.Id, &country.Name)
+	err = p.db(ctx).QueryRow(ctx, q, time.Now().Unix(), companyId).Scan(&newVersion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err = p.checkVersion(ctx, companyId); errors.Is(err, uerrors.ErrVersionMismatch) {
+			err = uerrors.ErrCompanyNotDeleted
+		}
+	}
+	// The company is missing or not deleted.
+	if uerrors.IsDomain(err) {
+		return 0, fmt.Errorf("Error occurs: %w", err)
+	}
 	if err != nil {
-		q = `
-			INSERT INTO xm_db.countries(name)
-			VALUES
-				($1)
-			RETURNING id
-		`
+		p.logger.Ctx(ctx).Error(err)
+		return 0, queryError(err, uerrors.ErrRestoreCompany)
+	}
+	return
+}
 
-		err = p.pool.QueryRow(ctx, q, company.Country).
-			Scan(&country.Id)
+func (p postgres) Purge(ctx context.Context, companyId int, version int) (company models.Company, err error) {
+	q := `
+		WITH d AS (
+			DELETE from xm_db.companies
+			WHERE id = $1 AND ($2 = 0 OR version = $2)
+			RETURNING *
+		)
+		SELECT d.id, d.name, d.code, co.id, co.alpha2, co.alpha3, co.numeric_code, co.name, co.region,
+			d.website, d.phone, d.created_at, d.updated_at, d.version, d.deleted_at
+		FROM d
+		JOIN xm_db.countries co ON co.id = d.country_id
+	`
 
-		if err != nil {
-			p.logger.Entry.Error(err)
-			return 0, fmt.Errorf("Error occurs: %w. %w", err, uerrors.ErrCreateCountry)
+	err = p.db(ctx).QueryRow(ctx, q, companyId, version).Scan(&company.Id, &company.Name, &company.Code,
+		&company.Country.Id, &company.Country.Alpha2, &company.Country.Alpha3, &company.Country.Numeric,
+		&company.Country.Name, &company.Country.Region, &company.Website, &company.Phone, &company.CreatedAt,
+		&company.UpdatedAt, &company.Version, &company.DeletedAt)
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		var id int
+		err = p.db(ctx).QueryRow(ctx, `SELECT id FROM xm_db.companies WHERE id = $1`, companyId).Scan(&id)
+		if err == nil {
+			err = uerrors.ErrVersionMismatch
 		}
 	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = uerrors.ErrCompanyNotFound
+	}
+	if uerrors.IsDomain(err) {
+		return company, fmt.Errorf("Error occurs: %w", err)
+	}
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return company, queryError(err, uerrors.ErrDeleteCompany)
+	}
+	return
+}
 
-	return country.Id, nil
+// checkVersion is called when a conditional write matched no rows. It returns
+// ErrVersionMismatch if the active company exists, so it must have another version,
+// and ErrCompanyNotFound if it doesn't.
+func (p postgres) checkVersion(ctx context.Context, companyId int) error {
+	var id int
+	err := p.db(ctx).QueryRow(ctx, `SELECT id FROM xm_db.companies WHERE id = $1 AND deleted_at IS NULL`, companyId).
+		Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uerrors.ErrCompanyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return uerrors.ErrVersionMismatch
 }
 
 func (p postgres) CreateUser(ctx context.Context, user *models.User) (id string, err error) {
@@ -149,10 +315,14 @@ func (p postgres) CreateUser(ctx context.Context, user *models.User) (id string,
 		           ($1, $2)
 		    RETURNING id
 	`
-	err = p.pool.QueryRow(ctx, q, user.Name, user.PasswordHash).Scan(&user.Id)
+	err = p.db(ctx).QueryRow(ctx, q, user.Name, user.PasswordHash).Scan(&user.Id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == codeUniqueViolation {
+		return "", queryError(err, uerrors.ErrUserExists)
+	}
 	if err != nil {
-		p.logger.Entry.Error(err)
-		return "", fmt.Errorf("Error occurs: %w. %w", err, uerrors.ErrCreateUser)
+		p.logger.Ctx(ctx).Error(err)
+		return "", queryError(err, uerrors.ErrCreateUser)
 	}
 	return user.Id, nil
 }
@@ -160,13 +330,19 @@ func (p postgres) CreateUser(ctx context.Context, user *models.User) (id string,
 func (p postgres) FindOneUser(ctx context.Context, name string) (u *models.User, err error) {
 	u = &models.User{}
 	q := `
-		SELECT id, username, password_hash
-		FROM xm_db.users WHERE username = $1
+		SELECT u.id, u.username, u.password_hash,
+			coalesce(array_agg(r.role ORDER BY r.role) FILTER (WHERE r.role IS NOT NULL), '{}')
+		FROM xm_db.users u LEFT JOIN xm_db.user_roles r ON r.user_id = u.id
+		WHERE u.username = $1
+		GROUP BY u.id
 	`
-	row := p.pool.QueryRow(ctx, q, name)
-	err = row.Scan(&u.Id, &u.Name, &u.PasswordHash)
+	row := p.db(ctx).QueryRow(ctx, q, name)
+	err = row.Scan(&u.Id, &u.Name, &u.PasswordHash, &u.Roles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return u, queryError(err, uerrors.ErrUserNotFound)
+	}
 	if err != nil {
-		p.logger.Entry.Error(err)
+		p.logger.Ctx(ctx).Error(err)
 		return u, err
 	}
 
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"errors"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgx/v4"
+)
+
+func (p postgres) GetUserRoles(ctx context.Context, userId string) (roles []string, err error) {
+	q := `
+		SELECT coalesce(array_agg(r.role ORDER BY r.role) FILTER (WHERE r.role IS NOT NULL), '{}')
+		FROM xm_db.users u LEFT JOIN xm_db.user_roles r ON r.user_id = u.id
+		WHERE u.id = $1
+		GROUP BY u.id
+	`
+
+	err = p.db(ctx).QueryRow(ctx, q, userId).Scan(&roles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, queryError(err, uerrors.ErrUserNotFound)
+	}
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return nil, queryError(err, uerrors.ErrGetRoles)
+	}
+	return
+}
+
+func (p postgres) AddUserRole(ctx context.Context, userId string, role string) (err error) {
+	q := `INSERT INTO xm_db.user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	if _, err = p.db(ctx).Exec(ctx, q, userId, role); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrUpdateRoles)
+	}
+	return
+}
+
+func (p postgres) RemoveUserRole(ctx context.Context, userId string, role string) (err error) {
+	q := `DELETE FROM xm_db.user_roles WHERE user_id = $1 AND role = $2`
+
+	if _, err = p.db(ctx).Exec(ctx, q, userId, role); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrUpdateRoles)
+	}
+	return
+}
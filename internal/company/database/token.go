@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+func (p postgres) CreateRefreshToken(ctx context.Context, tokenHash string, familyId string, userId string,
+	expiresAt int64) (newFamilyId string, err error) {
+	q := `
+		INSERT INTO xm_db.refresh_tokens (token_hash, family_id, user_id, created_at, expires_at)
+		VALUES ($1, coalesce(nullif($2, '')::uuid, gen_random_uuid()), $3, $4, $5)
+		RETURNING family_id
+	`
+
+	err = p.db(ctx).QueryRow(ctx, q, tokenHash, familyId, userId, time.Now().Unix(), expiresAt).Scan(&newFamilyId)
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return "", queryError(err, uerrors.ErrRefreshToken)
+	}
+	return
+}
+
+func (p postgres) FindRefreshToken(ctx context.Context, tokenHash string) (token models.RefreshToken, err error) {
+	q := `
+		SELECT id, family_id, user_id, expires_at, used_at, revoked_at
+		FROM xm_db.refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	err = p.db(ctx).QueryRow(ctx, q, tokenHash).Scan(&token.Id, &token.FamilyId, &token.UserId, &token.ExpiresAt,
+		&token.UsedAt, &token.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return token, queryError(err, uerrors.ErrInvalidRefreshToken)
+	}
+	if err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return token, queryError(err, uerrors.ErrRefreshToken)
+	}
+	return
+}
+
+func (p postgres) UseRefreshToken(ctx context.Context, id int64) (err error) {
+	q := `UPDATE xm_db.refresh_tokens SET used_at = $1 WHERE id = $2`
+
+	if _, err = p.db(ctx).Exec(ctx, q, time.Now().Unix(), id); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrRefreshToken)
+	}
+	return
+}
+
+func (p postgres) RevokeTokenFamily(ctx context.Context, familyId string) (err error) {
+	q := `UPDATE xm_db.refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+
+	if _, err = p.db(ctx).Exec(ctx, q, time.Now().Unix(), familyId); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrRevokeToken)
+	}
+	return
+}
+
+func (p postgres) RevokeToken(ctx context.Context, jti string, expiresAt int64) (err error) {
+	q := `INSERT INTO xm_db.revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	if _, err = p.db(ctx).Exec(ctx, q, jti, expiresAt); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrRevokeToken)
+	}
+	// Expired tokens are rejected anyway, they needn't stay on the list.
+	if _, err = p.db(ctx).Exec(ctx, `DELETE FROM xm_db.revoked_tokens WHERE expires_at < $1`,
+		time.Now().Unix()); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return queryError(err, uerrors.ErrRevokeToken)
+	}
+	return
+}
+
+func (p postgres) IsTokenRevoked(ctx context.Context, jti string) (revoked bool, err error) {
+	q := `SELECT EXISTS (SELECT 1 FROM xm_db.revoked_tokens WHERE jti = $1)`
+
+	if err = p.db(ctx).QueryRow(ctx, q, jti).Scan(&revoked); err != nil {
+		p.logger.Ctx(ctx).Error(err)
+		return false, queryError(err, uerrors.ErrRevokeToken)
+	}
+	return
+}
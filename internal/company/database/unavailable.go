@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgconn"
+	"net"
+	"strings"
+)
+
+// unavailableCodes are the SQLSTATEs, besides the connection exception class 08, of a server
+// that can't serve the query right now.
+var unavailableCodes = map[string]bool{
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isUnavailable reports whether err is a failure to reach the database rather than one of the query,
+// such as a refused connection, a pool acquire running out of time or a server shutting down.
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "08") || unavailableCodes[pgErr.Code])
+}
+
+// queryError returns the error of a failed query carrying the sentinel, or uerrors.ErrUnavailable
+// when the database couldn't be reached.
+func queryError(err error, sentinel error) error {
+	if isUnavailable(err) {
+		sentinel = uerrors.ErrUnavailable
+	}
+	return fmt.Errorf("Error occurs: %v. %w", err, sentinel)
+}
+
+// unavailable marks *err as uerrors.ErrUnavailable when it is a failure to reach the database
+// that no query wrapped yet.
+func unavailable(err *error) {
+	if isUnavailable(*err) && !errors.Is(*err, uerrors.ErrUnavailable) && !uerrors.IsDomain(*err) {
+		*err = fmt.Errorf("Error occurs: %v. %w", *err, uerrors.ErrUnavailable)
+	}
+}
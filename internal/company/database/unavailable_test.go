@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueryError(t *testing.T) {
+	// Nothing listens on port 1, so the connection is refused.
+	_, refused := pgconn.Connect(context.Background(), "postgres://user@127.0.0.1:1/db?connect_timeout=1")
+	if refused == nil {
+		t.Fatal("expected a connection error")
+	}
+
+	testCases := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{name: "[Ok] Connection refused", err: refused, unavailable: true},
+		{name: "[Ok] Pool acquire timed out", err: context.DeadlineExceeded, unavailable: true},
+		{name: "[Ok] Server shutting down", err: &pgconn.PgError{Code: "57P01"}, unavailable: true},
+		{name: "[Ok] Connection lost", err: &pgconn.PgError{Code: "08006"}, unavailable: true},
+		{name: "[Err] No rows", err: pgx.ErrNoRows},
+		{name: "[Err] Unique violation", err: &pgconn.PgError{Code: codeUniqueViolation}},
+		{name: "[Err] Query error", err: errors.New("syntax error")},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			err := queryError(tcase.err, uerrors.ErrGetCompany)
+			assert.Equal(t, tcase.unavailable, errors.Is(err, uerrors.ErrUnavailable))
+			assert.Equal(t, !tcase.unavailable, errors.Is(err, uerrors.ErrGetCompany))
+
+			err = tcase.err
+			unavailable(&err)
+			assert.Equal(t, tcase.unavailable, errors.Is(err, uerrors.ErrUnavailable))
+		})
+	}
+
+	t.Run("[Ok] Domain errors are kept", func(t *testing.T) {
+		err := fmt.Errorf("Error occurs: %w", uerrors.ErrCompanyNotFound)
+		unavailable(&err)
+		assert.ErrorIs(t, err, uerrors.ErrCompanyNotFound)
+		assert.NotErrorIs(t, err, uerrors.ErrUnavailable)
+	})
+}
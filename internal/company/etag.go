@@ -0,0 +1,65 @@
+package company
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+var (
+	errMissingIfMatch = errors.New("If-Match header is required")
+	errMultipleETags  = errors.New("only one entity tag is supported in If-Match")
+	errETagMismatch   = errors.New("entity tag does not match current version")
+)
+
+// formatETag returns the strong entity tag of a company version.
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch returns the version required by the If-Match header value.
+// Zero means that any current version satisfies the condition.
+func parseIfMatch(header string, strict bool) (version int, err error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		if strict {
+			return 0, errMissingIfMatch
+		}
+		return 0, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, errMultipleETags
+	}
+
+	// If-Match uses the strong comparison, so a weak tag never matches.
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return 0, errETagMismatch
+	}
+	version, err = strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version <= 0 {
+		return 0, errETagMismatch
+	}
+
+	return version, nil
+}
+
+// matchesIfNoneMatch reports whether the If-None-Match header value matches
+// the entity tag using the weak comparison.
+func matchesIfNoneMatch(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
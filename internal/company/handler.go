@@ -2,137 +2,158 @@ package company
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dkischenko/xm_app/internal/company/models"
 	"github.com/dkischenko/xm_app/internal/config"
 	uerrors "github.com/dkischenko/xm_app/internal/errors"
-	"github.com/dkischenko/xm_app/pkg/ipapi"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"github.com/dkischenko/xm_app/pkg/clientip"
 	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/dkischenko/xm_app/pkg/requestid"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 const (
 	company                = "/v1/companies"
 	users                  = "/v1/users"
 	usersLogin             = "/v1/users/login"
+	usersRefresh           = "/v1/users/refresh"
+	usersLogout            = "/v1/users/logout"
+	userRoles              = "/v1/users/{id}/roles"
+	userRole               = "/v1/users/{id}/roles/{role}"
+	apiKeys                = "/v1/api-keys"
+	apiKeyWithId           = "/v1/api-keys/{id}"
 	companyWithId          = "/v1/companies/{id:[0-9]+}"
+	companySearch          = "/v1/companies/search"
+	companyTrash           = "/v1/companies/trash"
+	companyRestore         = "/v1/companies/{id:[0-9]+}/restore"
+	companyHistory         = "/v1/companies/{id:[0-9]+}/history"
+	audit                  = "/v1/audit"
+	auditVerify            = "/v1/audit/verify"
+	countries              = "/v1/countries"
+	countryWithCode        = "/v1/countries/{code}"
+	policyExplain          = "/v1/policy/explain"
+	jwks                   = "/.well-known/jwks.json"
 	headerContentType      = "Content-Type"
 	headerValueContentType = "application/json"
 	headerAuthorization    = "Authorization"
 	headerXExpiresAfter    = "X-Expires-After"
+	headerAcceptPatch      = "Accept-Patch"
+	acceptPatchValue       = models.MediaTypeMergePatch + ", " + models.MediaTypeJSONPatch
+	maxPatchSize           = 1 << 20
 )
 
 type handler struct {
 	logger  *logger.Logger
 	service IService
 	config  *config.Config
+	policy  *policy.Engine
 }
 
-func NewHandler(logger *logger.Logger, service IService, cfg *config.Config) *handler {
+func NewHandler(logger *logger.Logger, service IService, cfg *config.Config, engine *policy.Engine) *handler {
 	return &handler{
 		logger:  logger,
 		service: service,
 		config:  cfg,
+		policy:  engine,
 	}
 }
 
 func (h handler) Register(router *mux.Router) {
-	router.HandleFunc(company, h.GetCompaniesListHandler).Methods(http.MethodGet)
-	router.HandleFunc(companyWithId, h.GetCompanyHandler).Methods(http.MethodGet)
-	router.HandleFunc(company, h.CreateCompanyHandler).Methods(http.MethodPost)
-	router.HandleFunc(companyWithId, h.UpdateCompanyHandler).Methods(http.MethodPut)
-	router.HandleFunc(companyWithId, h.DeleteCompanyHandler).Methods(http.MethodDelete)
+	router.Use(h.actorMiddleware)
+	router.Handle(company, h.authenticate(
+		userAccess(models.PermissionCompanyRead), h.GetCompaniesListHandler)).Methods(http.MethodGet)
+	router.Handle(companySearch, h.authenticate(
+		userAccess(models.PermissionCompanyRead), h.SearchCompaniesHandler)).Methods(http.MethodGet)
+	router.Handle(companyTrash, h.authenticate(
+		userAccess(models.PermissionCompanyDelete), h.GetTrashHandler)).Methods(http.MethodGet)
+	router.Handle(companyRestore, h.authenticate(
+		geoAccess(models.PermissionCompanyWrite, policy.OperationCompanyRestore),
+		h.RestoreCompanyHandler)).Methods(http.MethodPost)
+	router.Handle(companyHistory, h.authenticate(
+		userAccess(models.PermissionCompanyRead), h.GetCompanyHistoryHandler)).Methods(http.MethodGet)
+	router.Handle(audit, h.authenticate(
+		userAccess(models.PermissionAuditRead), h.GetAuditEventsHandler)).Methods(http.MethodGet)
+	router.Handle(auditVerify, h.authenticate(
+		userAccess(models.PermissionAuditRead), h.VerifyAuditLogHandler)).Methods(http.MethodGet)
+	router.Handle(companyWithId, h.authenticate(
+		userAccess(models.PermissionCompanyRead), h.GetCompanyHandler)).Methods(http.MethodGet)
+	router.Handle(countries, h.authenticate(
+		userAccess(models.PermissionCompanyRead), h.GetCountriesHandler)).Methods(http.MethodGet)
+	router.Handle(countryWithCode, h.authenticate(
+		userAccess(models.PermissionCompanyRead), h.GetCountryHandler)).Methods(http.MethodGet)
+	router.Handle(policyExplain, h.authenticate(
+		userAccess(models.PermissionPolicyRead), h.ExplainPolicyHandler)).Methods(http.MethodGet)
+	router.Handle(company, h.authenticate(
+		geoAccess(models.PermissionCompanyWrite, policy.OperationCompanyCreate),
+		h.CreateCompanyHandler)).Methods(http.MethodPost)
+	router.Handle(companyWithId, h.authenticate(
+		geoAccess(models.PermissionCompanyWrite, policy.OperationCompanyUpdate),
+		h.UpdateCompanyHandler)).Methods(http.MethodPut)
+	router.Handle(companyWithId, h.authenticate(
+		geoAccess(models.PermissionCompanyWrite, policy.OperationCompanyUpdate),
+		h.PatchCompanyHandler)).Methods(http.MethodPatch)
+	router.Handle(companyWithId, h.authenticate(
+		geoAccess(models.PermissionCompanyDelete, policy.OperationCompanyDelete),
+		h.DeleteCompanyHandler)).Methods(http.MethodDelete)
 	router.HandleFunc(users, h.CreateUser).Methods(http.MethodPost)
 	router.HandleFunc(usersLogin, h.LoginUser).Methods(http.MethodPost)
+	router.HandleFunc(usersRefresh, h.RefreshTokenHandler).Methods(http.MethodPost)
+	router.HandleFunc(jwks, h.JWKSHandler).Methods(http.MethodGet)
+	router.Handle(usersLogout, h.authenticate(userAccess(""), h.LogoutHandler)).Methods(http.MethodPost)
+	router.Handle(userRoles, h.authenticate(
+		userAccess(models.PermissionRoleManage), h.GetUserRolesHandler)).Methods(http.MethodGet)
+	router.Handle(userRole, h.authenticate(
+		userAccess(models.PermissionRoleManage), h.AssignRoleHandler)).Methods(http.MethodPut)
+	router.Handle(userRole, h.authenticate(
+		userAccess(models.PermissionRoleManage), h.RevokeRoleHandler)).Methods(http.MethodDelete)
+	router.Handle(apiKeys, h.authenticate(
+		userAccess(models.PermissionApiKeyManage), h.CreateApiKeyHandler)).Methods(http.MethodPost)
+	router.Handle(apiKeys, h.authenticate(
+		userAccess(models.PermissionApiKeyManage), h.GetApiKeysHandler)).Methods(http.MethodGet)
+	router.Handle(apiKeyWithId, h.authenticate(
+		userAccess(models.PermissionApiKeyManage), h.RevokeApiKeyHandler)).Methods(http.MethodDelete)
+
+	// The router runs no middleware for unmatched requests.
+	router.NotFoundHandler = requestid.Middleware(http.HandlerFunc(h.routeNotFound))
+	router.MethodNotAllowedHandler = requestid.Middleware(http.HandlerFunc(h.methodNotAllowed))
 }
 
 func (h handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	u := &models.UserRequest{}
-	err := json.NewDecoder(r.Body).Decode(u)
-	if err != nil {
-		h.logger.Entry.Error("wrong json format")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	v := validator.New()
-
-	if err := v.Struct(u); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		responseBody := uerrors.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: fmt.Sprintf("got wrong user data: %+v", err),
-		}
-		if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-			h.logger.Entry.Errorf("problems with encoding data: %+v", err)
-			w.WriteHeader(http.StatusBadRequest)
-		}
-		h.logger.Entry.Errorf("got wrong user data: %+v", err)
-		w.WriteHeader(http.StatusBadRequest)
+	if !h.decodeValid(w, r, u, "user") {
 		return
 	}
 
 	usr, err := h.service.Login(r.Context(), u)
 	if err != nil {
-		h.logger.Entry.Errorf("error with user login: %v", err)
-	}
-	hash, err := h.service.CreateToken(usr.Id)
-	if err != nil {
-		h.logger.Entry.Errorf("error with create token: %v", err)
+		h.writeError(w, r, fmt.Errorf("error with user login: %w", err))
+		return
 	}
-
-	accessTokenTTL, err := time.ParseDuration(h.config.Auth.AccessTokenTTL)
+	refreshToken, err := h.service.CreateRefreshToken(r.Context(), usr.Id)
 	if err != nil {
-		h.logger.Entry.Errorf("Error with access token ttl: %s", err)
-	}
-
-	w.Header().Add(headerXExpiresAfter, time.Now().Local().Add(accessTokenTTL).String())
-	w.Header().Add(headerContentType, headerValueContentType)
-	w.WriteHeader(http.StatusOK)
-	responseBody := models.UserLoginResponse{
-		Hash: hash,
-	}
-	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-		h.logger.Entry.Errorf("Failed to login user: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, fmt.Errorf("error with create refresh token: %w", err))
 		return
 	}
+	h.writeTokens(w, r, usr, refreshToken)
 }
 
 func (h handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	u := &models.UserRequest{}
-	err := json.NewDecoder(r.Body).Decode(u)
-	if err != nil {
-		h.logger.Entry.Error("wrong json format")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	v := validator.New()
-
-	if err := v.Struct(u); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		responseBody := uerrors.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: fmt.Sprintf("got wrong user data: %+v", err),
-		}
-		if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-			h.logger.Entry.Errorf("problems with encoding data: %+v", err)
-			w.WriteHeader(http.StatusBadRequest)
-		}
-		h.logger.Entry.Errorf("got wrong user data: %+v", err)
-		w.WriteHeader(http.StatusBadRequest)
+	if !h.decodeValid(w, r, u, "user") {
 		return
 	}
 
 	uID, err := h.service.CreateUser(r.Context(), *u)
 	if err != nil {
-		h.logger.Entry.Errorf("can't create user: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, fmt.Errorf("can't create user: %w", err))
 		return
 	}
 	// @todo refactor to service
@@ -144,8 +165,7 @@ func (h handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-		h.logger.Entry.Errorf("can't create user: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.logger.Ctx(r.Context()).Errorf("can't create user: %+v", err)
 		return
 	}
 }
@@ -154,100 +174,80 @@ func (h handler) GetCompanyHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	cId, _ := strconv.Atoi(params["id"])
 	company, err := h.service.GetCompany(r.Context(), cId)
-
 	if err != nil {
-		h.logger.Entry.Errorf("can't get company: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, fmt.Errorf("can't get company: %w", err))
+		return
+	}
+
+	etag := formatETag(company.Version)
+	w.Header().Set(headerETag, etag)
+	if matchesIfNoneMatch(r.Header.Get(headerIfNoneMatch), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.Header().Add(headerContentType, headerValueContentType)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(company); err != nil {
-		h.logger.Entry.Errorf("can't get companies list: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.logger.Ctx(r.Context()).Errorf("can't get company: %+v", err)
 		return
 	}
 }
 
 func (h handler) GetCompaniesListHandler(w http.ResponseWriter, r *http.Request) {
-	companies, err := h.service.GetCompanies(r.Context())
+	filter, err := parseCompanyFilter(r.URL.Query())
+	if err == nil {
+		err = validator.New().Struct(filter)
+	}
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong list params: %+v", err))
+		return
+	}
+
+	companies, err := h.service.GetCompanies(r.Context(), filter)
 	if err != nil {
-		h.logger.Entry.Errorf("can't get companies: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, fmt.Errorf("can't get companies: %w", err))
 		return
 	}
 
 	w.Header().Add(headerContentType, headerValueContentType)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(companies); err != nil {
-		h.logger.Entry.Errorf("can't get companies list: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.logger.Ctx(r.Context()).Errorf("can't get companies list: %+v", err)
 		return
 	}
 }
 
-func (h handler) CreateCompanyHandler(w http.ResponseWriter, r *http.Request) {
-	var token string
-
-	if len(r.Header.Get("Authorization")) > 0 {
-		_, err := h.service.CheckAuth(r.Header.Get("Authorization"))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-	} else if ok, country := ipapi.IsAllowed(r.RemoteAddr); ok {
-		hash, err := h.service.CreateToken(country)
-		if err != nil {
-			h.logger.Entry.Errorf("error with create token: %v", err)
-		}
-
-		accessTokenTTL, err := time.ParseDuration(h.config.Auth.AccessTokenTTL)
-		if err != nil {
-			h.logger.Entry.Errorf("Error with access token ttl: %s", err)
-		}
-
-		w.Header().Add(headerXExpiresAfter, time.Now().Local().Add(accessTokenTTL).String())
-		token = hash
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+func (h handler) SearchCompaniesHandler(w http.ResponseWriter, r *http.Request) {
+	query, limit, err := parseSearchParams(r.URL.Query())
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong search params: %+v", err))
 		return
 	}
 
-	companyData := &models.CompanyCreateRequest{}
-	err := json.NewDecoder(r.Body).Decode(companyData)
+	companies, err := h.service.SearchCompanies(r.Context(), query, limit)
 	if err != nil {
-		h.logger.Entry.Error("wrong json format")
-		w.WriteHeader(http.StatusBadRequest)
+		h.writeError(w, r, fmt.Errorf("can't search companies: %w", err))
 		return
 	}
 
-	v := validator.New()
-	if err := v.Struct(companyData); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		responseBody := uerrors.ErrorResponse{
-			Code:    http.StatusBadRequest,
-			Message: fmt.Sprintf("got wrong user data: %+v", err),
-		}
-		if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-			h.logger.Entry.Errorf("problems with encoding data: %+v", err)
-			w.WriteHeader(http.StatusBadRequest)
-		}
-		h.logger.Entry.Errorf("got wrong user data: %+v", err)
-		w.WriteHeader(http.StatusBadRequest)
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(CompanySearchResponse{Companies: companies}); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't search companies: %+v", err)
 		return
 	}
-	countryId, err := h.service.CreateCountry(r.Context(), *companyData)
-	if err != nil {
-		h.logger.Entry.Errorf("can't create country: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+}
+
+func (h handler) CreateCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	companyData := &models.CompanyCreateRequest{}
+	if !h.decodeValid(w, r, companyData, "company") {
 		return
 	}
 
-	companyId, err := h.service.CreateCompany(r.Context(), *companyData, countryId)
+	companyId, err := h.service.CreateCompany(r.Context(), *companyData)
 	if err != nil {
-		h.logger.Entry.Errorf("can't create company: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeCompanyError(w, r, fmt.Errorf("can't create company: %w", err))
 		return
 	}
 
@@ -256,12 +256,11 @@ func (h handler) CreateCompanyHandler(w http.ResponseWriter, r *http.Request) {
 	responseBody := CompanyCreateResponse{
 		Id:   companyId,
 		Name: companyData.Name,
-		Hash: token,
+		Hash: issuedToken(r.Context()),
 	}
 
 	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
-		h.logger.Entry.Errorf("can't create user: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.logger.Ctx(r.Context()).Errorf("can't create company: %+v", err)
 		return
 	}
 }
@@ -271,62 +270,183 @@ func (h handler) UpdateCompanyHandler(w http.ResponseWriter, r *http.Request) {
 	cId, _ := strconv.Atoi(params["id"])
 
 	companyData := &models.CompanyUpdateRequest{}
-	err := json.NewDecoder(r.Body).Decode(companyData)
+	if !h.decodeValid(w, r, companyData, "company") {
+		return
+	}
+
+	version, ok := h.ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	newVersion, err := h.service.UpdateCompany(r.Context(), cId, companyData, version)
 	if err != nil {
-		h.logger.Entry.Error("wrong json format")
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeCompanyError(w, r, fmt.Errorf("can't update company: %w", err))
+		return
+	}
+
+	w.Header().Set(headerETag, formatETag(newVersion))
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h handler) PatchCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	cId, _ := strconv.Atoi(params["id"])
+
+	version, ok := h.ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(headerContentType))
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "can't read patch document")
+		return
+	}
+
+	newVersion, err := h.service.PatchCompany(r.Context(), cId, models.CompanyPatch{ContentType: mediaType, Body: body},
+		version)
+	if errors.Is(err, uerrors.ErrUnsupportedPatch) {
+		w.Header().Add(headerAcceptPatch, acceptPatchValue)
+		h.writeErrorStatus(w, r, http.StatusUnsupportedMediaType, err)
 		return
 	}
-	err = h.service.UpdateCompany(r.Context(), cId, companyData)
 	if err != nil {
-		h.logger.Entry.Errorf("can't update company: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeCompanyError(w, r, fmt.Errorf("can't patch company: %w", err))
 		return
 	}
 
+	w.Header().Set(headerETag, formatETag(newVersion))
 	w.Header().Add(headerContentType, headerValueContentType)
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h handler) DeleteCompanyHandler(w http.ResponseWriter, r *http.Request) {
-	var token string
+	params := mux.Vars(r)
+	cId, _ := strconv.Atoi(params["id"])
+
+	version, ok := h.ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
 
-	if len(r.Header.Get("Authorization")) > 0 {
-		_, err := h.service.CheckAuth(r.Header.Get("Authorization"))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+	var err error
+	if r.URL.Query().Get(paramHard) == "true" {
+		if !h.can(r, models.PermissionCompanyPurge) {
+			h.writeError(w, r, uerrors.ErrForbidden.WithDetail("hard delete requires %s permission",
+				models.PermissionCompanyPurge))
 			return
 		}
-	} else if ok, country := ipapi.IsAllowed(r.RemoteAddr); ok {
-		hash, err := h.service.CreateToken(country)
-		if err != nil {
-			h.logger.Entry.Errorf("error with create token: %v", err)
-		}
+		err = h.service.PurgeCompany(r.Context(), cId, version)
+	} else {
+		err = h.service.DeleteCompany(r.Context(), cId, version)
+	}
+	if err != nil {
+		h.writeCompanyError(w, r, fmt.Errorf("can't delete company: %w", err))
+		return
+	}
 
-		accessTokenTTL, err := time.ParseDuration(h.config.Auth.AccessTokenTTL)
-		if err != nil {
-			h.logger.Entry.Errorf("Error with access token ttl: %s", err)
-		}
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+}
 
-		w.Header().Add(headerXExpiresAfter, time.Now().Local().Add(accessTokenTTL).String())
-		token = hash
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+func (h handler) GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseCompanyFilter(r.URL.Query())
+	if err == nil {
+		err = validator.New().Struct(filter)
+	}
+	if err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong list params: %+v", err))
+		return
+	}
+	filter.Trashed = true
+
+	companies, err := h.service.GetCompanies(r.Context(), filter)
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't get deleted companies: %w", err))
 		return
 	}
 
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(companies); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't get deleted companies: %+v", err)
+		return
+	}
+}
+
+func (h handler) RestoreCompanyHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	cId, _ := strconv.Atoi(params["id"])
 
-	err := h.service.DeleteCompany(r.Context(), cId)
-
+	version, err := h.service.RestoreCompany(r.Context(), cId)
 	if err != nil {
-		h.logger.Entry.Errorf("can't delete company: %+v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.writeError(w, r, fmt.Errorf("can't restore company: %w", err))
 		return
 	}
 
+	w.Header().Set(headerETag, formatETag(version))
 	w.Header().Add(headerContentType, headerValueContentType)
-	w.Header().Add(headerAuthorization, token)
 	w.WriteHeader(http.StatusOK)
 }
+
+// actorMiddleware stores an anonymous actor with the client address resolved by
+// clientip, or the peer address without it, in the request context. Handlers requiring authorization replace it.
+func (h handler) actorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := models.Actor{Type: models.ActorTypeAnonymous, Ip: r.RemoteAddr}
+		if ip := clientip.FromContext(r.Context()); ip != nil {
+			actor.Ip = ip.String()
+		} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			actor.Ip = host
+		}
+		next.ServeHTTP(w, r.WithContext(models.WithActor(r.Context(), actor)))
+	})
+}
+
+// ifMatchVersion returns the company version required by the If-Match header.
+// It writes the error response and returns false if the header is missing in
+// strict mode or can't be satisfied.
+func (h handler) ifMatchVersion(w http.ResponseWriter, r *http.Request) (version int, ok bool) {
+	version, err := parseIfMatch(r.Header.Get(headerIfMatch), h.config.Concurrency.Strict)
+	switch {
+	case err == nil:
+		return version, true
+	case errors.Is(err, errMissingIfMatch):
+		h.writeProblem(w, r, http.StatusPreconditionRequired, codePreconditionRequired, err.Error())
+	case errors.Is(err, errMultipleETags):
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+	default:
+		h.writeProblem(w, r, http.StatusPreconditionFailed, uerrors.ErrVersionMismatch.Code, err.Error())
+	}
+	return 0, false
+}
+
+// writeCompanyError writes the error of a company write. A version mismatch fails the If-Match
+// precondition and an unknown country is invalid company data.
+func (h handler) writeCompanyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, uerrors.ErrVersionMismatch):
+		h.writeProblem(w, r, http.StatusPreconditionFailed, uerrors.ErrVersionMismatch.Code, errETagMismatch.Error())
+	case errors.Is(err, uerrors.ErrCountryNotFound):
+		h.writeErrorStatus(w, r, http.StatusUnprocessableEntity, err)
+	default:
+		h.writeError(w, r, err)
+	}
+}
+
+// decodeValid decodes the JSON body of the request into v and validates it. It writes
+// a 400 problem and returns false if the body is malformed or invalid.
+func (h handler) decodeValid(w http.ResponseWriter, r *http.Request, v interface{}, what string) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong %s json: %v", what, err))
+		return false
+	}
+	if err := validator.New().Struct(v); err != nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong %s data: %v", what, err))
+		return false
+	}
+	return true
+}
@@ -2,20 +2,38 @@ package company_test
 
 import (
 	"context"
+	"fmt"
 	"github.com/dkischenko/xm_app/internal/company"
 	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
 	"github.com/dkischenko/xm_app/internal/company/models"
 	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/clientip"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
 	"github.com/dkischenko/xm_app/pkg/logger"
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 )
 
+// newPolicy returns the default access policy checking countries with the locator.
+func newPolicy(t *testing.T, locator geo.GeoLocator, failOpen bool) *policy.Engine {
+	l, _ := logger.GetLogger()
+	engine, err := policy.New(config.Policy{}, locator, failOpen, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
 func TestHandler_RegisterOk(t *testing.T) {
 	t.Run("[Ok] Register handlers", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
@@ -41,10 +59,448 @@ func TestHandler_RegisterOk(t *testing.T) {
 		l, _ := logger.GetLogger()
 		mockService := mock_company.NewMockIService(ctrl)
 		mockService.EXPECT().CreateUser(ctx, uDTO).Return(getUUID, nil).AnyTimes()
-		h := company.NewHandler(l, mockService, cfg)
+		h := company.NewHandler(l, mockService, cfg, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
 		router := mux.NewRouter()
 		h.Register(router)
 		h.CreateUser(w, req)
 		assert.Equal(t, w.Code, http.StatusOK)
 	})
 }
+
+func TestHandler_GetCompaniesListBadParams(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{name: "Limit is not a number", query: "limit=ten"},
+		{name: "Limit out of range", query: "limit=1000"},
+		{name: "Unknown sort field", query: "sort=phone"},
+		{name: "Broken cursor", query: "cursor=@@@"},
+		{name: "Cursor of another sort", query: "sort=name&cursor=" + models.CompanyCursor{Sort: "id", Id: 1}.Encode()},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/companies?"+tcase.query, nil)
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			h.GetCompaniesListHandler(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetCompaniesList(t *testing.T) {
+	t.Run("[Ok] Get companies list with filter", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		req := httptest.NewRequest(http.MethodGet,
+			"/v1/companies?limit=5&name=Ac&country=Cyprus&created_from=1650995663&sort=-updated_at", nil)
+		w := httptest.NewRecorder()
+		l, _ := logger.GetLogger()
+		mockService := mock_company.NewMockIService(ctrl)
+		mockService.EXPECT().GetCompanies(gomock.Any(), models.CompanyFilter{
+			Limit:       5,
+			NamePrefix:  "Ac",
+			Country:     "Cyprus",
+			CreatedFrom: 1650995663,
+			Sort:        models.SortByUpdatedAt,
+			Desc:        true,
+		}).Return(models.CompanyPage{Companies: []models.Company{}}, nil)
+		h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+		h.GetCompaniesListHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"companies":[],"next_cursor":""}`, w.Body.String())
+	})
+}
+
+func TestHandler_SearchCompanies(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     string
+		anonymous bool
+		status    int
+	}{
+		{name: "[Ok] Search", query: "q=acm&limit=5", status: http.StatusOK},
+		{name: "[Err] Empty query", query: "q=", status: http.StatusBadRequest},
+		{name: "[Err] Query too short", query: "q=a", status: http.StatusBadRequest},
+		{name: "[Err] Wrong limit", query: "q=acme&limit=0", status: http.StatusBadRequest},
+		{name: "[Err] Anonymous caller", query: "q=acm&limit=5", anonymous: true, status: http.StatusUnauthorized},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/companies/search?"+tcase.query, nil)
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			if !tcase.anonymous {
+				req.Header.Set("Authorization", "Bearer token")
+				mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleViewer}}, nil)
+			}
+			mockService.EXPECT().SearchCompanies(gomock.Any(), "acm", 5).Return([]models.CompanySearchResult{
+				{Company: models.Company{Id: 1, Name: "Acme"}, Score: 0.75},
+			}, nil).AnyTimes()
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			if tcase.status == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"score":0.75`)
+			}
+		})
+	}
+}
+
+func TestHandler_UpdateCompanyMissingFields(t *testing.T) {
+	t.Run("[Err] PUT requires a full representation", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		req := httptest.NewRequest(http.MethodPut, "/v1/companies/1", strings.NewReader(`{"name": "Renamed"}`))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		l, _ := logger.GetLogger()
+		mockService := mock_company.NewMockIService(ctrl)
+		mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}}, nil)
+		h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+		router := mux.NewRouter()
+		h.Register(router)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_PatchCompany(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		serviceErr  error
+		status      int
+	}{
+		{name: "[Ok] Merge patch", contentType: "application/merge-patch+json; charset=utf-8", status: http.StatusOK},
+		{name: "[Err] Unsupported media type", contentType: "text/plain", serviceErr: uerrors.ErrUnsupportedPatch,
+			status: http.StatusUnsupportedMediaType},
+		{name: "[Err] Invalid result", contentType: "application/merge-patch+json", serviceErr: uerrors.ErrValidateCompany,
+			status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			body := `{"name": "Renamed"}`
+			req := httptest.NewRequest(http.MethodPatch, "/v1/companies/1", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Content-Type", tcase.contentType)
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}}, nil)
+			mediaType := strings.Split(tcase.contentType, ";")[0]
+			mockService.EXPECT().PatchCompany(gomock.Any(), 1, models.CompanyPatch{
+				ContentType: mediaType,
+				Body:        []byte(body),
+			}, 0).Return(2, tcase.serviceErr)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetCompanyConditional(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{name: "[Ok] No condition", status: http.StatusOK},
+		{name: "[Ok] Stale tag", ifNoneMatch: `"2"`, status: http.StatusOK},
+		{name: "[Ok] Not modified", ifNoneMatch: `"1", W/"3"`, status: http.StatusNotModified},
+		{name: "[Ok] Not modified weak", ifNoneMatch: `W/"3"`, status: http.StatusNotModified},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/companies/1", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			if tcase.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tcase.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleViewer}}, nil)
+			mockService.EXPECT().GetCompany(gomock.Any(), 1).Return(models.Company{Id: 1, Version: 3}, nil)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestHandler_UpdateCompanyIfMatch(t *testing.T) {
+	payload := `{"name": "Test", "code": 12345, "country": "CY", "website": "https://example.com", "phone": "+380662342437"}`
+	testCases := []struct {
+		name       string
+		strict     bool
+		ifMatch    string
+		version    int
+		serviceErr error
+		status     int
+	}{
+		{name: "[Ok] Without If-Match", status: http.StatusOK},
+		{name: "[Ok] Any version", ifMatch: "*", strict: true, status: http.StatusOK},
+		{name: "[Ok] Matching version", ifMatch: `"4"`, version: 4, status: http.StatusOK},
+		{name: "[Err] Missing If-Match in strict mode", strict: true, status: http.StatusPreconditionRequired},
+		{name: "[Err] Weak tag", ifMatch: `W/"4"`, status: http.StatusPreconditionFailed},
+		{name: "[Err] Version mismatch", ifMatch: `"3"`, version: 3,
+			serviceErr: fmt.Errorf("error occurs: %w", uerrors.ErrVersionMismatch), status: http.StatusPreconditionFailed},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/companies/1", strings.NewReader(payload))
+			req.Header.Set("Authorization", "Bearer token")
+			if tcase.ifMatch != "" {
+				req.Header.Set("If-Match", tcase.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			cfg.Concurrency.Strict = tcase.strict
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}}, nil)
+			mockService.EXPECT().UpdateCompany(gomock.Any(), 1, gomock.Any(), tcase.version).
+				Return(tcase.version+1, tcase.serviceErr).MaxTimes(1)
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			if tcase.status == http.StatusOK {
+				assert.Equal(t, fmt.Sprintf(`"%d"`, tcase.version+1), w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestHandler_HardDeleteCompany(t *testing.T) {
+	testCases := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{name: "[Ok] Admin purges company", roles: []string{models.RoleAdmin}, status: http.StatusOK},
+		{name: "[Err] Editor", roles: []string{models.RoleEditor}, status: http.StatusForbidden},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodDelete, "/v1/companies/1?hard=true", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: tcase.roles}, nil)
+			mockService.EXPECT().PurgeCompany(gomock.Any(), 1, 0).Return(nil).MaxTimes(1)
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_RestoreCompany(t *testing.T) {
+	testCases := []struct {
+		name       string
+		serviceErr error
+		status     int
+	}{
+		{name: "[Ok] Restore", status: http.StatusOK},
+		{name: "[Err] Not deleted", serviceErr: fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotDeleted),
+			status: http.StatusConflict},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/companies/1/restore", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}}, nil)
+			mockService.EXPECT().RestoreCompany(gomock.Any(), 1).Return(3, tcase.serviceErr)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetTrash(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		roles  []string
+		status int
+	}{
+		{name: "[Ok] Trash lists deleted companies", header: "Bearer token", roles: []string{models.RoleEditor},
+			status: http.StatusOK},
+		{name: "[Err] Anonymous caller", status: http.StatusUnauthorized},
+		{name: "[Err] Viewer", header: "Bearer token", roles: []string{models.RoleViewer}, status: http.StatusForbidden},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/companies/trash?limit=5", nil)
+			if tcase.header != "" {
+				req.Header.Set("Authorization", tcase.header)
+			}
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			if tcase.header != "" {
+				mockService.EXPECT().CheckAuth(gomock.Any(), tcase.header).Return(auth.Claims{
+					Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: tcase.roles}, nil)
+			}
+			if tcase.status == http.StatusOK {
+				mockService.EXPECT().GetCompanies(gomock.Any(), models.CompanyFilter{
+					Limit:   5,
+					Sort:    models.SortById,
+					Trashed: true,
+				}).Return(models.CompanyPage{Companies: []models.Company{}}, nil)
+			}
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_DeleteCompanyGeo(t *testing.T) {
+	testCases := []struct {
+		name       string
+		location   geo.Location
+		geoErr     error
+		failPolicy string
+		token      string
+		status     int
+	}{
+		{name: "[Ok] Allowed country", location: geo.Location{CountryCode: "CY", CountryName: "Cyprus"},
+			token: "Cyprus", status: http.StatusOK},
+		{name: "[Ok] Provider down, fail open", geoErr: geo.ErrUnavailable, failPolicy: config.GeoFailOpen,
+			token: "31.153.0.1", status: http.StatusOK},
+		{name: "[Err] Provider down, fail closed", geoErr: geo.ErrUnavailable, failPolicy: config.GeoFailClosed,
+			status: http.StatusUnauthorized},
+		{name: "[Err] Other country", location: geo.Location{CountryCode: "GR", CountryName: "Greece"},
+			status: http.StatusUnauthorized},
+		{name: "[Err] Unknown location", geoErr: geo.ErrNotFound, status: http.StatusUnauthorized},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodDelete, "/v1/companies/1", nil)
+			req.RemoteAddr = "31.153.0.1:5000"
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			cfg.Auth.AccessTokenTTL = "1h"
+			mockLocator := mock_geo.NewMockGeoLocator(ctrl)
+			mockLocator.EXPECT().Locate(gomock.Any(), net.ParseIP("31.153.0.1")).Return(tcase.location, tcase.geoErr).
+				MinTimes(1)
+			mockService := mock_company.NewMockIService(ctrl)
+			if tcase.token != "" {
+				// Without a country the token is bound to the address.
+				claims := auth.Claims{Principal: auth.Geo(tcase.token), Scope: policy.Operations,
+					Country: tcase.location.CountryCode}
+				if claims.Country == "" {
+					claims.IP = "31.153.0.1"
+				}
+				mockService.EXPECT().CreateToken(claims).Return("token", nil)
+			}
+			mockService.EXPECT().DeleteCompany(gomock.Any(), 1, 0).Return(nil).MaxTimes(1)
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mockLocator, tcase.failPolicy == config.GeoFailOpen))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+		})
+	}
+}
+
+func TestHandler_DeleteCompanyBehindProxy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/companies/1", nil)
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("X-Forwarded-For", "31.153.0.1")
+	w := httptest.NewRecorder()
+	l, _ := logger.GetLogger()
+	cfg := &config.Config{}
+	cfg.Auth.AccessTokenTTL = "1h"
+	mockLocator := mock_geo.NewMockGeoLocator(ctrl)
+	mockLocator.EXPECT().Locate(gomock.Any(), net.ParseIP("31.153.0.1")).
+		Return(geo.Location{CountryCode: "CY", CountryName: "Cyprus"}, nil).MinTimes(1)
+	mockService := mock_company.NewMockIService(ctrl)
+	mockService.EXPECT().CreateToken(auth.Claims{Principal: auth.Geo("Cyprus"), Scope: policy.Operations, Country: "CY"}).
+		Return("token", nil)
+	mockService.EXPECT().DeleteCompany(gomock.Any(), 1, 0).
+		DoAndReturn(func(ctx context.Context, id int, version int) error {
+			assert.Equal(t, "31.153.0.1", models.ActorFromContext(ctx).Ip)
+			return nil
+		})
+	resolver, err := clientip.NewResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	h := company.NewHandler(l, mockService, cfg, newPolicy(t, mockLocator, false))
+	router := mux.NewRouter()
+	router.Use(resolver.Middleware)
+	h.Register(router)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
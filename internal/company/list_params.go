@@ -0,0 +1,122 @@
+package company
+
+import (
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	paramLimit       = "limit"
+	paramCursor      = "cursor"
+	paramName        = "name"
+	paramCode        = "code"
+	paramCountry     = "country"
+	paramCreatedFrom = "created_from"
+	paramCreatedTo   = "created_to"
+	paramUpdatedFrom = "updated_from"
+	paramUpdatedTo   = "updated_to"
+	paramSort        = "sort"
+	paramQuery       = "q"
+	paramHard        = "hard"
+	paramEntity      = "entity"
+	paramId          = "id"
+	paramRegion      = "region"
+)
+
+// parseCompanyFilter builds the list filter from the query string.
+// Sort accepts a field name, optionally prefixed with "-" for descending order.
+func parseCompanyFilter(query url.Values) (filter models.CompanyFilter, err error) {
+	filter = models.CompanyFilter{
+		Limit:      models.DefaultCompaniesLimit,
+		Sort:       models.SortById,
+		NamePrefix: query.Get(paramName),
+		Country:    query.Get(paramCountry),
+	}
+
+	if v := query.Get(paramLimit); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("%s must be an integer", paramLimit)
+		}
+	}
+	if v := query.Get(paramCode); v != "" {
+		if filter.Code, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("%s must be an integer", paramCode)
+		}
+	}
+
+	ranges := map[string]*int64{
+		paramCreatedFrom: &filter.CreatedFrom,
+		paramCreatedTo:   &filter.CreatedTo,
+		paramUpdatedFrom: &filter.UpdatedFrom,
+		paramUpdatedTo:   &filter.UpdatedTo,
+	}
+	for param, dst := range ranges {
+		if v := query.Get(param); v != "" {
+			if *dst, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return filter, fmt.Errorf("%s must be a unix timestamp", param)
+			}
+		}
+	}
+
+	if v := query.Get(paramSort); v != "" {
+		filter.Desc = strings.HasPrefix(v, "-")
+		filter.Sort = strings.TrimPrefix(v, "-")
+	}
+
+	if v := query.Get(paramCursor); v != "" {
+		if filter.Cursor, err = models.DecodeCompanyCursor(v); err != nil {
+			return filter, err
+		}
+		if !filter.Cursor.Matches(filter) {
+			return filter, fmt.Errorf("%w: cursor was issued for another sort order", models.ErrInvalidCursor)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseSearchParams returns the search query and the page limit from the query string.
+func parseSearchParams(query url.Values) (q string, limit int, err error) {
+	q = strings.TrimSpace(query.Get(paramQuery))
+	if n := len([]rune(q)); n < models.MinSearchQueryLength || n > models.MaxSearchQueryLength {
+		return "", 0, fmt.Errorf("%s must be from %d to %d characters long", paramQuery,
+			models.MinSearchQueryLength, models.MaxSearchQueryLength)
+	}
+
+	limit = models.DefaultCompaniesLimit
+	if v := query.Get(paramLimit); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return "", 0, fmt.Errorf("%s must be an integer", paramLimit)
+		}
+	}
+	if limit < 1 || limit > models.MaxCompaniesLimit {
+		return "", 0, fmt.Errorf("%s must be from 1 to %d", paramLimit, models.MaxCompaniesLimit)
+	}
+
+	return q, limit, nil
+}
+
+// parseAuditFilter builds the audit log filter from the query string.
+func parseAuditFilter(query url.Values) (filter models.AuditFilter, err error) {
+	filter = models.AuditFilter{
+		Limit:    models.DefaultCompaniesLimit,
+		Entity:   query.Get(paramEntity),
+		EntityId: query.Get(paramId),
+	}
+
+	if v := query.Get(paramLimit); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("%s must be an integer", paramLimit)
+		}
+	}
+	if v := query.Get(paramCursor); v != "" {
+		if filter.Cursor, err = models.DecodeAuditCursor(v); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
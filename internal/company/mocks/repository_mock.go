@@ -35,6 +35,20 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddUserRole mocks base method.
+func (m *MockRepository) AddUserRole(ctx context.Context, userId, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUserRole", ctx, userId, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddUserRole indicates an expected call of AddUserRole.
+func (mr *MockRepositoryMockRecorder) AddUserRole(ctx, userId, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserRole", reflect.TypeOf((*MockRepository)(nil).AddUserRole), ctx, userId, role)
+}
+
 // Create mocks base method.
 func (m *MockRepository) Create(ctx context.Context, company models.CompanyCreateRequest, countryId int) (int, error) {
 	m.ctrl.T.Helper()
@@ -50,19 +64,48 @@ func (mr *MockRepositoryMockRecorder) Create(ctx, company, countryId interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), ctx, company, countryId)
 }
 
-// CreateCountry mocks base method.
-func (m *MockRepository) CreateCountry(ctx context.Context, company models.CompanyCreateRequest) (int, error) {
+// CreateApiKey mocks base method.
+func (m *MockRepository) CreateApiKey(ctx context.Context, key *models.ApiKey) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCountry", ctx, company)
-	ret0, _ := ret[0].(int)
+	ret := m.ctrl.Call(m, "CreateApiKey", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateApiKey indicates an expected call of CreateApiKey.
+func (mr *MockRepositoryMockRecorder) CreateApiKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApiKey", reflect.TypeOf((*MockRepository)(nil).CreateApiKey), ctx, key)
+}
+
+// CreateAuditEvent mocks base method.
+func (m *MockRepository) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuditEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAuditEvent indicates an expected call of CreateAuditEvent.
+func (mr *MockRepositoryMockRecorder) CreateAuditEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditEvent", reflect.TypeOf((*MockRepository)(nil).CreateAuditEvent), ctx, event)
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockRepository) CreateRefreshToken(ctx context.Context, tokenHash, familyId, userId string, expiresAt int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, tokenHash, familyId, userId, expiresAt)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateCountry indicates an expected call of CreateCountry.
-func (mr *MockRepositoryMockRecorder) CreateCountry(ctx, company interface{}) *gomock.Call {
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockRepositoryMockRecorder) CreateRefreshToken(ctx, tokenHash, familyId, userId, expiresAt interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCountry", reflect.TypeOf((*MockRepository)(nil).CreateCountry), ctx, company)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockRepository)(nil).CreateRefreshToken), ctx, tokenHash, familyId, userId, expiresAt)
 }
 
 // CreateUser mocks base method.
@@ -81,17 +124,47 @@ func (mr *MockRepositoryMockRecorder) CreateUser(ctx, user interface{}) *gomock.
 }
 
 // Delete mocks base method.
-func (m *MockRepository) Delete(ctx context.Context, companyId int) error {
+func (m *MockRepository) Delete(ctx context.Context, companyId, version int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", ctx, companyId)
+	ret := m.ctrl.Call(m, "Delete", ctx, companyId, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockRepositoryMockRecorder) Delete(ctx, companyId interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Delete(ctx, companyId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), ctx, companyId, version)
+}
+
+// FindApiKey mocks base method.
+func (m *MockRepository) FindApiKey(ctx context.Context, prefix string) (models.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindApiKey", ctx, prefix)
+	ret0, _ := ret[0].(models.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindApiKey indicates an expected call of FindApiKey.
+func (mr *MockRepositoryMockRecorder) FindApiKey(ctx, prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindApiKey", reflect.TypeOf((*MockRepository)(nil).FindApiKey), ctx, prefix)
+}
+
+// FindCountry mocks base method.
+func (m *MockRepository) FindCountry(ctx context.Context, ref string) (models.Country, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindCountry", ctx, ref)
+	ret0, _ := ret[0].(models.Country)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindCountry indicates an expected call of FindCountry.
+func (mr *MockRepositoryMockRecorder) FindCountry(ctx, ref interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), ctx, companyId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindCountry", reflect.TypeOf((*MockRepository)(nil).FindCountry), ctx, ref)
 }
 
 // FindOneUser mocks base method.
@@ -109,6 +182,81 @@ func (mr *MockRepositoryMockRecorder) FindOneUser(ctx, name interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneUser", reflect.TypeOf((*MockRepository)(nil).FindOneUser), ctx, name)
 }
 
+// FindRefreshToken mocks base method.
+func (m *MockRepository) FindRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRefreshToken", ctx, tokenHash)
+	ret0, _ := ret[0].(models.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRefreshToken indicates an expected call of FindRefreshToken.
+func (mr *MockRepositoryMockRecorder) FindRefreshToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRefreshToken", reflect.TypeOf((*MockRepository)(nil).FindRefreshToken), ctx, tokenHash)
+}
+
+// GetApiKey mocks base method.
+func (m *MockRepository) GetApiKey(ctx context.Context, id string) (models.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKey", ctx, id)
+	ret0, _ := ret[0].(models.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKey indicates an expected call of GetApiKey.
+func (mr *MockRepositoryMockRecorder) GetApiKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKey", reflect.TypeOf((*MockRepository)(nil).GetApiKey), ctx, id)
+}
+
+// GetApiKeys mocks base method.
+func (m *MockRepository) GetApiKeys(ctx context.Context) ([]models.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKeys", ctx)
+	ret0, _ := ret[0].([]models.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKeys indicates an expected call of GetApiKeys.
+func (mr *MockRepositoryMockRecorder) GetApiKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKeys", reflect.TypeOf((*MockRepository)(nil).GetApiKeys), ctx)
+}
+
+// GetAuditChain mocks base method.
+func (m *MockRepository) GetAuditChain(ctx context.Context, afterId int64, limit int) ([]models.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditChain", ctx, afterId, limit)
+	ret0, _ := ret[0].([]models.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditChain indicates an expected call of GetAuditChain.
+func (mr *MockRepositoryMockRecorder) GetAuditChain(ctx, afterId, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditChain", reflect.TypeOf((*MockRepository)(nil).GetAuditChain), ctx, afterId, limit)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockRepository) GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, filter)
+	ret0, _ := ret[0].([]models.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockRepositoryMockRecorder) GetAuditEvents(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockRepository)(nil).GetAuditEvents), ctx, filter)
+}
+
 // GetCompany mocks base method.
 func (m *MockRepository) GetCompany(ctx context.Context, companyId int) (models.Company, error) {
 	m.ctrl.T.Helper()
@@ -124,31 +272,220 @@ func (mr *MockRepositoryMockRecorder) GetCompany(ctx, companyId interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompany", reflect.TypeOf((*MockRepository)(nil).GetCompany), ctx, companyId)
 }
 
+// GetCountries mocks base method.
+func (m *MockRepository) GetCountries(ctx context.Context, region string) ([]models.Country, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCountries", ctx, region)
+	ret0, _ := ret[0].([]models.Country)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCountries indicates an expected call of GetCountries.
+func (mr *MockRepositoryMockRecorder) GetCountries(ctx, region interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountries", reflect.TypeOf((*MockRepository)(nil).GetCountries), ctx, region)
+}
+
 // GetList mocks base method.
-func (m *MockRepository) GetList(ctx context.Context) ([]models.Company, error) {
+func (m *MockRepository) GetList(ctx context.Context, filter models.CompanyFilter) ([]models.Company, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetList", ctx)
+	ret := m.ctrl.Call(m, "GetList", ctx, filter)
 	ret0, _ := ret[0].([]models.Company)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetList indicates an expected call of GetList.
-func (mr *MockRepositoryMockRecorder) GetList(ctx interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetList(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockRepository)(nil).GetList), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockRepository)(nil).GetList), ctx, filter)
 }
 
-// Update mocks base method.
-func (m *MockRepository) Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest) error {
+// GetUserRoles mocks base method.
+func (m *MockRepository) GetUserRoles(ctx context.Context, userId string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRoles", ctx, userId)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRoles indicates an expected call of GetUserRoles.
+func (mr *MockRepositoryMockRecorder) GetUserRoles(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRoles", reflect.TypeOf((*MockRepository)(nil).GetUserRoles), ctx, userId)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", ctx, jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockRepositoryMockRecorder) IsTokenRevoked(ctx, jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockRepository)(nil).IsTokenRevoked), ctx, jti)
+}
+
+// Purge mocks base method.
+func (m *MockRepository) Purge(ctx context.Context, companyId, version int) (models.Company, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, companyId, version)
+	ret0, _ := ret[0].(models.Company)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockRepositoryMockRecorder) Purge(ctx, companyId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockRepository)(nil).Purge), ctx, companyId, version)
+}
+
+// RemoveUserRole mocks base method.
+func (m *MockRepository) RemoveUserRole(ctx context.Context, userId, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveUserRole", ctx, userId, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveUserRole indicates an expected call of RemoveUserRole.
+func (mr *MockRepositoryMockRecorder) RemoveUserRole(ctx, userId, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveUserRole", reflect.TypeOf((*MockRepository)(nil).RemoveUserRole), ctx, userId, role)
+}
+
+// Restore mocks base method.
+func (m *MockRepository) Restore(ctx context.Context, companyId int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, companyId)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockRepositoryMockRecorder) Restore(ctx, companyId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRepository)(nil).Restore), ctx, companyId)
+}
+
+// RevokeApiKey mocks base method.
+func (m *MockRepository) RevokeApiKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeApiKey indicates an expected call of RevokeApiKey.
+func (mr *MockRepositoryMockRecorder) RevokeApiKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKey", reflect.TypeOf((*MockRepository)(nil).RevokeApiKey), ctx, id)
+}
+
+// RevokeToken mocks base method.
+func (m *MockRepository) RevokeToken(ctx context.Context, jti string, expiresAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, jti, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockRepositoryMockRecorder) RevokeToken(ctx, jti, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockRepository)(nil).RevokeToken), ctx, jti, expiresAt)
+}
+
+// RevokeTokenFamily mocks base method.
+func (m *MockRepository) RevokeTokenFamily(ctx context.Context, familyId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeTokenFamily", ctx, familyId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeTokenFamily indicates an expected call of RevokeTokenFamily.
+func (mr *MockRepositoryMockRecorder) RevokeTokenFamily(ctx, familyId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeTokenFamily", reflect.TypeOf((*MockRepository)(nil).RevokeTokenFamily), ctx, familyId)
+}
+
+// Search mocks base method.
+func (m *MockRepository) Search(ctx context.Context, query string, limit int) ([]models.CompanySearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, query, limit)
+	ret0, _ := ret[0].([]models.CompanySearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockRepositoryMockRecorder) Search(ctx, query, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockRepository)(nil).Search), ctx, query, limit)
+}
+
+// TouchApiKey mocks base method.
+func (m *MockRepository) TouchApiKey(ctx context.Context, id, ip string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", ctx, companyId, company)
+	ret := m.ctrl.Call(m, "TouchApiKey", ctx, id, ip)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// TouchApiKey indicates an expected call of TouchApiKey.
+func (mr *MockRepositoryMockRecorder) TouchApiKey(ctx, id, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchApiKey", reflect.TypeOf((*MockRepository)(nil).TouchApiKey), ctx, id, ip)
+}
+
+// Update mocks base method.
+func (m *MockRepository) Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, countryId, version int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, companyId, company, countryId, version)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Update indicates an expected call of Update.
-func (mr *MockRepositoryMockRecorder) Update(ctx, companyId, company interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Update(ctx, companyId, company, countryId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, companyId, company, countryId, version)
+}
+
+// UseRefreshToken mocks base method.
+func (m *MockRepository) UseRefreshToken(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRefreshToken", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRefreshToken indicates an expected call of UseRefreshToken.
+func (mr *MockRepositoryMockRecorder) UseRefreshToken(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRefreshToken", reflect.TypeOf((*MockRepository)(nil).UseRefreshToken), ctx, id)
+}
+
+// WithinTransaction mocks base method.
+func (m *MockRepository) WithinTransaction(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTransaction", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTransaction indicates an expected call of WithinTransaction.
+func (mr *MockRepositoryMockRecorder) WithinTransaction(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, companyId, company)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTransaction", reflect.TypeOf((*MockRepository)(nil).WithinTransaction), ctx, fn)
 }
@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	models "github.com/dkischenko/xm_app/internal/company/models"
+	auth "github.com/dkischenko/xm_app/pkg/auth"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,64 +36,110 @@ func (m *MockIService) EXPECT() *MockIServiceMockRecorder {
 	return m.recorder
 }
 
+// AssignRole mocks base method.
+func (m *MockIService) AssignRole(ctx context.Context, userId, role string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssignRole", ctx, userId, role)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssignRole indicates an expected call of AssignRole.
+func (mr *MockIServiceMockRecorder) AssignRole(ctx, userId, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssignRole", reflect.TypeOf((*MockIService)(nil).AssignRole), ctx, userId, role)
+}
+
+// CheckApiKey mocks base method.
+func (m *MockIService) CheckApiKey(ctx context.Context, header, ip string) (models.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckApiKey", ctx, header, ip)
+	ret0, _ := ret[0].(models.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckApiKey indicates an expected call of CheckApiKey.
+func (mr *MockIServiceMockRecorder) CheckApiKey(ctx, header, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckApiKey", reflect.TypeOf((*MockIService)(nil).CheckApiKey), ctx, header, ip)
+}
+
 // CheckAuth mocks base method.
-func (m *MockIService) CheckAuth(header string) (string, error) {
+func (m *MockIService) CheckAuth(ctx context.Context, header string) (auth.Claims, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckAuth", header)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "CheckAuth", ctx, header)
+	ret0, _ := ret[0].(auth.Claims)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CheckAuth indicates an expected call of CheckAuth.
-func (mr *MockIServiceMockRecorder) CheckAuth(header interface{}) *gomock.Call {
+func (mr *MockIServiceMockRecorder) CheckAuth(ctx, header interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAuth", reflect.TypeOf((*MockIService)(nil).CheckAuth), header)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAuth", reflect.TypeOf((*MockIService)(nil).CheckAuth), ctx, header)
+}
+
+// CreateApiKey mocks base method.
+func (m *MockIService) CreateApiKey(ctx context.Context, req models.ApiKeyCreateRequest) (models.ApiKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApiKey", ctx, req)
+	ret0, _ := ret[0].(models.ApiKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateApiKey indicates an expected call of CreateApiKey.
+func (mr *MockIServiceMockRecorder) CreateApiKey(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApiKey", reflect.TypeOf((*MockIService)(nil).CreateApiKey), ctx, req)
 }
 
 // CreateCompany mocks base method.
-func (m *MockIService) CreateCompany(ctx context.Context, company models.CompanyCreateRequest, countryId int) (int, error) {
+func (m *MockIService) CreateCompany(ctx context.Context, company models.CompanyCreateRequest) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCompany", ctx, company, countryId)
+	ret := m.ctrl.Call(m, "CreateCompany", ctx, company)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateCompany indicates an expected call of CreateCompany.
-func (mr *MockIServiceMockRecorder) CreateCompany(ctx, company, countryId interface{}) *gomock.Call {
+func (mr *MockIServiceMockRecorder) CreateCompany(ctx, company interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCompany", reflect.TypeOf((*MockIService)(nil).CreateCompany), ctx, company, countryId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCompany", reflect.TypeOf((*MockIService)(nil).CreateCompany), ctx, company)
 }
 
-// CreateCountry mocks base method.
-func (m *MockIService) CreateCountry(ctx context.Context, company models.CompanyCreateRequest) (int, error) {
+// CreateRefreshToken mocks base method.
+func (m *MockIService) CreateRefreshToken(ctx context.Context, userId string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCountry", ctx, company)
-	ret0, _ := ret[0].(int)
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, userId)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateCountry indicates an expected call of CreateCountry.
-func (mr *MockIServiceMockRecorder) CreateCountry(ctx, company interface{}) *gomock.Call {
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockIServiceMockRecorder) CreateRefreshToken(ctx, userId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCountry", reflect.TypeOf((*MockIService)(nil).CreateCountry), ctx, company)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockIService)(nil).CreateRefreshToken), ctx, userId)
 }
 
 // CreateToken mocks base method.
-func (m *MockIService) CreateToken(uId string) (string, error) {
+func (m *MockIService) CreateToken(claims auth.Claims) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateToken", uId)
+	ret := m.ctrl.Call(m, "CreateToken", claims)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateToken indicates an expected call of CreateToken.
-func (mr *MockIServiceMockRecorder) CreateToken(uId interface{}) *gomock.Call {
+func (mr *MockIServiceMockRecorder) CreateToken(claims interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToken", reflect.TypeOf((*MockIService)(nil).CreateToken), uId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToken", reflect.TypeOf((*MockIService)(nil).CreateToken), claims)
 }
 
 // CreateUser mocks base method.
@@ -111,32 +158,62 @@ func (mr *MockIServiceMockRecorder) CreateUser(ctx, user interface{}) *gomock.Ca
 }
 
 // DeleteCompany mocks base method.
-func (m *MockIService) DeleteCompany(ctx context.Context, companyId int) error {
+func (m *MockIService) DeleteCompany(ctx context.Context, companyId, version int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteCompany", ctx, companyId)
+	ret := m.ctrl.Call(m, "DeleteCompany", ctx, companyId, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteCompany indicates an expected call of DeleteCompany.
-func (mr *MockIServiceMockRecorder) DeleteCompany(ctx, companyId interface{}) *gomock.Call {
+func (mr *MockIServiceMockRecorder) DeleteCompany(ctx, companyId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCompany", reflect.TypeOf((*MockIService)(nil).DeleteCompany), ctx, companyId, version)
+}
+
+// GetApiKeys mocks base method.
+func (m *MockIService) GetApiKeys(ctx context.Context) ([]models.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKeys", ctx)
+	ret0, _ := ret[0].([]models.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKeys indicates an expected call of GetApiKeys.
+func (mr *MockIServiceMockRecorder) GetApiKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKeys", reflect.TypeOf((*MockIService)(nil).GetApiKeys), ctx)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockIService) GetAuditEvents(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, filter)
+	ret0, _ := ret[0].(models.AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockIServiceMockRecorder) GetAuditEvents(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCompany", reflect.TypeOf((*MockIService)(nil).DeleteCompany), ctx, companyId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockIService)(nil).GetAuditEvents), ctx, filter)
 }
 
 // GetCompanies mocks base method.
-func (m *MockIService) GetCompanies(ctx context.Context) ([]models.Company, error) {
+func (m *MockIService) GetCompanies(ctx context.Context, filter models.CompanyFilter) (models.CompanyPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCompanies", ctx)
-	ret0, _ := ret[0].([]models.Company)
+	ret := m.ctrl.Call(m, "GetCompanies", ctx, filter)
+	ret0, _ := ret[0].(models.CompanyPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCompanies indicates an expected call of GetCompanies.
-func (mr *MockIServiceMockRecorder) GetCompanies(ctx interface{}) *gomock.Call {
+func (mr *MockIServiceMockRecorder) GetCompanies(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompanies", reflect.TypeOf((*MockIService)(nil).GetCompanies), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompanies", reflect.TypeOf((*MockIService)(nil).GetCompanies), ctx, filter)
 }
 
 // GetCompany mocks base method.
@@ -154,6 +231,65 @@ func (mr *MockIServiceMockRecorder) GetCompany(ctx, companyId interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompany", reflect.TypeOf((*MockIService)(nil).GetCompany), ctx, companyId)
 }
 
+// GetCountries mocks base method.
+func (m *MockIService) GetCountries(ctx context.Context, region string) ([]models.Country, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCountries", ctx, region)
+	ret0, _ := ret[0].([]models.Country)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCountries indicates an expected call of GetCountries.
+func (mr *MockIServiceMockRecorder) GetCountries(ctx, region interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountries", reflect.TypeOf((*MockIService)(nil).GetCountries), ctx, region)
+}
+
+// GetCountry mocks base method.
+func (m *MockIService) GetCountry(ctx context.Context, ref string) (models.Country, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCountry", ctx, ref)
+	ret0, _ := ret[0].(models.Country)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCountry indicates an expected call of GetCountry.
+func (mr *MockIServiceMockRecorder) GetCountry(ctx, ref interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountry", reflect.TypeOf((*MockIService)(nil).GetCountry), ctx, ref)
+}
+
+// GetUserRoles mocks base method.
+func (m *MockIService) GetUserRoles(ctx context.Context, userId string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRoles", ctx, userId)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRoles indicates an expected call of GetUserRoles.
+func (mr *MockIServiceMockRecorder) GetUserRoles(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRoles", reflect.TypeOf((*MockIService)(nil).GetUserRoles), ctx, userId)
+}
+
+// JWKS mocks base method.
+func (m *MockIService) JWKS() auth.JWKS {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(auth.JWKS)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockIServiceMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockIService)(nil).JWKS))
+}
+
 // Login mocks base method.
 func (m *MockIService) Login(ctx context.Context, ur *models.UserRequest) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -169,16 +305,151 @@ func (mr *MockIServiceMockRecorder) Login(ctx, ur interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockIService)(nil).Login), ctx, ur)
 }
 
-// UpdateCompany mocks base method.
-func (m *MockIService) UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest) error {
+// Logout mocks base method.
+func (m *MockIService) Logout(ctx context.Context, claims auth.Claims, refreshToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, claims, refreshToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockIServiceMockRecorder) Logout(ctx, claims, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockIService)(nil).Logout), ctx, claims, refreshToken)
+}
+
+// PatchCompany mocks base method.
+func (m *MockIService) PatchCompany(ctx context.Context, companyId int, patch models.CompanyPatch, version int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateCompany", ctx, companyId, company)
+	ret := m.ctrl.Call(m, "PatchCompany", ctx, companyId, patch, version)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchCompany indicates an expected call of PatchCompany.
+func (mr *MockIServiceMockRecorder) PatchCompany(ctx, companyId, patch, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchCompany", reflect.TypeOf((*MockIService)(nil).PatchCompany), ctx, companyId, patch, version)
+}
+
+// PurgeCompany mocks base method.
+func (m *MockIService) PurgeCompany(ctx context.Context, companyId, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeCompany", ctx, companyId, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// PurgeCompany indicates an expected call of PurgeCompany.
+func (mr *MockIServiceMockRecorder) PurgeCompany(ctx, companyId, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeCompany", reflect.TypeOf((*MockIService)(nil).PurgeCompany), ctx, companyId, version)
+}
+
+// Refresh mocks base method.
+func (m *MockIService) Refresh(ctx context.Context, token string) (*models.User, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", ctx, token)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockIServiceMockRecorder) Refresh(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockIService)(nil).Refresh), ctx, token)
+}
+
+// RestoreCompany mocks base method.
+func (m *MockIService) RestoreCompany(ctx context.Context, companyId int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreCompany", ctx, companyId)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreCompany indicates an expected call of RestoreCompany.
+func (mr *MockIServiceMockRecorder) RestoreCompany(ctx, companyId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreCompany", reflect.TypeOf((*MockIService)(nil).RestoreCompany), ctx, companyId)
+}
+
+// RevokeApiKey mocks base method.
+func (m *MockIService) RevokeApiKey(ctx context.Context, id string) (models.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKey", ctx, id)
+	ret0, _ := ret[0].(models.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeApiKey indicates an expected call of RevokeApiKey.
+func (mr *MockIServiceMockRecorder) RevokeApiKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKey", reflect.TypeOf((*MockIService)(nil).RevokeApiKey), ctx, id)
+}
+
+// RevokeRole mocks base method.
+func (m *MockIService) RevokeRole(ctx context.Context, userId, role string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRole", ctx, userId, role)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeRole indicates an expected call of RevokeRole.
+func (mr *MockIServiceMockRecorder) RevokeRole(ctx, userId, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRole", reflect.TypeOf((*MockIService)(nil).RevokeRole), ctx, userId, role)
+}
+
+// SearchCompanies mocks base method.
+func (m *MockIService) SearchCompanies(ctx context.Context, query string, limit int) ([]models.CompanySearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchCompanies", ctx, query, limit)
+	ret0, _ := ret[0].([]models.CompanySearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchCompanies indicates an expected call of SearchCompanies.
+func (mr *MockIServiceMockRecorder) SearchCompanies(ctx, query, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchCompanies", reflect.TypeOf((*MockIService)(nil).SearchCompanies), ctx, query, limit)
+}
+
+// UpdateCompany mocks base method.
+func (m *MockIService) UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, version int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCompany", ctx, companyId, company, version)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // UpdateCompany indicates an expected call of UpdateCompany.
-func (mr *MockIServiceMockRecorder) UpdateCompany(ctx, companyId, company interface{}) *gomock.Call {
+func (mr *MockIServiceMockRecorder) UpdateCompany(ctx, companyId, company, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCompany", reflect.TypeOf((*MockIService)(nil).UpdateCompany), ctx, companyId, company, version)
+}
+
+// VerifyAuditLog mocks base method.
+func (m *MockIService) VerifyAuditLog(ctx context.Context) (models.AuditVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAuditLog", ctx)
+	ret0, _ := ret[0].(models.AuditVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAuditLog indicates an expected call of VerifyAuditLog.
+func (mr *MockIServiceMockRecorder) VerifyAuditLog(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCompany", reflect.TypeOf((*MockIService)(nil).UpdateCompany), ctx, companyId, company)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAuditLog", reflect.TypeOf((*MockIService)(nil).VerifyAuditLog), ctx)
 }
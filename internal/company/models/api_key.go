@@ -0,0 +1,49 @@
+package models
+
+// ApiKeyScopes are the permissions API keys may be given. Managing roles and
+// keys is left to users.
+var ApiKeyScopes = []string{PermissionCompanyRead, PermissionCompanyWrite, PermissionCompanyDelete,
+	PermissionCompanyPurge, PermissionAuditRead, PermissionPolicyRead}
+
+// IsApiKeyScope reports whether API keys may be given the permission.
+func IsApiKeyScope(scope string) bool {
+	for _, s := range ApiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ApiKey is a key of a service-to-service client. Only the hash of the key is stored,
+// the prefix is the public part it is looked up by.
+type ApiKey struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedBy  string   `json:"created_by"`
+	CreatedAt  int64    `json:"created_at"`
+	ExpiresAt  *int64   `json:"expires_at"`
+	RevokedAt  *int64   `json:"revoked_at"`
+	LastUsedAt *int64   `json:"last_used_at"`
+	LastUsedIp *string  `json:"last_used_ip"`
+	KeyHash    string   `json:"-"`
+}
+
+type ApiKeyCreateRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// ExpiresAt is a unix timestamp, the key never expires without it.
+	ExpiresAt *int64 `json:"expires_at" validate:"omitempty,gt=0"`
+}
+
+// ApiKeyCreateResponse carries the key itself, it is shown this once only.
+type ApiKeyCreateResponse struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+type ApiKeysResponse struct {
+	ApiKeys []ApiKey `json:"api_keys"`
+}
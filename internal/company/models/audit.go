@@ -0,0 +1,170 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	AuditEntityCompany = "company"
+	AuditEntityUser    = "user"
+	AuditEntityApiKey  = "api_key"
+
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+	// AuditActionAssignRole and AuditActionRevokeRole change the roles of a user.
+	AuditActionAssignRole = "assign_role"
+	AuditActionRevokeRole = "revoke_role"
+	// AuditActionRevoke revokes an API key.
+	AuditActionRevoke = "revoke"
+
+	ActorTypeUser      = "user"
+	ActorTypeGeo       = "geo"
+	ActorTypeApiKey    = "api_key"
+	ActorTypeAnonymous = "anonymous"
+)
+
+// Actor is whoever performs a mutation: a user authorized by a token, a client
+// authorized by an API key, a caller authorized by its geo-IP country or an anonymous caller.
+type Actor struct {
+	Type string
+	Id   string
+	Ip   string
+	// Roles are the roles of a user, see RolePermissions.
+	Roles []string
+	// Scopes are the permissions of an API key.
+	Scopes []string
+}
+
+// Can reports whether the actor is a user or an API key client having the permission.
+func (a Actor) Can(permission string) bool {
+	switch a.Type {
+	case ActorTypeUser:
+		return HasPermission(a.Roles, permission)
+	case ActorTypeApiKey:
+		for _, scope := range a.Scopes {
+			if scope == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, an anonymous one if there is none.
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Type: ActorTypeAnonymous}
+}
+
+// AuditEvent is a single entry of the audit log. Every entry is chained to the
+// previous one by PrevHash, so any change of a stored entry breaks the chain.
+type AuditEvent struct {
+	Id        int64           `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityId  string          `json:"entity_id"`
+	Action    string          `json:"action"`
+	ActorType string          `json:"actor_type"`
+	ActorId   string          `json:"actor_id"`
+	Ip        string          `json:"ip"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt int64           `json:"created_at"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// ComputeHash returns the hash of the event content and its PrevHash.
+// Before and After are canonicalized first, as the storage may reformat them.
+func (e AuditEvent) ComputeHash() (string, error) {
+	before, err := canonicalJSON(e.Before)
+	if err != nil {
+		return "", err
+	}
+	after, err := canonicalJSON(e.After)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := json.Marshal([]interface{}{
+		e.PrevHash, e.Entity, e.EntityId, e.Action, e.ActorType, e.ActorId, e.Ip,
+		before, after, e.CreatedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func canonicalJSON(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// AuditFilter describes a single page request of the audit log, newest events first.
+type AuditFilter struct {
+	Entity   string `validate:"omitempty,oneof=company user"`
+	EntityId string `validate:"max=100"`
+	Limit    int    `validate:"min=1,max=100"`
+	// Cursor is the id of the last event of the previous page.
+	Cursor int64
+}
+
+// AuditPage is a single page of audit events. NextCursor is empty on the last page.
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// AuditVerification is the result of checking the audit log hash chain.
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int   `json:"checked"`
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// EncodeAuditCursor returns the opaque cursor pointing after the event with the id.
+func EncodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodeAuditCursor parses a cursor previously returned by EncodeAuditCursor.
+func DecodeAuditCursor(s string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
@@ -1,28 +1,62 @@
 package models
 
 type Company struct {
-	Id        int    `json:"id"`
-	Name      string `json:"name"`
-	Code      int    `json:"code"`
-	CountryId int    `json:"countryId"`
-	Website   string `json:"website"`
-	Phone     string `json:"phone"`
-	CreatedAt int    `json:"created_at"`
-	UpdatedAt int    `json:"updated_at"`
+	Id        int     `json:"id"`
+	Name      string  `json:"name"`
+	Code      int     `json:"code"`
+	Country   Country `json:"country"`
+	Website   string  `json:"website"`
+	Phone     string  `json:"phone"`
+	CreatedAt int     `json:"created_at"`
+	UpdatedAt int     `json:"updated_at"`
+	Version   int     `json:"version"`
+	DeletedAt *int    `json:"deleted_at,omitempty"`
 }
 
+// CompanySearchResult is a company matched by a search query with its relevance score.
+type CompanySearchResult struct {
+	Company
+	Score float64 `json:"score"`
+}
+
+// CompanyCreateRequest refers to the country by its ISO 3166-1 code or English name.
 type CompanyCreateRequest struct {
 	Name    string `json:"name" validate:"required"`
 	Code    int    `json:"code" validate:"required,numeric"`
-	Country string `json:"country" validate:"required,alpha"`
+	Country string `json:"country" validate:"required,max=100"`
 	Website string `json:"website" validate:"required,url"`
 	Phone   string `json:"phone" validate:"required,e164"`
 }
 
+// CompanyUpdateRequest is the full representation of a company accepted by PUT.
+// All fields are required, a partial update goes through CompanyPatch.
 type CompanyUpdateRequest struct {
-	Name      string `json:"name"`
-	Code      int    `json:"code" validate:"numeric"`
-	CountryId int    `json:"country_id" validate:"alpha"`
-	Website   string `json:"website" validate:"url"`
-	Phone     string `json:"phone" validate:"e164"`
+	Name    string `json:"name" validate:"required"`
+	Code    int    `json:"code" validate:"required,numeric"`
+	Country string `json:"country" validate:"required,max=100"`
+	Website string `json:"website" validate:"required,url"`
+	Phone   string `json:"phone" validate:"required,e164"`
+}
+
+const (
+	MediaTypeMergePatch = "application/merge-patch+json"
+	MediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// CompanyPatch is a partial update of a company: a JSON Merge Patch (RFC 7396)
+// or a JSON Patch (RFC 6902) document, depending on ContentType.
+type CompanyPatch struct {
+	ContentType string
+	Body        []byte
+}
+
+// UpdateRequest returns the updatable representation of the company.
+func (c Company) UpdateRequest() CompanyUpdateRequest {
+	return CompanyUpdateRequest{
+		Name:    c.Name,
+		Code:    c.Code,
+		Country: c.Country.Alpha2,
+		Website: c.Website,
+		Phone:   c.Phone,
+	}
 }
@@ -1,6 +1,11 @@
 package models
 
+// Country is an ISO 3166-1 country.
 type Country struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
+	Id      int    `json:"id"`
+	Alpha2  string `json:"alpha2"`
+	Alpha3  string `json:"alpha3"`
+	Numeric string `json:"numeric"`
+	Name    string `json:"name"`
+	Region  string `json:"region"`
 }
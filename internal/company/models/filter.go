@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+const (
+	DefaultCompaniesLimit = 20
+	MaxCompaniesLimit     = 100
+
+	MinSearchQueryLength = 2
+	MaxSearchQueryLength = 100
+
+	SortById        = "id"
+	SortByName      = "name"
+	SortByCode      = "code"
+	SortByCountry   = "country"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CompanyFilter describes a single page request of the companies list.
+type CompanyFilter struct {
+	Limit       int    `validate:"min=1,max=100"`
+	NamePrefix  string `validate:"max=100"`
+	Code        int    `validate:"min=0"`
+	Country     string `validate:"max=100"`
+	CreatedFrom int64  `validate:"min=0"`
+	CreatedTo   int64  `validate:"min=0"`
+	UpdatedFrom int64  `validate:"min=0"`
+	UpdatedTo   int64  `validate:"min=0"`
+	Sort        string `validate:"oneof=id name code country created_at updated_at"`
+	Desc        bool
+	Cursor      *CompanyCursor
+	// Trashed lists soft-deleted companies instead of the active ones.
+	Trashed bool
+}
+
+// CompanyPage is a single page of companies. NextCursor is empty on the last page.
+type CompanyPage struct {
+	Companies  []Company `json:"companies"`
+	NextCursor string    `json:"next_cursor"`
+}
+
+// CompanyCursor is the keyset position of the last company of a page:
+// the value of the sort column and the company id as a tie-breaker.
+type CompanyCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	Id    int    `json:"id"`
+}
+
+// NewCompanyCursor returns the cursor pointing right after the company c.
+func NewCompanyCursor(c Company, filter CompanyFilter) CompanyCursor {
+	return CompanyCursor{
+		Sort:  filter.Sort,
+		Desc:  filter.Desc,
+		Value: c.SortValue(filter.Sort),
+		Id:    c.Id,
+	}
+}
+
+// Encode returns the opaque string representation of the cursor.
+func (c CompanyCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCompanyCursor parses a cursor previously returned by Encode.
+func DecodeCompanyCursor(s string) (*CompanyCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &CompanyCursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.Id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// Matches reports whether the cursor was issued for the same ordering as the filter.
+func (c CompanyCursor) Matches(filter CompanyFilter) bool {
+	return c.Sort == filter.Sort && c.Desc == filter.Desc
+}
+
+// SortValue returns the value of the company field used for the given sort key.
+func (c Company) SortValue(sort string) string {
+	switch sort {
+	case SortByName:
+		return c.Name
+	case SortByCode:
+		return strconv.Itoa(c.Code)
+	case SortByCountry:
+		return c.Country.Name
+	case SortByCreatedAt:
+		return strconv.Itoa(c.CreatedAt)
+	case SortByUpdatedAt:
+		return strconv.Itoa(c.UpdatedAt)
+	default:
+		return strconv.Itoa(c.Id)
+	}
+}
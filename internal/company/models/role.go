@@ -0,0 +1,50 @@
+package models
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+
+	// DefaultRole is given to every new user.
+	DefaultRole = RoleEditor
+
+	PermissionCompanyRead   = "company:read"
+	PermissionCompanyWrite  = "company:write"
+	PermissionCompanyDelete = "company:delete"
+	PermissionCompanyPurge  = "company:purge"
+	PermissionAuditRead     = "audit:read"
+	PermissionPolicyRead    = "policy:read"
+	PermissionRoleManage    = "role:manage"
+	PermissionApiKeyManage  = "apikey:manage"
+)
+
+// RolePermissions are the permissions each role grants.
+var RolePermissions = map[string][]string{
+	RoleViewer: {PermissionCompanyRead},
+	RoleEditor: {PermissionCompanyRead, PermissionCompanyWrite, PermissionCompanyDelete},
+	RoleAdmin: {PermissionCompanyRead, PermissionCompanyWrite, PermissionCompanyDelete, PermissionCompanyPurge,
+		PermissionAuditRead, PermissionPolicyRead, PermissionRoleManage, PermissionApiKeyManage},
+}
+
+// IsRole reports whether role is one of the known roles.
+func IsRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether any of the roles grants the permission.
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, p := range RolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type RolesResponse struct {
+	UserId string   `json:"user_id"`
+	Roles  []string `json:"roles"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// RefreshToken is a stored refresh token. Tokens issued by refreshing one another form a family.
+type RefreshToken struct {
+	Id        int64
+	FamilyId  string
+	UserId    string
+	ExpiresAt int64
+	// UsedAt is set once the token was exchanged, presenting it again is a reuse.
+	UsedAt    *int64
+	RevokedAt *int64
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	// RefreshToken, if given, is revoked together with its whole family.
+	RefreshToken string `json:"refresh_token"`
+}
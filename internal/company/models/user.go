@@ -1,9 +1,10 @@
 package models
 
 type User struct {
-	Id           string `json:"id"`
-	Name         string `json:"name"`
-	PasswordHash string `json:"passwordHash"`
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	PasswordHash string   `json:"passwordHash"`
+	Roles        []string `json:"roles"`
 }
 
 type UserRequest struct {
@@ -17,5 +18,6 @@ type UserCreateResponse struct {
 }
 
 type UserLoginResponse struct {
-	Hash string `json:"hash"`
+	Hash         string `json:"hash"`
+	RefreshToken string `json:"refresh_token"`
 }
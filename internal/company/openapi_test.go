@@ -0,0 +1,175 @@
+package company_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/internal/openapi"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHandler_RoutesDocumented(t *testing.T) {
+	t.Run("[Ok] Every route is in the OpenAPI document", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		doc, err := openapi.Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, _ := logger.GetLogger()
+		h := company.NewHandler(l, mock_company.NewMockIService(ctrl), &config.Config{},
+			newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+		router := mux.NewRouter()
+		h.Register(router)
+
+		// The document has no patterns in path parameters.
+		pattern := regexp.MustCompile(`\{(\w+):[^}]+\}`)
+		err = router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return err
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return err
+			}
+			path = pattern.ReplaceAllString(path, "{$1}")
+			item := doc.Paths.Find(path)
+			for _, method := range methods {
+				if item == nil || item.GetOperation(method) == nil {
+					t.Errorf("%s %s is not documented", method, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestHandler_ResponsesDocumented(t *testing.T) {
+	acme := models.Company{Id: 1, Name: "Acme", Code: 12345, Version: 3, Website: "https://example.com",
+		Phone: "+35722000000", Country: models.Country{Id: 1, Alpha2: "CY", Alpha3: "CYP", Numeric: "196",
+			Name: "Cyprus", Region: "Asia"}}
+	adminClaims := auth.Claims{Principal: auth.User(roleUserId), Roles: []string{models.RoleAdmin}}
+	viewerClaims := auth.Claims{Principal: auth.User(roleUserId), Roles: []string{models.RoleViewer}}
+	expires := int64(1700000000)
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		mock   func(s *mock_company.MockIService)
+		status int
+	}{
+		{name: "[Ok] Get company", method: http.MethodGet, path: "/v1/companies/1",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(viewerClaims, nil)
+				s.EXPECT().GetCompany(gomock.Any(), 1).Return(acme, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] List companies", method: http.MethodGet, path: "/v1/companies?limit=1&sort=-name",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(viewerClaims, nil)
+				s.EXPECT().GetCompanies(gomock.Any(), gomock.Any()).
+					Return(models.CompanyPage{Companies: []models.Company{acme}, NextCursor: "abc"}, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] Search companies", method: http.MethodGet, path: "/v1/companies/search?q=acme",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(viewerClaims, nil)
+				s.EXPECT().SearchCompanies(gomock.Any(), "acme", gomock.Any()).
+					Return([]models.CompanySearchResult{{Company: acme, Score: 0.5}}, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] Get countries", method: http.MethodGet, path: "/v1/countries",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(viewerClaims, nil)
+				s.EXPECT().GetCountries(gomock.Any(), "").Return([]models.Country{acme.Country}, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] Create user", method: http.MethodPost, path: "/v1/users",
+			body: `{"name": "bill", "password": "password"}`,
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(roleUserId, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] List api keys", method: http.MethodGet, path: "/v1/api-keys",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(adminClaims, nil)
+				s.EXPECT().GetApiKeys(gomock.Any()).Return([]models.ApiKey{{Id: apiKeyId, Name: "billing",
+					Prefix: "0123456789abcdef", Scopes: []string{models.PermissionCompanyRead}, CreatedBy: roleUserId,
+					CreatedAt: 1600000000, ExpiresAt: &expires}}, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] Get user roles", method: http.MethodGet, path: "/v1/users/" + roleUserId + "/roles",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(adminClaims, nil)
+				s.EXPECT().GetUserRoles(gomock.Any(), roleUserId).Return([]string{models.RoleViewer}, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Ok] Verify audit log", method: http.MethodGet, path: "/v1/audit/verify",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(adminClaims, nil)
+				s.EXPECT().VerifyAuditLog(gomock.Any()).Return(models.AuditVerification{Valid: true, Checked: 2}, nil)
+			},
+			status: http.StatusOK},
+		{name: "[Err] Missing company", method: http.MethodGet, path: "/v1/companies/1",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(viewerClaims, nil)
+				s.EXPECT().GetCompany(gomock.Any(), 1).
+					Return(models.Company{}, fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound))
+			},
+			status: http.StatusNotFound},
+		{name: "[Err] Bad credentials", method: http.MethodPost, path: "/v1/users/login",
+			body: `{"name": "bill", "password": "password"}`,
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().Login(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidCredentials))
+			},
+			status: http.StatusUnauthorized},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(tcase.method, tcase.path, strings.NewReader(tcase.body))
+			req.Header.Set("Authorization", "Bearer token")
+			if tcase.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			tcase.mock(mockService)
+			v, err := openapi.NewValidator(l, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			router.Use(v.Middleware)
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package company
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// applyCompanyPatch applies the patch document to the company and returns the
+// patched representation. Fields unknown to CompanyUpdateRequest are rejected.
+func applyCompanyPatch(current models.CompanyUpdateRequest, patch models.CompanyPatch) (patched models.CompanyUpdateRequest, err error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return patched, err
+	}
+
+	switch patch.ContentType {
+	case models.MediaTypeMergePatch:
+		doc, err = jsonpatch.MergePatch(doc, patch.Body)
+	case models.MediaTypeJSONPatch:
+		var p jsonpatch.Patch
+		if p, err = jsonpatch.DecodePatch(patch.Body); err == nil {
+			doc, err = p.Apply(doc)
+		}
+	default:
+		return patched, uerrors.ErrUnsupportedPatch.WithDetail("%q", patch.ContentType)
+	}
+	if err != nil {
+		return patched, uerrors.ErrApplyPatch.WithDetail("%s", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&patched); err != nil {
+		return patched, uerrors.ErrApplyPatch.WithDetail("%s", err)
+	}
+
+	return patched, nil
+}
@@ -0,0 +1,64 @@
+package company
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	paramIp        = "ip"
+	paramOperation = "operation"
+	paramAt        = "at"
+)
+
+// ExplainPolicyHandler reports how the access policy decides for a caller address,
+// without performing any operation.
+func (h handler) ExplainPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ip := net.ParseIP(query.Get(paramIp))
+	if ip == nil {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest,
+			fmt.Sprintf("got wrong %s param: %q", paramIp, query.Get(paramIp)))
+		return
+	}
+
+	at := time.Now()
+	if value := query.Get(paramAt); value != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest,
+				fmt.Sprintf("got wrong %s param: %+v", paramAt, err))
+			return
+		}
+	}
+
+	operations := policy.Operations
+	if operation := query.Get(paramOperation); operation != "" {
+		if !policy.IsOperation(operation) {
+			h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest,
+				fmt.Sprintf("got wrong %s param: %q", paramOperation, operation))
+			return
+		}
+		operations = []string{operation}
+	}
+
+	decisions := make([]policy.Decision, 0, len(operations))
+	for _, operation := range operations {
+		decisions = append(decisions, h.policy.Evaluate(r.Context(), policy.Request{
+			Operation: operation,
+			IP:        ip,
+			Time:      at,
+		}))
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(PolicyExplainResponse{Ip: ip.String(), Decisions: decisions}); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't explain policy: %+v", err)
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package company_test
+
+import (
+	"encoding/json"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	"github.com/dkischenko/xm_app/internal/policy"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler_ExplainPolicy(t *testing.T) {
+	admin := []string{models.RoleAdmin}
+	testCases := []struct {
+		name      string
+		roles     []string
+		query     string
+		status    int
+		decisions int
+	}{
+		{name: "[Ok] All operations", roles: admin, query: "?ip=31.153.0.1", status: http.StatusOK, decisions: 4},
+		{name: "[Ok] One operation", roles: admin, query: "?ip=31.153.0.1&operation=company.delete&at=2022-05-02T10:00:00Z",
+			status: http.StatusOK, decisions: 1},
+		{name: "[Err] Not an admin", roles: []string{models.RoleEditor}, query: "?ip=31.153.0.1",
+			status: http.StatusForbidden},
+		{name: "[Err] Wrong ip", roles: admin, query: "?ip=localhost", status: http.StatusBadRequest},
+		{name: "[Err] Wrong operation", roles: admin, query: "?ip=31.153.0.1&operation=company.read",
+			status: http.StatusBadRequest},
+		{name: "[Err] Wrong time", roles: admin, query: "?ip=31.153.0.1&at=yesterday", status: http.StatusBadRequest},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/policy/explain"+tcase.query, nil)
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: tcase.roles}, nil)
+			mockLocator := mock_geo.NewMockGeoLocator(ctrl)
+			mockLocator.EXPECT().Locate(gomock.Any(), net.ParseIP("31.153.0.1")).
+				Return(geo.Location{CountryCode: "CY", CountryName: "Cyprus"}, nil).AnyTimes()
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mockLocator, false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			if tcase.status != http.StatusOK {
+				return
+			}
+
+			var resp company.PolicyExplainResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			assert.Equal(t, "31.153.0.1", resp.Ip)
+			assert.Len(t, resp.Decisions, tcase.decisions)
+			for _, d := range resp.Decisions {
+				assert.True(t, d.Allowed)
+				assert.True(t, d.GrantToken)
+				assert.Equal(t, policy.DefaultRules[0].Name, d.Rule)
+				assert.Equal(t, "CY", d.Country)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package company
+
+import (
+	"encoding/json"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/requestid"
+	"net/http"
+)
+
+const (
+	headerValueProblem = "application/problem+json"
+	// codeBadRequest is the code of requests that can't be parsed, such as malformed JSON bodies.
+	codeBadRequest = "bad_request"
+	// codePreconditionRequired is the code of writes lacking the If-Match header in strict mode.
+	codePreconditionRequired = "precondition_required"
+	codeRouteNotFound        = "route_not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+)
+
+// kindStatus maps the kinds of domain errors to HTTP status codes.
+var kindStatus = map[uerrors.Kind]int{
+	uerrors.KindNotFound:        http.StatusNotFound,
+	uerrors.KindConflict:        http.StatusConflict,
+	uerrors.KindValidation:      http.StatusUnprocessableEntity,
+	uerrors.KindUnauthenticated: http.StatusUnauthorized,
+	uerrors.KindForbidden:       http.StatusForbidden,
+	uerrors.KindUnavailable:     http.StatusServiceUnavailable,
+}
+
+// writeError writes the problem err carries with the status of its kind, 500 for internal errors.
+// Errors of the server are logged, their details are kept from the client.
+func (h handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	h.writeErrorStatus(w, r, 0, err)
+}
+
+// writeErrorStatus writes the problem err carries with the status, the one of its kind when status is 0.
+func (h handler) writeErrorStatus(w http.ResponseWriter, r *http.Request, status int, err error) {
+	e := uerrors.As(err)
+	if status == 0 {
+		if status = kindStatus[e.Kind]; status == 0 {
+			status = http.StatusInternalServerError
+		}
+	}
+	detail := e.Error()
+	if status >= http.StatusInternalServerError {
+		h.logger.Ctx(r.Context()).Errorf("%s %s failed: %+v", r.Method, r.URL.Path, err)
+		detail = ""
+	}
+	h.writeProblem(w, r, status, e.Code, detail)
+}
+
+// writeProblem writes an RFC 7807 problem details body.
+func (h handler) writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	w.Header().Set(headerContentType, headerValueProblem)
+	w.WriteHeader(status)
+	problem := uerrors.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestId: requestid.FromContext(r.Context()),
+	}
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("problems with encoding data: %+v", err)
+	}
+}
+
+// routeNotFound writes the problem of requests matching no route.
+func (h handler) routeNotFound(w http.ResponseWriter, r *http.Request) {
+	h.writeProblem(w, r, http.StatusNotFound, codeRouteNotFound, "")
+}
+
+// methodNotAllowed writes the problem of requests to a route not serving their method.
+func (h handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	h.writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "")
+}
@@ -0,0 +1,187 @@
+package company_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/dkischenko/xm_app/pkg/requestid"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_Problem(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		mock   func(s *mock_company.MockIService)
+		status int
+		code   string
+		detail bool
+	}{
+		{name: "[Err] Missing company", method: http.MethodGet, path: "/v1/companies/7",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().GetCompany(gomock.Any(), 7).
+					Return(models.Company{}, fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound))
+			},
+			status: http.StatusNotFound, code: "company_not_found", detail: true},
+		{name: "[Err] Database failure", method: http.MethodGet, path: "/v1/companies/7",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().GetCompany(gomock.Any(), 7).
+					Return(models.Company{}, fmt.Errorf("error occurs: %w", uerrors.ErrGetCompany))
+			},
+			status: http.StatusInternalServerError, code: uerrors.CodeInternal},
+		{name: "[Err] Database unavailable", method: http.MethodGet, path: "/v1/companies/7",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().GetCompany(gomock.Any(), 7).Return(models.Company{}, fmt.Errorf("error occurs: %w",
+					fmt.Errorf("Error occurs: dial tcp 10.0.0.5:5432: connect: connection refused. %w", uerrors.ErrUnavailable)))
+			},
+			status: http.StatusServiceUnavailable, code: "unavailable"},
+		{name: "[Err] Token denylist unavailable", method: http.MethodGet, path: "/v1/companies/7",
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{}, fmt.Errorf("error occurs: %w: %v",
+					uerrors.ErrUnavailable, errors.New("dial tcp 10.0.0.5:5432: connect: connection refused")))
+			},
+			status: http.StatusServiceUnavailable, code: "unavailable"},
+		{name: "[Err] Bad credentials mint no token", method: http.MethodPost, path: "/v1/users/login",
+			body: `{"name": "bill", "password": "password"}`,
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().Login(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidCredentials))
+			},
+			status: http.StatusUnauthorized, code: "invalid_credentials", detail: true},
+		{name: "[Err] User exists", method: http.MethodPost, path: "/v1/users",
+			body: `{"name": "bill", "password": "password"}`,
+			mock: func(s *mock_company.MockIService) {
+				s.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Return("", fmt.Errorf("error occurs: %w", uerrors.ErrUserExists))
+			},
+			status: http.StatusConflict, code: "user_exists", detail: true},
+		{name: "[Err] Malformed body", method: http.MethodPost, path: "/v1/users", body: `{"name":`,
+			status: http.StatusBadRequest, code: "bad_request", detail: true},
+		{name: "[Err] Unknown route", method: http.MethodGet, path: "/v1/unknown",
+			status: http.StatusNotFound, code: "route_not_found"},
+		{name: "[Err] Method not allowed", method: http.MethodDelete, path: "/v1/countries",
+			status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(tcase.method, tcase.path, strings.NewReader(tcase.body))
+			req.Header.Set(requestid.Header, "req-1")
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			if tcase.mock != nil {
+				tcase.mock(mockService)
+			}
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{
+				Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleViewer}}, nil).AnyTimes()
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			router.Use(requestid.Middleware)
+			h.Register(router)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tcase.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.Equal(t, "req-1", w.Header().Get(requestid.Header))
+			problem := uerrors.Problem{}
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.status, problem.Status)
+			assert.Equal(t, tcase.code, problem.Code)
+			assert.Equal(t, "req-1", problem.RequestId)
+			assert.Equal(t, tcase.path, problem.Instance)
+			assert.Equal(t, tcase.detail, problem.Detail != "")
+		})
+	}
+}
+
+func TestHandler_DatabaseUnavailable(t *testing.T) {
+	outage := fmt.Errorf("Error occurs: %v. %w", errors.New("connection refused"), uerrors.ErrUnavailable)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		body   string
+		mock   func(mockRepo *mock_company.MockRepository)
+	}{
+		{name: "[Err] Login", method: http.MethodPost, path: "/v1/users/login",
+			body: `{"name": "bill", "password": "password"}`,
+			mock: func(mockRepo *mock_company.MockRepository) {
+				mockRepo.EXPECT().FindOneUser(gomock.Any(), "bill").Return(nil, outage)
+			}},
+		{name: "[Err] API key", method: http.MethodGet, path: "/v1/companies/1", header: "ApiKey xm_prefix_secret",
+			mock: func(mockRepo *mock_company.MockRepository) {
+				mockRepo.EXPECT().FindApiKey(gomock.Any(), "prefix").Return(models.ApiKey{}, outage)
+			}},
+		{name: "[Err] Get company", method: http.MethodGet, path: "/v1/companies/1", header: "ApiKey xm_prefix_secret",
+			mock: func(mockRepo *mock_company.MockRepository) {
+				mockRepo.EXPECT().FindApiKey(gomock.Any(), "prefix").Return(models.ApiKey{Id: "key",
+					KeyHash: sha256Hex("xm_prefix_secret"), Scopes: []string{models.PermissionCompanyRead}}, nil)
+				mockRepo.EXPECT().TouchApiKey(gomock.Any(), "key", gomock.Any()).Return(nil).AnyTimes()
+				mockRepo.EXPECT().GetCompany(gomock.Any(), 1).Return(models.Company{}, outage)
+			}},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(tcase.method, tcase.path, strings.NewReader(tcase.body))
+			if tcase.header != "" {
+				req.Header.Set("Authorization", tcase.header)
+			}
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockRepo := mock_company.NewMockRepository(ctrl)
+			tcase.mock(mockRepo)
+			s := company.NewService(l, mockRepo, auth.Options{TokenTTL: time.Hour}, 24*time.Hour)
+			h := company.NewHandler(l, s, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			problem := uerrors.Problem{}
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "unavailable", problem.Code)
+		})
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	t.Run("[Ok] Error with detail is its sentinel", func(t *testing.T) {
+		err := fmt.Errorf("error occurs: %w", uerrors.ErrCountryNotFound.WithDetail("%q", "XX"))
+		assert.True(t, errors.Is(err, uerrors.ErrCountryNotFound))
+		assert.False(t, errors.Is(err, uerrors.ErrCompanyNotFound))
+		assert.Equal(t, `error with unknown country: "XX"`, uerrors.As(err).Error())
+		assert.Equal(t, uerrors.CodeInternal, uerrors.As(uerrors.ErrGetCompany).Code)
+	})
+}
@@ -8,11 +8,66 @@ import (
 //go:generate mockgen -source=repository.go -destination=mocks/repository_mock.go
 type Repository interface {
 	Create(ctx context.Context, company models.CompanyCreateRequest, countryId int) (id int, err error)
-	GetList(ctx context.Context) (companies []models.Company, err error)
+	// GetList returns up to filter.Limit+1 companies matching the filter, starting after filter.Cursor.
+	GetList(ctx context.Context, filter models.CompanyFilter) (companies []models.Company, err error)
+	// Search returns up to limit companies matching the query, the most relevant first.
+	Search(ctx context.Context, query string, limit int) (companies []models.CompanySearchResult, err error)
 	GetCompany(ctx context.Context, companyId int) (company models.Company, err error)
-	Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest) (err error)
-	Delete(ctx context.Context, companyId int) (err error)
-	CreateCountry(ctx context.Context, company models.CompanyCreateRequest) (id int, err error)
+	// Update overwrites the company if its version equals the given one, a zero version skips the check.
+	// It returns the new version of the company.
+	Update(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, countryId int,
+		version int) (newVersion int, err error)
+	// Delete moves the company to the trash if its version equals the given one, a zero version skips the check.
+	Delete(ctx context.Context, companyId int, version int) (err error)
+	// Restore brings a soft-deleted company back and returns its new version.
+	Restore(ctx context.Context, companyId int) (newVersion int, err error)
+	// Purge removes the company permanently, whether it is in the trash or not, and returns the removed company.
+	Purge(ctx context.Context, companyId int, version int) (company models.Company, err error)
+	// GetCountries returns the countries of the region ordered by name, all of them for an empty region.
+	GetCountries(ctx context.Context, region string) (countries []models.Country, err error)
+	// FindCountry returns the country with the given ISO 3166-1 alpha-2, alpha-3 or numeric code or English name.
+	FindCountry(ctx context.Context, ref string) (country models.Country, err error)
 	CreateUser(ctx context.Context, user *models.User) (id string, err error)
+	// FindOneUser returns the user with the given name together with its roles.
 	FindOneUser(ctx context.Context, name string) (u *models.User, err error)
+	// GetUserRoles returns the roles of the user ordered by name.
+	GetUserRoles(ctx context.Context, userId string) (roles []string, err error)
+	// AddUserRole grants the role to the user, granting a role the user has is a no-op.
+	AddUserRole(ctx context.Context, userId string, role string) (err error)
+	// RemoveUserRole takes the role from the user, taking a role the user hasn't is a no-op.
+	RemoveUserRole(ctx context.Context, userId string, role string) (err error)
+	// CreateRefreshToken stores the hash of a refresh token of the family, an empty familyId starts a new one.
+	CreateRefreshToken(ctx context.Context, tokenHash string, familyId string, userId string,
+		expiresAt int64) (newFamilyId string, err error)
+	// FindRefreshToken returns the refresh token with the hash and locks it until the transaction ends.
+	FindRefreshToken(ctx context.Context, tokenHash string) (token models.RefreshToken, err error)
+	// UseRefreshToken marks the refresh token as exchanged.
+	UseRefreshToken(ctx context.Context, id int64) (err error)
+	// RevokeTokenFamily revokes every refresh token of the family.
+	RevokeTokenFamily(ctx context.Context, familyId string) (err error)
+	// RevokeToken puts the access token id on the denylist until the token expires.
+	RevokeToken(ctx context.Context, jti string, expiresAt int64) (err error)
+	// IsTokenRevoked reports whether the access token id is on the denylist.
+	IsTokenRevoked(ctx context.Context, jti string) (revoked bool, err error)
+	// CreateApiKey stores the API key with its hash and returns its id.
+	CreateApiKey(ctx context.Context, key *models.ApiKey) (id string, err error)
+	// GetApiKeys returns every API key, the newest first.
+	GetApiKeys(ctx context.Context) (keys []models.ApiKey, err error)
+	// GetApiKey returns the API key with the id and locks it until the transaction ends.
+	GetApiKey(ctx context.Context, id string) (key models.ApiKey, err error)
+	// FindApiKey returns the API key with the prefix.
+	FindApiKey(ctx context.Context, prefix string) (key models.ApiKey, err error)
+	// RevokeApiKey revokes the API key, revoking a revoked key is a no-op.
+	RevokeApiKey(ctx context.Context, id string) (err error)
+	// TouchApiKey records the API key was just used from the address.
+	TouchApiKey(ctx context.Context, id string, ip string) (err error)
+	// WithinTransaction runs fn in a database transaction. Repository methods called
+	// with the context passed to fn take part in it.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error)
+	// CreateAuditEvent chains the event to the last one and stores it.
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) (err error)
+	// GetAuditEvents returns up to filter.Limit+1 events, the newest first.
+	GetAuditEvents(ctx context.Context, filter models.AuditFilter) (events []models.AuditEvent, err error)
+	// GetAuditChain returns up to limit events following the one with afterId, in chain order.
+	GetAuditChain(ctx context.Context, afterId int64, limit int) (events []models.AuditEvent, err error)
 }
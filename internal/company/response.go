@@ -1,7 +1,25 @@
 package company
 
+import (
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/policy"
+)
+
 type CompanyCreateResponse struct {
 	Id   int    `json:"id"`
 	Name string `json:"name"`
 	Hash string `json:"hash"`
 }
+
+type CompanySearchResponse struct {
+	Companies []models.CompanySearchResult `json:"companies"`
+}
+
+type CountriesResponse struct {
+	Countries []models.Country `json:"countries"`
+}
+
+type PolicyExplainResponse struct {
+	Ip        string            `json:"ip"`
+	Decisions []policy.Decision `json:"decisions"`
+}
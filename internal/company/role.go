@@ -0,0 +1,63 @@
+package company
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+)
+
+func (s Service) GetUserRoles(ctx context.Context, userId string) (roles []string, err error) {
+	roles, err = s.storage.GetUserRoles(ctx, userId)
+	if errors.Is(err, uerrors.ErrUserNotFound) {
+		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrUserNotFound)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to get user roles: %s", err)
+		return nil, storageError(err, uerrors.ErrGetRoles)
+	}
+	return
+}
+
+func (s Service) AssignRole(ctx context.Context, userId string, role string) (roles []string, err error) {
+	return s.changeRoles(ctx, userId, role, models.AuditActionAssignRole, s.storage.AddUserRole)
+}
+
+func (s Service) RevokeRole(ctx context.Context, userId string, role string) (roles []string, err error) {
+	return s.changeRoles(ctx, userId, role, models.AuditActionRevokeRole, s.storage.RemoveUserRole)
+}
+
+// changeRoles applies change to the roles of the user and audits it, unless it changes nothing.
+func (s Service) changeRoles(ctx context.Context, userId string, role string, action string,
+	change func(ctx context.Context, userId string, role string) error) (roles []string, err error) {
+	if !models.IsRole(role) {
+		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrUnknownRole.WithDetail("%q", role))
+	}
+
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		before, err := s.storage.GetUserRoles(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if err = change(ctx, userId, role); err != nil {
+			return err
+		}
+		if roles, err = s.storage.GetUserRoles(ctx, userId); err != nil {
+			return err
+		}
+		if len(roles) == len(before) {
+			return nil
+		}
+		return s.audit(ctx, models.AuditEntityUser, userId, action,
+			models.RolesResponse{UserId: userId, Roles: before}, models.RolesResponse{UserId: userId, Roles: roles})
+	})
+	if errors.Is(err, uerrors.ErrUserNotFound) {
+		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrUserNotFound)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to change user roles: %s", err)
+		return nil, storageError(err, uerrors.ErrUpdateRoles)
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package company
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func (h handler) GetUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeUserRoles(w, r, "get", h.service.GetUserRoles)
+}
+
+func (h handler) AssignRoleHandler(w http.ResponseWriter, r *http.Request) {
+	role := mux.Vars(r)["role"]
+	h.writeUserRoles(w, r, "assign", func(ctx context.Context, userId string) ([]string, error) {
+		return h.service.AssignRole(ctx, userId, role)
+	})
+}
+
+func (h handler) RevokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	role := mux.Vars(r)["role"]
+	h.writeUserRoles(w, r, "revoke", func(ctx context.Context, userId string) ([]string, error) {
+		return h.service.RevokeRole(ctx, userId, role)
+	})
+}
+
+// writeUserRoles runs fn for the user of the request path and writes the roles it returns.
+func (h handler) writeUserRoles(w http.ResponseWriter, r *http.Request, verb string,
+	fn func(ctx context.Context, userId string) ([]string, error)) {
+	userId := mux.Vars(r)["id"]
+	if err := validator.New().Var(userId, "uuid"); err != nil {
+		h.writeError(w, r, uerrors.ErrUserNotFound)
+		return
+	}
+
+	roles, err := fn(r.Context(), userId)
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't %s user roles: %w", verb, err))
+		return
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(models.RolesResponse{UserId: userId, Roles: roles}); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("can't %s user roles: %+v", verb, err)
+		return
+	}
+}
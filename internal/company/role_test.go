@@ -0,0 +1,197 @@
+package company_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const roleUserId = "0b3c4a5e-1b1c-4c1d-8f5e-0c1f2a3b4c5d"
+
+func TestService_AssignRole(t *testing.T) {
+	t.Run("[Ok] Assign role writes audit event", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().WithinTransaction(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		gomock.InOrder(
+			mockRepo.EXPECT().GetUserRoles(ctx, roleUserId).Return([]string{models.RoleViewer}, nil),
+			mockRepo.EXPECT().AddUserRole(ctx, roleUserId, models.RoleAdmin).Return(nil),
+			mockRepo.EXPECT().GetUserRoles(ctx, roleUserId).Return([]string{models.RoleAdmin, models.RoleViewer}, nil),
+		)
+		mockRepo.EXPECT().CreateAuditEvent(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, e *models.AuditEvent) error {
+				assert.Equal(t, models.AuditEntityUser, e.Entity)
+				assert.Equal(t, roleUserId, e.EntityId)
+				assert.Equal(t, models.AuditActionAssignRole, e.Action)
+				assert.Contains(t, string(e.Before), `"roles":["viewer"]`)
+				assert.Contains(t, string(e.After), `"roles":["admin","viewer"]`)
+				return nil
+			})
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		roles, err := s.AssignRole(ctx, roleUserId, models.RoleAdmin)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, []string{models.RoleAdmin, models.RoleViewer}, roles)
+	})
+
+	t.Run("[Ok] Revoke missing role changes nothing", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().WithinTransaction(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		mockRepo.EXPECT().GetUserRoles(ctx, roleUserId).Return([]string{models.RoleViewer}, nil).Times(2)
+		mockRepo.EXPECT().RemoveUserRole(ctx, roleUserId, models.RoleAdmin).Return(nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		roles, err := s.RevokeRole(ctx, roleUserId, models.RoleAdmin)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, []string{models.RoleViewer}, roles)
+	})
+
+	t.Run("[Err] Unknown role", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mock_company.NewMockRepository(ctrl), auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.AssignRole(context.Background(), roleUserId, "owner")
+		assert.True(t, errors.Is(err, uerrors.ErrUnknownRole))
+	})
+
+	t.Run("[Err] Unknown user", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		expectTransaction(mockRepo)
+		mockRepo.EXPECT().GetUserRoles(gomock.Any(), roleUserId).
+			Return(nil, fmt.Errorf("Error occurs: %w", uerrors.ErrUserNotFound))
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.AssignRole(context.Background(), roleUserId, models.RoleEditor)
+		assert.True(t, errors.Is(err, uerrors.ErrUserNotFound))
+	})
+}
+
+func TestHandler_AssignRole(t *testing.T) {
+	testCases := []struct {
+		name       string
+		target     string
+		roles      []string
+		serviceErr error
+		status     int
+	}{
+		{name: "[Ok] Assign role", target: "/v1/users/" + roleUserId + "/roles/editor", roles: []string{models.RoleAdmin},
+			status: http.StatusOK},
+		{name: "[Err] Not an admin", target: "/v1/users/" + roleUserId + "/roles/editor",
+			roles: []string{models.RoleEditor}, status: http.StatusForbidden},
+		{name: "[Err] Malformed user id", target: "/v1/users/bill/roles/editor", roles: []string{models.RoleAdmin},
+			status: http.StatusNotFound},
+		{name: "[Err] Unknown user", target: "/v1/users/" + roleUserId + "/roles/editor", roles: []string{models.RoleAdmin},
+			serviceErr: fmt.Errorf("error occurs: %w", uerrors.ErrUserNotFound), status: http.StatusNotFound},
+		{name: "[Err] Unknown role", target: "/v1/users/" + roleUserId + "/roles/owner", roles: []string{models.RoleAdmin},
+			serviceErr: fmt.Errorf("error occurs: %w", uerrors.ErrUnknownRole), status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodPut, tcase.target, nil)
+			req.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: tcase.roles}, nil)
+			mockService.EXPECT().AssignRole(gomock.Any(), roleUserId, gomock.Any()).
+				Return([]string{models.RoleEditor}, tcase.serviceErr).MaxTimes(1)
+			h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.status, w.Code)
+			if tcase.status != http.StatusOK {
+				return
+			}
+
+			var resp models.RolesResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			assert.Equal(t, models.RolesResponse{UserId: roleUserId, Roles: []string{models.RoleEditor}}, resp)
+		})
+	}
+}
+
+func TestHandler_LoginUserRoles(t *testing.T) {
+	testCases := []struct {
+		name   string
+		admins []string
+		roles  []string
+	}{
+		{name: "[Ok] Stored roles", roles: []string{models.RoleViewer}},
+		{name: "[Ok] Configured administrator", admins: []string{roleUserId},
+			roles: []string{models.RoleViewer, models.RoleAdmin}},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/users/login",
+				strings.NewReader(`{"name": "bill", "password": "password"}`))
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			cfg.Auth.AccessTokenTTL = "1h"
+			cfg.Auth.Admins = tcase.admins
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().Login(gomock.Any(), &models.UserRequest{Name: "bill", Password: "password"}).
+				Return(&models.User{Id: roleUserId, Name: "bill", Roles: []string{models.RoleViewer}}, nil)
+			mockService.EXPECT().CreateRefreshToken(gomock.Any(), roleUserId).Return("refresh", nil)
+			mockService.EXPECT().CreateToken(auth.Claims{Principal: auth.User(roleUserId), Roles: tcase.roles}).Return("token", nil)
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
@@ -2,111 +2,311 @@ package company
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dkischenko/xm_app/internal/company/models"
 	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/internal/metrics"
 	"github.com/dkischenko/xm_app/pkg/auth"
 	"github.com/dkischenko/xm_app/pkg/hasher"
 	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Service struct {
-	logger       *logger.Logger
-	storage      Repository
-	tokenManager *auth.Manager
+	logger          *logger.Logger
+	storage         Repository
+	tokenManager    *auth.Manager
+	refreshTokenTTL time.Duration
 }
 
 //go:generate mockgen -source=service.go -destination=mocks/service_mock.go
 type IService interface {
-	CreateCountry(ctx context.Context, company models.CompanyCreateRequest) (id int, err error)
-	CreateCompany(ctx context.Context, company models.CompanyCreateRequest, countryId int) (id int, err error)
-	UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest) (err error)
-	DeleteCompany(ctx context.Context, companyId int) (err error)
-	GetCompanies(ctx context.Context) (companies []models.Company, err error)
+	GetCountries(ctx context.Context, region string) (countries []models.Country, err error)
+	GetCountry(ctx context.Context, ref string) (country models.Country, err error)
+	CreateCompany(ctx context.Context, company models.CompanyCreateRequest) (id int, err error)
+	UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, version int) (newVersion int, err error)
+	PatchCompany(ctx context.Context, companyId int, patch models.CompanyPatch, version int) (newVersion int, err error)
+	DeleteCompany(ctx context.Context, companyId int, version int) (err error)
+	RestoreCompany(ctx context.Context, companyId int) (newVersion int, err error)
+	PurgeCompany(ctx context.Context, companyId int, version int) (err error)
+	GetAuditEvents(ctx context.Context, filter models.AuditFilter) (page models.AuditPage, err error)
+	VerifyAuditLog(ctx context.Context) (result models.AuditVerification, err error)
+	GetCompanies(ctx context.Context, filter models.CompanyFilter) (page models.CompanyPage, err error)
 	GetCompany(ctx context.Context, companyId int) (company models.Company, err error)
+	SearchCompanies(ctx context.Context, query string, limit int) (companies []models.CompanySearchResult, err error)
 	CreateUser(ctx context.Context, user models.UserRequest) (id string, err error)
 	Login(ctx context.Context, ur *models.UserRequest) (u *models.User, err error)
-	CreateToken(uId string) (hash string, err error)
-	CheckAuth(header string) (uuid string, err error)
+	// CreateToken issues an access token with the claims.
+	CreateToken(claims auth.Claims) (hash string, err error)
+	// CheckAuth returns the claims of the access token of a "Bearer <token>" Authorization header.
+	CheckAuth(ctx context.Context, header string) (claims auth.Claims, err error)
+	// JWKS returns the public keys access tokens are verified with.
+	JWKS() auth.JWKS
+	// CreateRefreshToken issues a refresh token of the user starting a new token family.
+	CreateRefreshToken(ctx context.Context, userId string) (token string, err error)
+	// Refresh exchanges the refresh token for a new one of the same family and returns
+	// the user with its current roles. Exchanging a token twice revokes the whole family.
+	Refresh(ctx context.Context, token string) (user *models.User, newToken string, err error)
+	// Logout revokes the access token and, if given, the family of the refresh token.
+	Logout(ctx context.Context, claims auth.Claims, refreshToken string) (err error)
+	GetUserRoles(ctx context.Context, userId string) (roles []string, err error)
+	// AssignRole grants the role to the user and returns the resulting roles.
+	AssignRole(ctx context.Context, userId string, role string) (roles []string, err error)
+	// RevokeRole takes the role from the user and returns the resulting roles.
+	RevokeRole(ctx context.Context, userId string, role string) (roles []string, err error)
+	// CreateApiKey creates an API key and returns it together with the key itself, which isn't stored.
+	CreateApiKey(ctx context.Context, req models.ApiKeyCreateRequest) (key models.ApiKey, secret string, err error)
+	GetApiKeys(ctx context.Context) (keys []models.ApiKey, err error)
+	// RevokeApiKey revokes the API key and returns it.
+	RevokeApiKey(ctx context.Context, id string) (key models.ApiKey, err error)
+	// CheckApiKey returns the valid API key of an "ApiKey <key>" Authorization header and
+	// records it was used from the address.
+	CheckApiKey(ctx context.Context, header string, ip string) (key models.ApiKey, err error)
 }
 
-func NewService(logger *logger.Logger, storage Repository, tokenTTL time.Duration) IService {
-	tm, err := auth.NewManager(tokenTTL)
+// NewService returns the service issuing access tokens with the token options.
+func NewService(logger *logger.Logger, storage Repository, tokens auth.Options,
+	refreshTokenTTL time.Duration) IService {
+	tm, err := auth.NewManager(tokens, storage)
 	if err != nil {
 		logger.Entry.Errorf("error with token manager: %s", err)
 	}
 
 	return &Service{
-		tokenManager: tm,
-		logger:       logger,
-		storage:      storage,
+		tokenManager:    tm,
+		logger:          logger,
+		storage:         storage,
+		refreshTokenTTL: refreshTokenTTL,
 	}
 }
 
-func (s Service) CheckAuth(header string) (uuid string, err error) {
-	headerString := strings.Split(header, " ")
-	if len(headerString[1]) == 0 {
-		return "", fmt.Errorf("error occurs: %w", uerrors.ErrEmptyToken)
+func (s Service) CheckAuth(ctx context.Context, header string) (claims auth.Claims, err error) {
+	scheme, token, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return claims, fmt.Errorf("error occurs: %w", uerrors.ErrAuthScheme)
+	}
+	token = strings.TrimSpace(token)
+	if len(token) == 0 {
+		return claims, fmt.Errorf("error occurs: %w", uerrors.ErrEmptyToken)
+	}
+	claims, err = s.tokenManager.ParseJWT(ctx, token)
+	if errors.Is(err, auth.ErrRevoked) {
+		return claims, fmt.Errorf("error occurs: %w", uerrors.ErrTokenRevoked)
+	}
+	if errors.Is(err, auth.ErrDenylist) {
+		return claims, fmt.Errorf("error occurs: %w: %v", uerrors.ErrUnavailable, err)
 	}
-	uuid, err = s.tokenManager.ParseJWT(headerString[1])
 	if err != nil {
-		return "", fmt.Errorf("error occurs: %w", uerrors.ErrParseToken)
+		return claims, fmt.Errorf("error occurs: %w", uerrors.ErrParseToken.WithDetail("%v", err))
 	}
-	return uuid, nil
+	return claims, nil
 }
 
-func (s Service) CreateCountry(ctx context.Context, company models.CompanyCreateRequest) (id int, err error) {
-	id, err = s.storage.CreateCountry(ctx, company)
+func (s Service) CreateCompany(ctx context.Context, company models.CompanyCreateRequest) (id int, err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		country, err := s.storage.FindCountry(ctx, company.Country)
+		if err != nil {
+			return err
+		}
+		if id, err = s.storage.Create(ctx, company, country.Id); err != nil {
+			return err
+		}
+		after, err := s.storage.GetCompany(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityCompany, strconv.Itoa(id), models.AuditActionCreate, nil, after)
+	})
+	if errors.Is(err, uerrors.ErrCountryNotFound) {
+		s.logger.Ctx(ctx).Errorf("failed to create company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCountryNotFound.WithDetail("%q", company.Country))
+	}
 	if err != nil {
-		s.logger.Entry.Errorf("failed to create country: %s", err)
-		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCreateCountry)
+		s.logger.Ctx(ctx).Errorf("failed to create company: %s", err)
+		return 0, storageError(err, uerrors.ErrCreateCompany)
 	}
 	return
 }
 
-func (s Service) CreateCompany(ctx context.Context, company models.CompanyCreateRequest, countryId int) (id int, err error) {
-	id, err = s.storage.Create(ctx, company, countryId)
+func (s Service) UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest, version int) (newVersion int, err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		before, err := s.storage.GetCompany(ctx, companyId)
+		if err != nil {
+			return err
+		}
+		country, err := s.storage.FindCountry(ctx, company.Country)
+		if err != nil {
+			return err
+		}
+		if newVersion, err = s.storage.Update(ctx, companyId, company, country.Id, version); err != nil {
+			return err
+		}
+		after, err := s.storage.GetCompany(ctx, companyId)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityCompany, strconv.Itoa(companyId), models.AuditActionUpdate, before, after)
+	})
+	if errors.Is(err, uerrors.ErrVersionMismatch) {
+		s.logger.Ctx(ctx).Errorf("failed to update company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrVersionMismatch)
+	}
+	if errors.Is(err, uerrors.ErrCountryNotFound) {
+		s.logger.Ctx(ctx).Errorf("failed to update company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCountryNotFound.WithDetail("%q", company.Country))
+	}
+	if errors.Is(err, uerrors.ErrCompanyNotFound) {
+		s.logger.Ctx(ctx).Errorf("failed to update company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound)
+	}
 	if err != nil {
-		s.logger.Entry.Errorf("failed to create company: %s", err)
-		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCreateCompany)
+		s.logger.Ctx(ctx).Errorf("failed to update company: %s", err)
+		return 0, storageError(err, uerrors.ErrUpdateCompany)
 	}
 	return
 }
 
-func (s Service) UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest) (err error) {
-	err = s.storage.Update(ctx, companyId, company)
+// PatchCompany applies the patch to the current state of the company. The write is
+// conditional on the version that was read, so concurrent updates are never lost.
+func (s Service) PatchCompany(ctx context.Context, companyId int, patch models.CompanyPatch, version int) (newVersion int, err error) {
+	company, err := s.GetCompany(ctx, companyId)
+	if err != nil {
+		return 0, err
+	}
+	if version != 0 && company.Version != version {
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrVersionMismatch)
+	}
+
+	update, err := applyCompanyPatch(company.UpdateRequest(), patch)
 	if err != nil {
-		s.logger.Entry.Errorf("failed to update company: %s", err)
-		return fmt.Errorf("error occurs: %w", uerrors.ErrUpdateCompany)
+		s.logger.Ctx(ctx).Errorf("failed to patch company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", err)
+	}
+
+	if err = validator.New().Struct(update); err != nil {
+		s.logger.Ctx(ctx).Errorf("patched company is invalid: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrValidateCompany.WithDetail("%s", err))
+	}
+
+	return s.UpdateCompany(ctx, companyId, &update, company.Version)
+}
+
+func (s Service) DeleteCompany(ctx context.Context, companyId int, version int) (err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		before, err := s.storage.GetCompany(ctx, companyId)
+		if err != nil {
+			return err
+		}
+		if err = s.storage.Delete(ctx, companyId, version); err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityCompany, strconv.Itoa(companyId), models.AuditActionDelete, before, nil)
+	})
+	if errors.Is(err, uerrors.ErrVersionMismatch) {
+		s.logger.Ctx(ctx).Errorf("failed to delete company: %s", err)
+		return fmt.Errorf("error occurs: %w", uerrors.ErrVersionMismatch)
+	}
+	if errors.Is(err, uerrors.ErrCompanyNotFound) {
+		s.logger.Ctx(ctx).Errorf("failed to delete company: %s", err)
+		return fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to delete company: %s", err)
+		return storageError(err, uerrors.ErrDeleteCompany)
 	}
 	return
 }
 
-func (s Service) DeleteCompany(ctx context.Context, companyId int) (err error) {
-	err = s.storage.Delete(ctx, companyId)
+func (s Service) RestoreCompany(ctx context.Context, companyId int) (newVersion int, err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		if newVersion, err = s.storage.Restore(ctx, companyId); err != nil {
+			return err
+		}
+		after, err := s.storage.GetCompany(ctx, companyId)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityCompany, strconv.Itoa(companyId), models.AuditActionRestore, nil, after)
+	})
+	if errors.Is(err, uerrors.ErrCompanyNotDeleted) {
+		s.logger.Ctx(ctx).Errorf("failed to restore company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotDeleted)
+	}
+	if errors.Is(err, uerrors.ErrCompanyNotFound) {
+		s.logger.Ctx(ctx).Errorf("failed to restore company: %s", err)
+		return 0, fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound)
+	}
 	if err != nil {
-		s.logger.Entry.Errorf("failed to create country: %s", err)
-		return fmt.Errorf("error occurs: %w", uerrors.ErrDeleteCompany)
+		s.logger.Ctx(ctx).Errorf("failed to restore company: %s", err)
+		return 0, storageError(err, uerrors.ErrRestoreCompany)
+	}
+	return
+}
+
+func (s Service) PurgeCompany(ctx context.Context, companyId int, version int) (err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		before, err := s.storage.Purge(ctx, companyId, version)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditEntityCompany, strconv.Itoa(companyId), models.AuditActionPurge, before, nil)
+	})
+	if errors.Is(err, uerrors.ErrVersionMismatch) {
+		s.logger.Ctx(ctx).Errorf("failed to purge company: %s", err)
+		return fmt.Errorf("error occurs: %w", uerrors.ErrVersionMismatch)
+	}
+	if errors.Is(err, uerrors.ErrCompanyNotFound) {
+		s.logger.Ctx(ctx).Errorf("failed to purge company: %s", err)
+		return fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to purge company: %s", err)
+		return storageError(err, uerrors.ErrDeleteCompany)
 	}
 	return
 }
 
 func (s Service) GetCompany(ctx context.Context, cId int) (company models.Company, err error) {
 	company, err = s.storage.GetCompany(ctx, cId)
+	if errors.Is(err, uerrors.ErrCompanyNotFound) {
+		return company, fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound)
+	}
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to get companies: %s", err)
+		return company, storageError(err, uerrors.ErrGetCompany)
+	}
+	return
+}
+
+func (s Service) GetCompanies(ctx context.Context, filter models.CompanyFilter) (page models.CompanyPage, err error) {
+	companies, err := s.storage.GetList(ctx, filter)
 	if err != nil {
-		s.logger.Entry.Errorf("failed to get companies: %s", err)
-		return company, fmt.Errorf("error occurs: %w", uerrors.ErrGetCompany)
+		s.logger.Ctx(ctx).Errorf("failed to get companies: %s", err)
+		return page, storageError(err, uerrors.ErrGetCompanies)
+	}
+
+	page.Companies = make([]models.Company, 0, len(companies))
+	if len(companies) > filter.Limit {
+		companies = companies[:filter.Limit]
+		page.NextCursor = models.NewCompanyCursor(companies[len(companies)-1], filter).Encode()
 	}
+	page.Companies = append(page.Companies, companies...)
+
 	return
 }
 
-func (s Service) GetCompanies(ctx context.Context) (companies []models.Company, err error) {
-	companies, err = s.storage.GetList(ctx)
+func (s Service) SearchCompanies(ctx context.Context, query string, limit int) (companies []models.CompanySearchResult, err error) {
+	companies, err = s.storage.Search(ctx, query, limit)
 	if err != nil {
-		s.logger.Entry.Errorf("failed to get companies: %s", err)
-		return companies, fmt.Errorf("error occurs: %w", uerrors.ErrGetCompanies)
+		s.logger.Ctx(ctx).Errorf("failed to search companies: %s", err)
+		return nil, storageError(err, uerrors.ErrSearchCompanies)
+	}
+	if companies == nil {
+		companies = []models.CompanySearchResult{}
 	}
 	return
 }
@@ -114,7 +314,7 @@ func (s Service) GetCompanies(ctx context.Context) (companies []models.Company,
 func (s Service) CreateUser(ctx context.Context, user models.UserRequest) (id string, err error) {
 	hashPassword, err := hasher.HashPassword(user.Password)
 	if err != nil {
-		s.logger.Entry.Errorf("troubles with hashing password: %s", user.Password)
+		s.logger.Ctx(ctx).Errorf("troubles with hashing password: %s", user.Password)
 		return "", err
 	}
 	usr := &models.User{
@@ -122,7 +322,16 @@ func (s Service) CreateUser(ctx context.Context, user models.UserRequest) (id st
 		PasswordHash: hashPassword,
 	}
 
-	id, err = s.storage.CreateUser(ctx, usr)
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		if id, err = s.storage.CreateUser(ctx, usr); err != nil {
+			return err
+		}
+		if err = s.storage.AddUserRole(ctx, id, models.DefaultRole); err != nil {
+			return err
+		}
+		after := models.UserCreateResponse{ID: id, Name: usr.Name}
+		return s.audit(ctx, models.AuditEntityUser, id, models.AuditActionCreate, nil, after)
+	})
 
 	if err != nil {
 		return id, err
@@ -133,25 +342,47 @@ func (s Service) CreateUser(ctx context.Context, user models.UserRequest) (id st
 
 func (s Service) Login(ctx context.Context, ur *models.UserRequest) (u *models.User, err error) {
 	u, err = s.storage.FindOneUser(ctx, ur.Name)
+	if errors.Is(err, uerrors.ErrUserNotFound) {
+		s.logger.Ctx(ctx).Infof("login of unknown user %q", ur.Name)
+		metrics.ObserveLogin(metrics.LoginRejected)
+		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidCredentials)
+	}
 	if err != nil {
-		s.logger.Entry.Errorf("failed find user with error: %s", err)
-		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrFindOneUser)
+		s.logger.Ctx(ctx).Errorf("failed find user with error: %s", err)
+		metrics.ObserveLogin(metrics.LoginFailed)
+		return nil, storageError(err, uerrors.ErrFindOneUser)
 	}
 
 	if !hasher.CheckPasswordHash(u.PasswordHash, ur.Password) {
-		s.logger.Entry.Errorf("user used wrong password: %s", err)
-		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrCheckUserPasswordHash)
+		s.logger.Ctx(ctx).Infof("user %q used wrong password", ur.Name)
+		metrics.ObserveLogin(metrics.LoginRejected)
+		return nil, fmt.Errorf("error occurs: %w", uerrors.ErrInvalidCredentials)
 	}
 
+	metrics.ObserveLogin(metrics.LoginSucceeded)
 	return
 }
 
-func (s Service) CreateToken(uId string) (hash string, err error) {
-	hash, err = s.tokenManager.CreateJWT(uId)
+func (s Service) CreateToken(claims auth.Claims) (hash string, err error) {
+	hash, err = s.tokenManager.CreateJWT(claims)
 	if err != nil {
 		s.logger.Entry.Errorf("problems with creating jwt token: %s", err)
 		return "", fmt.Errorf("error occurs: %w", uerrors.ErrCreateJWTToken)
 	}
 
+	metrics.ObserveToken(claims.Principal.Type)
 	return
 }
+
+func (s Service) JWKS() auth.JWKS {
+	return s.tokenManager.JWKS()
+}
+
+// storageError returns the error of a failed repository call carrying the sentinel, or
+// uerrors.ErrUnavailable when the database couldn't be reached.
+func storageError(err error, sentinel error) error {
+	if errors.Is(err, uerrors.ErrUnavailable) {
+		sentinel = uerrors.ErrUnavailable
+	}
+	return fmt.Errorf("error occurs: %w", sentinel)
+}
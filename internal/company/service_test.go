@@ -17,61 +17,54 @@ import (
 	"time"
 )
 
+var cyprus = models.Country{Id: 55, Alpha2: "CY", Alpha3: "CYP", Numeric: "196", Name: "Cyprus", Region: "Asia"}
+
+// expectTransaction makes the repository mock run transactions inline and accept audit events.
+func expectTransaction(mockRepo *mock_company.MockRepository) {
+	mockRepo.EXPECT().WithinTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		}).AnyTimes()
+	mockRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+}
+
 func TestNewService(t *testing.T) {
 	l, _ := logger.GetLogger()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockRepo := mock_company.NewMockRepository(ctrl)
-	assert.NotNil(t, company.NewService(l, mockRepo, 3600))
+	assert.NotNil(t, company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600}, 24*time.Hour))
 }
 
-func TestService_CreateCountry(t *testing.T) {
-	t.Run("Create country", func(t *testing.T) {
+func TestService_GetCountry(t *testing.T) {
+	t.Run("Get country", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockRepo := mock_company.NewMockRepository(ctrl)
-		cmp := models.CompanyCreateRequest{
-			Name:    "test",
-			Code:    12345,
-			Country: "Ukr",
-			Website: "https://example.com",
-			Phone:   "+380662342437",
-		}
-		mockRepo.EXPECT().CreateCountry(context.Background(), cmp).Return(1, nil).AnyTimes()
+		mockRepo.EXPECT().FindCountry(context.Background(), "cy").Return(cyprus, nil)
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		id, err := s.CreateCountry(context.Background(), cmp)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		country, err := s.GetCountry(context.Background(), "cy")
 		if err != nil {
-			t.Fatalf("Cannot store user via service due error: %s", err)
+			t.Fatalf("Unexpected error: %s", err)
 		}
-		assert.NotNil(t, id, "Country id can't be nil")
+		assert.Equal(t, cyprus, country)
 	})
 }
 
-func TestService_CreateCountryErr(t *testing.T) {
-	t.Run("Create country Err", func(t *testing.T) {
+func TestService_GetCountryErr(t *testing.T) {
+	t.Run("Get unknown country", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
 		mockRepo := mock_company.NewMockRepository(ctrl)
-		cmp := models.CompanyCreateRequest{
-			Name:    "test",
-			Code:    12345,
-			Country: "Ukr",
-			Website: "https://example.com",
-			Phone:   "+380662342437",
-		}
-		mockRepo.EXPECT().CreateCountry(context.Background(), cmp).
-			Return(0, fmt.Errorf("Error occurs: %w", uerrors.ErrCreateCountry)).AnyTimes()
+		mockRepo.EXPECT().FindCountry(context.Background(), "Atlantis").
+			Return(models.Country{}, fmt.Errorf("Error occurs: %w", uerrors.ErrCountryNotFound))
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		_, err := s.CreateCountry(context.Background(), cmp)
-		if err != nil {
-			assert.ErrorIs(t, err, uerrors.ErrCreateCountry)
-		} else {
-			t.Fatalf("Unexpected error: %s", err)
-		}
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.GetCountry(context.Background(), "Atlantis")
+		assert.ErrorIs(t, err, uerrors.ErrCountryNotFound)
 	})
 }
 
@@ -94,7 +87,7 @@ func TestService_Login(t *testing.T) {
 			Name:         ur.Name,
 			PasswordHash: hash,
 		}, nil).AnyTimes()
-		service := company.NewService(l, mockRepo, 3600)
+		service := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600}, 24*time.Hour)
 		u, err := mockRepo.FindOneUser(ctx, ur.Name)
 		if err != nil {
 			t.Fatalf("Can't find user with credentials due error: %s", err)
@@ -124,7 +117,7 @@ func TestService_LoginFindOneError(t *testing.T) {
 			Return(nil, fmt.Errorf("Error occurs: %w", uerrors.ErrFindOneUser)).AnyTimes()
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600}, 24*time.Hour)
 		ur := &models.UserRequest{
 			Name:     "Bob",
 			Password: "password",
@@ -156,19 +149,80 @@ func TestService_CheckAuth(t *testing.T) {
 		mockRepo := mock_company.NewMockRepository(ctrl)
 		mockRepo.EXPECT().FindOneUser(ctx, usr.Id).
 			Return(usr, nil).AnyTimes()
+		mockRepo.EXPECT().IsTokenRevoked(ctx, gomock.Any()).Return(false, nil)
 
-		authRepo, _ := auth.NewManager(3600 * time.Second)
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		token, _ := authRepo.CreateJWT(usr.Id)
+		authRepo, _ := auth.NewManager(auth.Options{TokenTTL: 3600 * time.Second}, nil)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		token, _ := authRepo.CreateJWT(auth.Claims{Principal: auth.User(usr.Id), Roles: []string{models.RoleEditor}})
 
-		//_, err := authRepo.ParseJWT(token)
 		header := "Bearer " + token
-		uId, err := s.CheckAuth(header)
-		assert.NotNil(t, uId)
+		claims, err := s.CheckAuth(ctx, header)
+		assert.Equal(t, auth.User(usr.Id), claims.Principal)
+		assert.Equal(t, []string{models.RoleEditor}, claims.Roles)
+		assert.NotEmpty(t, claims.TokenId)
 		if err != nil {
 			t.Fatalf("unexpected error")
 		}
 	})
+
+	t.Run("[Err] Revoked token", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		l, _ := logger.GetLogger()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		authRepo, _ := auth.NewManager(auth.Options{TokenTTL: 3600 * time.Second}, nil)
+		token, _ := authRepo.CreateJWT(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}})
+		mockRepo.EXPECT().IsTokenRevoked(ctx, gomock.Any()).Return(true, nil)
+
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.CheckAuth(ctx, "Bearer "+token)
+		assert.True(t, errors.Is(err, uerrors.ErrTokenRevoked))
+	})
+
+	t.Run("[Err] Denylist unavailable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		l, _ := logger.GetLogger()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		authRepo, _ := auth.NewManager(auth.Options{TokenTTL: 3600 * time.Second}, nil)
+		token, _ := authRepo.CreateJWT(auth.Claims{Principal: auth.User("c9f44c4a-788a-4d5f-a210-94ccafcc2231"), Roles: []string{models.RoleEditor}})
+		mockRepo.EXPECT().IsTokenRevoked(ctx, gomock.Any()).Return(false, errors.New("connection refused"))
+
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.CheckAuth(ctx, "Bearer "+token)
+		assert.ErrorIs(t, err, uerrors.ErrUnavailable)
+		assert.NotErrorIs(t, err, uerrors.ErrParseToken)
+	})
+}
+
+func TestService_CheckAuthMalformed(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		err    error
+	}{
+		{name: "[Err] Scheme only", header: "Bearer", err: uerrors.ErrEmptyToken},
+		{name: "[Err] Empty token", header: "Bearer ", err: uerrors.ErrEmptyToken},
+		{name: "[Err] Token only", header: "token", err: uerrors.ErrAuthScheme},
+		{name: "[Err] Other scheme", header: "Basic dXNlcjpwYXNz", err: uerrors.ErrAuthScheme},
+		{name: "[Err] Invalid token", header: "bearer token", err: uerrors.ErrParseToken},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			l, _ := logger.GetLogger()
+			s := company.NewService(l, mock_company.NewMockRepository(ctrl), auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+			_, err := s.CheckAuth(context.Background(), tcase.header)
+			assert.True(t, errors.Is(err, tcase.err))
+		})
+	}
 }
 
 func TestService_CreateCompany(t *testing.T) {
@@ -180,14 +234,17 @@ func TestService_CreateCompany(t *testing.T) {
 		cmp := models.CompanyCreateRequest{
 			Name:    "test",
 			Code:    12345,
-			Country: "Ukr",
+			Country: "CY",
 			Website: "https://example.com",
 			Phone:   "+380662342437",
 		}
-		mockRepo.EXPECT().Create(context.Background(), cmp, 1).Return(1, nil).AnyTimes()
+		mockRepo.EXPECT().FindCountry(context.Background(), "CY").Return(cyprus, nil)
+		mockRepo.EXPECT().Create(context.Background(), cmp, cyprus.Id).Return(1, nil).AnyTimes()
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1}, nil).AnyTimes()
+		expectTransaction(mockRepo)
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		id, err := s.CreateCompany(context.Background(), cmp, 1)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		id, err := s.CreateCompany(context.Background(), cmp)
 		if err != nil {
 			t.Fatalf("Cannot store company via service due error: %s", err)
 		}
@@ -204,15 +261,17 @@ func TestService_CreateCompanyErr(t *testing.T) {
 		cmp := models.CompanyCreateRequest{
 			Name:    "test",
 			Code:    12345,
-			Country: "Ukr",
+			Country: "CY",
 			Website: "https://example.com",
 			Phone:   "+380662342437",
 		}
-		mockRepo.EXPECT().Create(context.Background(), cmp, 1).Return(0,
+		mockRepo.EXPECT().FindCountry(context.Background(), "CY").Return(cyprus, nil)
+		mockRepo.EXPECT().Create(context.Background(), cmp, cyprus.Id).Return(0,
 			fmt.Errorf("Error occurs: %w", uerrors.ErrCreateCompany)).AnyTimes()
+		expectTransaction(mockRepo)
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		_, err := s.CreateCompany(context.Background(), cmp, 1)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.CreateCompany(context.Background(), cmp)
 		if err != nil {
 			assert.ErrorIs(t, err, uerrors.ErrCreateCompany)
 		} else {
@@ -221,6 +280,23 @@ func TestService_CreateCompanyErr(t *testing.T) {
 	})
 }
 
+func TestService_CreateCompanyUnknownCountry(t *testing.T) {
+	t.Run("Create company in unknown country", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		cmp := models.CompanyCreateRequest{Name: "test", Country: "Atlantis"}
+		mockRepo.EXPECT().FindCountry(context.Background(), "Atlantis").
+			Return(models.Country{}, fmt.Errorf("Error occurs: %w", uerrors.ErrCountryNotFound))
+		expectTransaction(mockRepo)
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.CreateCompany(context.Background(), cmp)
+		assert.ErrorIs(t, err, uerrors.ErrCountryNotFound)
+	})
+}
+
 func TestService_UpdateCompany(t *testing.T) {
 	t.Run("Update company", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
@@ -228,20 +304,24 @@ func TestService_UpdateCompany(t *testing.T) {
 
 		mockRepo := mock_company.NewMockRepository(ctrl)
 		cmp := &models.CompanyUpdateRequest{
-			Name:      "test",
-			Code:      12345,
-			CountryId: 1,
-			Website:   "https://example.com",
-			Phone:     "+380662342437",
+			Name:    "test",
+			Code:    12345,
+			Country: "CY",
+			Website: "https://example.com",
+			Phone:   "+380662342437",
 		}
 
-		mockRepo.EXPECT().Update(context.Background(), 1, cmp).Return(nil)
+		mockRepo.EXPECT().FindCountry(context.Background(), "CY").Return(cyprus, nil)
+		mockRepo.EXPECT().Update(context.Background(), 1, cmp, cyprus.Id, 0).Return(2, nil)
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1}, nil).AnyTimes()
+		expectTransaction(mockRepo)
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		err := s.UpdateCompany(context.Background(), 1, cmp)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		version, err := s.UpdateCompany(context.Background(), 1, cmp, 0)
 		if err != nil {
 			t.Fatalf("Cannot update company via service due error: %s", err)
 		}
+		assert.Equal(t, 2, version)
 	})
 }
 
@@ -252,18 +332,21 @@ func TestService_UpdateCompanyErr(t *testing.T) {
 
 		mockRepo := mock_company.NewMockRepository(ctrl)
 		cmp := &models.CompanyUpdateRequest{
-			Name:      "test",
-			Code:      12345,
-			CountryId: 1,
-			Website:   "https://example.com",
-			Phone:     "+380662342437",
+			Name:    "test",
+			Code:    12345,
+			Country: "CY",
+			Website: "https://example.com",
+			Phone:   "+380662342437",
 		}
 
-		mockRepo.EXPECT().Update(context.Background(), 1, cmp).
-			Return(fmt.Errorf("Error occurs: %w", uerrors.ErrUpdateCompany))
+		mockRepo.EXPECT().FindCountry(context.Background(), "CY").Return(cyprus, nil)
+		mockRepo.EXPECT().Update(context.Background(), 1, cmp, cyprus.Id, 0).
+			Return(0, fmt.Errorf("Error occurs: %w", uerrors.ErrUpdateCompany))
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1}, nil).AnyTimes()
+		expectTransaction(mockRepo)
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		err := s.UpdateCompany(context.Background(), 1, cmp)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.UpdateCompany(context.Background(), 1, cmp, 0)
 		if err != nil {
 			assert.ErrorIs(t, err, uerrors.ErrUpdateCompany)
 		} else {
@@ -272,17 +355,143 @@ func TestService_UpdateCompanyErr(t *testing.T) {
 	})
 }
 
+func TestService_UpdateCompanyVersionMismatch(t *testing.T) {
+	t.Run("Update company version mismatch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		cmp := &models.CompanyUpdateRequest{Name: "test", Country: "CY"}
+		mockRepo.EXPECT().FindCountry(context.Background(), "CY").Return(cyprus, nil)
+		mockRepo.EXPECT().Update(context.Background(), 1, cmp, cyprus.Id, 3).
+			Return(0, fmt.Errorf("Error occurs: %w", uerrors.ErrVersionMismatch))
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1}, nil).AnyTimes()
+		expectTransaction(mockRepo)
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.UpdateCompany(context.Background(), 1, cmp, 3)
+		assert.ErrorIs(t, err, uerrors.ErrVersionMismatch)
+	})
+}
+
+func TestService_PatchCompany(t *testing.T) {
+	current := models.Company{
+		Id:      1,
+		Name:    "Test",
+		Code:    1231432,
+		Country: cyprus,
+		Website: "https://example.com",
+		Phone:   "+3806678934556",
+		Version: 5,
+	}
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		version     int
+		want        *models.CompanyUpdateRequest
+		wantErr     error
+	}{
+		{
+			name:        "Merge patch changes only supplied fields",
+			contentType: models.MediaTypeMergePatch,
+			body:        `{"name": "Renamed", "phone": "+35722000000"}`,
+			want: &models.CompanyUpdateRequest{
+				Name:    "Renamed",
+				Code:    1231432,
+				Country: "CY",
+				Website: "https://example.com",
+				Phone:   "+35722000000",
+			},
+		},
+		{
+			name:        "JSON patch",
+			contentType: models.MediaTypeJSONPatch,
+			body:        `[{"op": "test", "path": "/name", "value": "Test"}, {"op": "replace", "path": "/code", "value": 42}]`,
+			want: &models.CompanyUpdateRequest{
+				Name:    "Test",
+				Code:    42,
+				Country: "CY",
+				Website: "https://example.com",
+				Phone:   "+3806678934556",
+			},
+		},
+		{
+			name:        "Merge patch removing required field",
+			contentType: models.MediaTypeMergePatch,
+			body:        `{"country": null}`,
+			wantErr:     uerrors.ErrValidateCompany,
+		},
+		{
+			name:        "Merge patch with unknown field",
+			contentType: models.MediaTypeMergePatch,
+			body:        `{"id": 2}`,
+			wantErr:     uerrors.ErrApplyPatch,
+		},
+		{
+			name:        "JSON patch failed test",
+			contentType: models.MediaTypeJSONPatch,
+			body:        `[{"op": "test", "path": "/name", "value": "Other"}]`,
+			wantErr:     uerrors.ErrApplyPatch,
+		},
+		{
+			name:        "Stale version",
+			contentType: models.MediaTypeMergePatch,
+			body:        `{"name": "Renamed"}`,
+			version:     4,
+			wantErr:     uerrors.ErrVersionMismatch,
+		},
+		{
+			name:        "Unsupported media type",
+			contentType: "application/json",
+			body:        `{"name": "Renamed"}`,
+			wantErr:     uerrors.ErrUnsupportedPatch,
+		},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mock_company.NewMockRepository(ctrl)
+			mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(current, nil).AnyTimes()
+			expectTransaction(mockRepo)
+			if tcase.want != nil {
+				mockRepo.EXPECT().FindCountry(context.Background(), "CY").Return(cyprus, nil)
+				mockRepo.EXPECT().Update(context.Background(), 1, tcase.want, cyprus.Id, current.Version).
+					Return(current.Version+1, nil)
+			}
+
+			l, _ := logger.GetLogger()
+			s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+			_, err := s.PatchCompany(context.Background(), 1, models.CompanyPatch{
+				ContentType: tcase.contentType,
+				Body:        []byte(tcase.body),
+			}, tcase.version)
+			if tcase.wantErr != nil {
+				assert.ErrorIs(t, err, tcase.wantErr)
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
 func TestService_DeleteCompany(t *testing.T) {
 	t.Run("Delete company", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockRepo := mock_company.NewMockRepository(ctrl)
-		mockRepo.EXPECT().Delete(context.Background(), 1).Return(nil).AnyTimes()
+		mockRepo.EXPECT().Delete(context.Background(), 1, 0).Return(nil).AnyTimes()
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1}, nil).AnyTimes()
+		expectTransaction(mockRepo)
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
 
-		err := s.DeleteCompany(context.Background(), 1)
+		err := s.DeleteCompany(context.Background(), 1, 0)
 		if err != nil {
 			t.Fatalf("Cannot delete company via service due error: %s", err)
 		}
@@ -294,13 +503,15 @@ func TestService_DeleteCompanyErr(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		mockRepo := mock_company.NewMockRepository(ctrl)
-		mockRepo.EXPECT().Delete(context.Background(), 1).
+		mockRepo.EXPECT().Delete(context.Background(), 1, 0).
 			Return(fmt.Errorf("Error occurs: %w", uerrors.ErrDeleteCompany)).AnyTimes()
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1}, nil).AnyTimes()
+		expectTransaction(mockRepo)
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
 
-		err := s.DeleteCompany(context.Background(), 1)
+		err := s.DeleteCompany(context.Background(), 1, 0)
 		if err != nil {
 			assert.ErrorIs(t, err, uerrors.ErrDeleteCompany)
 		} else {
@@ -309,6 +520,55 @@ func TestService_DeleteCompanyErr(t *testing.T) {
 	})
 }
 
+func TestService_RestoreCompany(t *testing.T) {
+	t.Run("Restore company", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Restore(context.Background(), 1).Return(4, nil)
+		mockRepo.EXPECT().GetCompany(context.Background(), 1).Return(models.Company{Id: 1, Version: 4}, nil)
+		expectTransaction(mockRepo)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		version, err := s.RestoreCompany(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, 4, version)
+	})
+
+	t.Run("Restore company not deleted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Restore(context.Background(), 1).
+			Return(0, fmt.Errorf("Error occurs: %w", uerrors.ErrCompanyNotDeleted))
+		expectTransaction(mockRepo)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.RestoreCompany(context.Background(), 1)
+		assert.ErrorIs(t, err, uerrors.ErrCompanyNotDeleted)
+	})
+}
+
+func TestService_PurgeCompany(t *testing.T) {
+	t.Run("Purge company err", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Purge(context.Background(), 1, 2).
+			Return(models.Company{}, fmt.Errorf("Error occurs: %w", uerrors.ErrVersionMismatch))
+		expectTransaction(mockRepo)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		err := s.PurgeCompany(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, uerrors.ErrVersionMismatch)
+	})
+}
+
 func TestService_GetCompany(t *testing.T) {
 	t.Run("Get company", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
@@ -319,7 +579,7 @@ func TestService_GetCompany(t *testing.T) {
 			Id:        1,
 			Name:      "Test",
 			Code:      1231432,
-			CountryId: 1,
+			Country:   cyprus,
 			Website:   "https://example.com",
 			Phone:     "+3806678934556",
 			CreatedAt: 1650995663,
@@ -327,7 +587,7 @@ func TestService_GetCompany(t *testing.T) {
 		}, nil).AnyTimes()
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
 		cmp, err := s.GetCompany(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("Unexpected error: %s", err)
@@ -346,7 +606,7 @@ func TestService_GetCompanyErr(t *testing.T) {
 			Return(models.Company{}, fmt.Errorf("Error occurs: %w", uerrors.ErrGetCompany)).AnyTimes()
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
 		_, err := s.GetCompany(context.Background(), 1)
 		if err != nil {
 			assert.ErrorIs(t, err, uerrors.ErrGetCompany)
@@ -367,7 +627,7 @@ func TestService_GetCompanies(t *testing.T) {
 			Id:        1,
 			Name:      "Test",
 			Code:      1231432,
-			CountryId: 1,
+			Country:   cyprus,
 			Website:   "https://example.com",
 			Phone:     "+3806678934556",
 			CreatedAt: 1650995663,
@@ -377,22 +637,55 @@ func TestService_GetCompanies(t *testing.T) {
 			Id:        2,
 			Name:      "Very test",
 			Code:      1231432,
-			CountryId: 1,
+			Country:   cyprus,
 			Website:   "https://example.com",
 			Phone:     "+3806678934556",
 			CreatedAt: 1650995663,
 			UpdatedAt: 1651002057,
 		}
-		mockRepo.EXPECT().GetList(context.Background()).Return(companies, nil).AnyTimes()
+		filter := models.CompanyFilter{Limit: 2, Sort: models.SortById}
+		mockRepo.EXPECT().GetList(context.Background(), filter).Return(companies, nil).AnyTimes()
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		cmp, err := s.GetCompanies(context.Background())
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		page, err := s.GetCompanies(context.Background(), filter)
 		if err != nil {
 			t.Fatalf("Unexpected error: %s", err)
 		}
-		assert.NotNil(t, cmp)
-		assert.Equal(t, len(cmp), 2)
+		assert.NotNil(t, page.Companies)
+		assert.Equal(t, len(page.Companies), 2)
+		assert.Empty(t, page.NextCursor)
+	})
+}
+
+func TestService_GetCompaniesNextCursor(t *testing.T) {
+	t.Run("Get companies next cursor", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		companies := []models.Company{
+			{Id: 1, Name: "Alpha", UpdatedAt: 1651002057},
+			{Id: 2, Name: "Beta", UpdatedAt: 1651002058},
+			{Id: 3, Name: "Gamma", UpdatedAt: 1651002059},
+		}
+		filter := models.CompanyFilter{Limit: 2, Sort: models.SortByName, Desc: true}
+		mockRepo.EXPECT().GetList(context.Background(), filter).Return(companies, nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		page, err := s.GetCompanies(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, 2, len(page.Companies))
+
+		cursor, err := models.DecodeCompanyCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, models.CompanyCursor{Sort: models.SortByName, Desc: true, Value: "Beta", Id: 2}, *cursor)
+		assert.True(t, cursor.Matches(filter))
 	})
 }
 
@@ -402,12 +695,13 @@ func TestService_GetCompaniesErr(t *testing.T) {
 		defer ctrl.Finish()
 
 		mockRepo := mock_company.NewMockRepository(ctrl)
-		mockRepo.EXPECT().GetList(context.Background()).
+		filter := models.CompanyFilter{Limit: 20, Sort: models.SortById}
+		mockRepo.EXPECT().GetList(context.Background(), filter).
 			Return(nil, fmt.Errorf("Error occurs: %w", uerrors.ErrGetCompanies)).AnyTimes()
 
 		l, _ := logger.GetLogger()
-		s := company.NewService(l, mockRepo, 3600*time.Second)
-		_, err := s.GetCompanies(context.Background())
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.GetCompanies(context.Background(), filter)
 		if err != nil {
 			assert.ErrorIs(t, err, uerrors.ErrGetCompanies)
 		} else {
@@ -416,6 +710,42 @@ func TestService_GetCompaniesErr(t *testing.T) {
 	})
 }
 
+func TestService_SearchCompanies(t *testing.T) {
+	t.Run("Search companies", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Search(context.Background(), "acme", 10).Return([]models.CompanySearchResult{
+			{Company: models.Company{Id: 1, Name: "Acme"}, Score: 1.2},
+			{Company: models.Company{Id: 2, Name: "Acne"}, Score: 0.4},
+		}, nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		res, err := s.SearchCompanies(context.Background(), "acme", 10)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, 2, len(res))
+		assert.Equal(t, "Acme", res[0].Name)
+	})
+
+	t.Run("Search companies err", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Search(context.Background(), "acme", 10).
+			Return(nil, fmt.Errorf("Error occurs: %w", uerrors.ErrSearchCompanies))
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, err := s.SearchCompanies(context.Background(), "acme", 10)
+		assert.ErrorIs(t, err, uerrors.ErrSearchCompanies)
+	})
+}
+
 func TestService_CreateUser(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -463,8 +793,10 @@ func TestService_CreateUser(t *testing.T) {
 			uId := "c9f44c4a-788a-4d5f-a210-94ccafcc2231"
 			mockRepo.EXPECT().
 				CreateUser(tcase.ctx, gomock.Any()).Return(uId, nil).AnyTimes()
+			mockRepo.EXPECT().AddUserRole(tcase.ctx, uId, models.DefaultRole).Return(nil).AnyTimes()
+			expectTransaction(mockRepo)
 
-			service := company.NewService(l, mockRepo, 3600)
+			service := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600}, 24*time.Hour)
 			if len(tcase.user.Name) == 0 {
 				if tcase.wantError {
 					t.Skip("Username can't be empty")
@@ -508,9 +840,9 @@ func TestService_CreateToken(t *testing.T) {
 
 		l, _ := logger.GetLogger()
 		mockRepo := mock_company.NewMockRepository(ctrl)
-		service := company.NewService(l, mockRepo, 3600)
+		service := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600}, 24*time.Hour)
 		uId := "c9f44c4a-788a-4d5f-a210-94ccafcc2231"
-		hash, err := service.CreateToken(uId)
+		hash, err := service.CreateToken(auth.Claims{Principal: auth.User(uId)})
 
 		if err != nil {
 			t.Fatalf("unexpected error")
@@ -519,3 +851,110 @@ func TestService_CreateToken(t *testing.T) {
 		assert.NotNil(t, hash)
 	})
 }
+
+func TestService_LoginUnknownUser(t *testing.T) {
+	t.Run("[Err] Unknown user has invalid credentials", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		mockRepo.EXPECT().
+			FindOneUser(ctx, "Bob").
+			Return(nil, fmt.Errorf("Error occurs: %w", uerrors.ErrUserNotFound))
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600}, 24*time.Hour)
+		_, err := s.Login(ctx, &models.UserRequest{Name: "Bob", Password: "password"})
+		assert.ErrorIs(t, err, uerrors.ErrInvalidCredentials)
+	})
+}
+
+func TestService_DatabaseUnavailable(t *testing.T) {
+	outage := fmt.Errorf("Error occurs: %v. %w", errors.New("connection refused"), uerrors.ErrUnavailable)
+	update := &models.CompanyUpdateRequest{Name: "Test", Country: "Cyprus"}
+
+	testCases := []struct {
+		name string
+		mock func(mockRepo *mock_company.MockRepository)
+		call func(s company.IService) error
+	}{
+		{name: "[Err] Get company", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().GetCompany(gomock.Any(), 1).Return(models.Company{}, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.GetCompany(context.Background(), 1)
+			return err
+		}},
+		{name: "[Err] Get companies", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().GetList(gomock.Any(), gomock.Any()).Return(nil, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.GetCompanies(context.Background(), models.CompanyFilter{Limit: 10})
+			return err
+		}},
+		{name: "[Err] Search companies", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().Search(gomock.Any(), "acme", 10).Return(nil, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.SearchCompanies(context.Background(), "acme", 10)
+			return err
+		}},
+		{name: "[Err] Create company", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().FindCountry(gomock.Any(), "Cyprus").Return(models.Country{}, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.CreateCompany(context.Background(), models.CompanyCreateRequest{Name: "Test", Country: "Cyprus"})
+			return err
+		}},
+		{name: "[Err] Update company", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().GetCompany(gomock.Any(), 1).Return(models.Company{}, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.UpdateCompany(context.Background(), 1, update, 0)
+			return err
+		}},
+		{name: "[Err] Delete company", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().GetCompany(gomock.Any(), 1).Return(models.Company{}, outage)
+		}, call: func(s company.IService) error {
+			return s.DeleteCompany(context.Background(), 1, 0)
+		}},
+		{name: "[Err] Restore company", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().Restore(gomock.Any(), 1).Return(0, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.RestoreCompany(context.Background(), 1)
+			return err
+		}},
+		{name: "[Err] Purge company", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().Purge(gomock.Any(), 1, 2).Return(models.Company{}, outage)
+		}, call: func(s company.IService) error {
+			return s.PurgeCompany(context.Background(), 1, 2)
+		}},
+		{name: "[Err] Login", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().FindOneUser(gomock.Any(), "bill").Return(nil, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.Login(context.Background(), &models.UserRequest{Name: "bill", Password: "password"})
+			return err
+		}},
+		{name: "[Err] Check API key", mock: func(mockRepo *mock_company.MockRepository) {
+			mockRepo.EXPECT().FindApiKey(gomock.Any(), "prefix").Return(models.ApiKey{}, outage)
+		}, call: func(s company.IService) error {
+			_, err := s.CheckApiKey(context.Background(), "ApiKey xm_prefix_secret", "127.0.0.1")
+			return err
+		}},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mock_company.NewMockRepository(ctrl)
+			expectTransaction(mockRepo)
+			tcase.mock(mockRepo)
+			l, _ := logger.GetLogger()
+			s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+
+			err := tcase.call(s)
+			var e *uerrors.Error
+			if assert.ErrorAs(t, err, &e) {
+				assert.Equal(t, uerrors.KindUnavailable, e.Kind)
+			}
+		})
+	}
+}
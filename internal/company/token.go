@@ -0,0 +1,113 @@
+package company
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"time"
+)
+
+func (s Service) CreateRefreshToken(ctx context.Context, userId string) (token string, err error) {
+	token, err = s.storeRefreshToken(ctx, "", userId)
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to create refresh token: %s", err)
+		return "", storageError(err, uerrors.ErrRefreshToken)
+	}
+	return
+}
+
+func (s Service) Refresh(ctx context.Context, token string) (user *models.User, newToken string, err error) {
+	reused := false
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		stored, err := s.storage.FindRefreshToken(ctx, hashToken(token))
+		if err != nil {
+			return err
+		}
+		if stored.RevokedAt != nil || stored.ExpiresAt < time.Now().Unix() {
+			return uerrors.ErrInvalidRefreshToken
+		}
+		if stored.UsedAt != nil {
+			// Whoever holds the newer tokens of the family may be the thief, the family is revoked
+			// and the transaction commits the revocation.
+			reused = true
+			return s.storage.RevokeTokenFamily(ctx, stored.FamilyId)
+		}
+
+		if err = s.storage.UseRefreshToken(ctx, stored.Id); err != nil {
+			return err
+		}
+		if newToken, err = s.storeRefreshToken(ctx, stored.FamilyId, stored.UserId); err != nil {
+			return err
+		}
+		user = &models.User{Id: stored.UserId}
+		user.Roles, err = s.storage.GetUserRoles(ctx, stored.UserId)
+		return err
+	})
+	switch {
+	case err == nil && reused:
+		s.logger.Ctx(ctx).Warnf("refresh token reused, its family is revoked")
+		return nil, "", fmt.Errorf("error occurs: %w", uerrors.ErrRefreshTokenReuse)
+	case errors.Is(err, uerrors.ErrInvalidRefreshToken), errors.Is(err, uerrors.ErrUserNotFound):
+		return nil, "", fmt.Errorf("error occurs: %w", uerrors.ErrInvalidRefreshToken)
+	case err != nil:
+		s.logger.Ctx(ctx).Errorf("failed to refresh token: %s", err)
+		return nil, "", storageError(err, uerrors.ErrRefreshToken)
+	}
+	return
+}
+
+func (s Service) Logout(ctx context.Context, claims auth.Claims, refreshToken string) (err error) {
+	err = s.storage.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		if err = s.storage.RevokeToken(ctx, claims.TokenId, claims.ExpiresAt.Unix()); err != nil {
+			return err
+		}
+		// Only users have refresh tokens.
+		userId, ok := claims.Principal.UserId()
+		if refreshToken == "" || !ok {
+			return nil
+		}
+
+		stored, err := s.storage.FindRefreshToken(ctx, hashToken(refreshToken))
+		if errors.Is(err, uerrors.ErrInvalidRefreshToken) || err == nil && stored.UserId != userId {
+			// There is nothing the caller may revoke.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.storage.RevokeTokenFamily(ctx, stored.FamilyId)
+	})
+	if err != nil {
+		s.logger.Ctx(ctx).Errorf("failed to logout: %s", err)
+		return storageError(err, uerrors.ErrRevokeToken)
+	}
+	return
+}
+
+// storeRefreshToken generates a refresh token of the family and stores its hash.
+func (s Service) storeRefreshToken(ctx context.Context, familyId string, userId string) (token string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	expiresAt := time.Now().Add(s.refreshTokenTTL).Unix()
+	if _, err = s.storage.CreateRefreshToken(ctx, hashToken(token), familyId, userId, expiresAt); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// hashToken returns the hash refresh tokens are stored by, so that a database leak doesn't reveal them.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
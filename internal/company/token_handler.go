@@ -0,0 +1,78 @@
+package company
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"io"
+	"net/http"
+	"time"
+)
+
+func (h handler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	req := &models.RefreshRequest{}
+	if !h.decodeValid(w, r, req, "refresh") {
+		return
+	}
+
+	usr, refreshToken, err := h.service.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("can't refresh token: %w", err))
+		return
+	}
+	h.writeTokens(w, r, usr, refreshToken)
+}
+
+func (h handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	req := &models.LogoutRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		h.writeProblem(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("got wrong logout data: %+v", err))
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), tokenClaims(r.Context()), req.RefreshToken); err != nil {
+		h.writeError(w, r, fmt.Errorf("can't logout: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKSHandler publishes the public keys access tokens are verified with. Caches may keep
+// the set for a few minutes, so a new key should be added that long before it starts signing.
+func (h handler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.Header().Add("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.service.JWKS()); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("Failed to write JWKS: %+v", err)
+		return
+	}
+}
+
+// writeTokens issues an access token of the user and writes it together with the refresh token.
+func (h handler) writeTokens(w http.ResponseWriter, r *http.Request, usr *models.User, refreshToken string) {
+	hash, err := h.service.CreateToken(auth.Claims{Principal: auth.User(usr.Id), Roles: h.userRoles(usr)})
+	if err != nil {
+		h.writeError(w, r, fmt.Errorf("error with create token: %w", err))
+		return
+	}
+
+	accessTokenTTL, err := time.ParseDuration(h.config.Auth.AccessTokenTTL)
+	if err != nil {
+		h.logger.Ctx(r.Context()).Errorf("Error with access token ttl: %s", err)
+	}
+
+	w.Header().Add(headerXExpiresAfter, time.Now().Local().Add(accessTokenTTL).String())
+	w.Header().Add(headerContentType, headerValueContentType)
+	w.WriteHeader(http.StatusOK)
+	responseBody := models.UserLoginResponse{
+		Hash:         hash,
+		RefreshToken: refreshToken,
+	}
+	if err := json.NewEncoder(w).Encode(responseBody); err != nil {
+		h.logger.Ctx(r.Context()).Errorf("Failed to write tokens: %+v", err)
+		return
+	}
+}
@@ -0,0 +1,224 @@
+package company_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	"github.com/dkischenko/xm_app/internal/config"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	mock_geo "github.com/dkischenko/xm_app/pkg/geo/mocks"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const tokenFamilyId = "5f0c1b7e-2d4a-4e8b-9c3f-7a6d5e4c3b2a"
+
+func TestService_Refresh(t *testing.T) {
+	t.Run("[Ok] Rotate refresh token", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		expectTransaction(mockRepo)
+		mockRepo.EXPECT().FindRefreshToken(ctx, gomock.Any()).Return(models.RefreshToken{
+			Id: 1, FamilyId: tokenFamilyId, UserId: roleUserId, ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		}, nil)
+		mockRepo.EXPECT().UseRefreshToken(ctx, int64(1)).Return(nil)
+		var newHash string
+		mockRepo.EXPECT().CreateRefreshToken(ctx, gomock.Any(), tokenFamilyId, roleUserId, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, hash, familyId, userId string, expiresAt int64) (string, error) {
+				newHash = hash
+				assert.LessOrEqual(t, expiresAt, time.Now().Add(24*time.Hour).Unix())
+				return familyId, nil
+			})
+		mockRepo.EXPECT().GetUserRoles(ctx, roleUserId).Return([]string{models.RoleViewer}, nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		usr, token, err := s.Refresh(ctx, "old")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, roleUserId, usr.Id)
+		assert.Equal(t, []string{models.RoleViewer}, usr.Roles)
+		assert.NotEmpty(t, token)
+		assert.NotEqual(t, token, newHash)
+	})
+
+	t.Run("[Err] Reused token revokes family", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		usedAt := time.Now().Add(-time.Minute).Unix()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		expectTransaction(mockRepo)
+		mockRepo.EXPECT().FindRefreshToken(ctx, gomock.Any()).Return(models.RefreshToken{
+			Id: 1, FamilyId: tokenFamilyId, UserId: roleUserId, ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			UsedAt: &usedAt,
+		}, nil)
+		mockRepo.EXPECT().RevokeTokenFamily(ctx, tokenFamilyId).Return(nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, _, err := s.Refresh(ctx, "old")
+		assert.True(t, errors.Is(err, uerrors.ErrRefreshTokenReuse))
+	})
+
+	t.Run("[Err] Expired token", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx := context.Background()
+		mockRepo := mock_company.NewMockRepository(ctrl)
+		expectTransaction(mockRepo)
+		mockRepo.EXPECT().FindRefreshToken(ctx, gomock.Any()).Return(models.RefreshToken{
+			Id: 1, FamilyId: tokenFamilyId, UserId: roleUserId, ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+		}, nil)
+
+		l, _ := logger.GetLogger()
+		s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+		_, _, err := s.Refresh(ctx, "old")
+		assert.True(t, errors.Is(err, uerrors.ErrInvalidRefreshToken))
+	})
+}
+
+func TestService_Logout(t *testing.T) {
+	testCases := []struct {
+		name         string
+		tokenUserId  string
+		revokeFamily bool
+	}{
+		{name: "[Ok] Revoke own refresh token family", tokenUserId: roleUserId, revokeFamily: true},
+		{name: "[Ok] Ignore refresh token of another user", tokenUserId: "c9f44c4a-788a-4d5f-a210-94ccafcc2231"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			expiresAt := time.Now().Add(time.Hour)
+			mockRepo := mock_company.NewMockRepository(ctrl)
+			expectTransaction(mockRepo)
+			mockRepo.EXPECT().RevokeToken(ctx, "jti", expiresAt.Unix()).Return(nil)
+			mockRepo.EXPECT().FindRefreshToken(ctx, gomock.Any()).Return(models.RefreshToken{
+				Id: 1, FamilyId: tokenFamilyId, UserId: tcase.tokenUserId, ExpiresAt: expiresAt.Unix(),
+			}, nil)
+			if tcase.revokeFamily {
+				mockRepo.EXPECT().RevokeTokenFamily(ctx, tokenFamilyId).Return(nil)
+			}
+
+			l, _ := logger.GetLogger()
+			s := company.NewService(l, mockRepo, auth.Options{TokenTTL: 3600 * time.Second}, 24*time.Hour)
+			err := s.Logout(ctx, auth.Claims{Principal: auth.User(roleUserId), TokenId: "jti", ExpiresAt: expiresAt}, "refresh")
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestHandler_RefreshToken(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		wantStatusCode int
+	}{
+		{name: "[Ok] Refresh", body: `{"refresh_token": "old"}`, wantStatusCode: http.StatusOK},
+		{name: "[Err] Missing token", body: `{}`, wantStatusCode: http.StatusBadRequest},
+		{name: "[Err] Invalid token", body: `{"refresh_token": "old"}`,
+			serviceErr: uerrors.ErrInvalidRefreshToken, wantStatusCode: http.StatusUnauthorized},
+		{name: "[Err] Reused token", body: `{"refresh_token": "old"}`,
+			serviceErr: uerrors.ErrRefreshTokenReuse, wantStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/users/refresh", strings.NewReader(tcase.body))
+			w := httptest.NewRecorder()
+			l, _ := logger.GetLogger()
+			cfg := &config.Config{}
+			cfg.Auth.AccessTokenTTL = "15m"
+			mockService := mock_company.NewMockIService(ctrl)
+			if tcase.body != `{}` {
+				if tcase.serviceErr != nil {
+					mockService.EXPECT().Refresh(gomock.Any(), "old").Return(nil, "", tcase.serviceErr)
+				} else {
+					mockService.EXPECT().Refresh(gomock.Any(), "old").
+						Return(&models.User{Id: roleUserId, Roles: []string{models.RoleViewer}}, "new", nil)
+					mockService.EXPECT().CreateToken(auth.Claims{Principal: auth.User(roleUserId), Roles: []string{models.RoleViewer}}).Return("token", nil)
+				}
+			}
+			h := company.NewHandler(l, mockService, cfg, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+			router := mux.NewRouter()
+			h.Register(router)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tcase.wantStatusCode, w.Code)
+
+			if tcase.wantStatusCode == http.StatusOK {
+				resp := models.UserLoginResponse{}
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				assert.Equal(t, "token", resp.Hash)
+				assert.Equal(t, "new", resp.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestHandler_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users/logout", strings.NewReader(`{"refresh_token": "refresh"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	l, _ := logger.GetLogger()
+	claims := auth.Claims{Principal: auth.User(roleUserId), Roles: []string{models.RoleViewer}, TokenId: "jti"}
+	mockService := mock_company.NewMockIService(ctrl)
+	mockService.EXPECT().CheckAuth(gomock.Any(), "Bearer token").Return(claims, nil)
+	mockService.EXPECT().Logout(gomock.Any(), claims, "refresh").Return(nil)
+	h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+	router := mux.NewRouter()
+	h.Register(router)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestHandler_JWKS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	l, _ := logger.GetLogger()
+	mockService := mock_company.NewMockIService(ctrl)
+	mockService.EXPECT().JWKS().Return(auth.JWKS{Keys: []auth.JWK{{Kty: "OKP", Kid: "k1", Use: "sig", Alg: "EdDSA",
+		Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}}})
+	h := company.NewHandler(l, mockService, &config.Config{}, newPolicy(t, mock_geo.NewMockGeoLocator(ctrl), false))
+	router := mux.NewRouter()
+	h.Register(router)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"keys": [{"kty": "OKP", "kid": "k1", "use": "sig", "alg": "EdDSA", "crv": "Ed25519",
+		"x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`, w.Body.String())
+}
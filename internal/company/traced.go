@@ -0,0 +1,208 @@
+package company
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/dkischenko/xm_app/internal/company"
+
+// traced runs every call of the service taking a context in a span of its own.
+type traced struct {
+	next   IService
+	tracer trace.Tracer
+}
+
+// Traced returns the service running every call taking a context in a span of its own.
+func Traced(service IService) IService {
+	return traced{next: service, tracer: otel.Tracer(tracerName)}
+}
+
+func (t traced) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "Service."+method)
+}
+
+// end ends the span of a call that failed with err. Failures caused by the caller,
+// such as a missing company, are recorded without marking the span as failed.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if kind := uerrors.As(err).Kind; kind == uerrors.KindInternal || kind == uerrors.KindUnavailable {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (t traced) GetCountries(ctx context.Context, region string) (countries []models.Country, err error) {
+	ctx, span := t.start(ctx, "GetCountries")
+	defer func() { end(span, err) }()
+	return t.next.GetCountries(ctx, region)
+}
+
+func (t traced) GetCountry(ctx context.Context, ref string) (country models.Country, err error) {
+	ctx, span := t.start(ctx, "GetCountry")
+	defer func() { end(span, err) }()
+	return t.next.GetCountry(ctx, ref)
+}
+
+func (t traced) CreateCompany(ctx context.Context, company models.CompanyCreateRequest) (id int, err error) {
+	ctx, span := t.start(ctx, "CreateCompany")
+	defer func() { end(span, err) }()
+	return t.next.CreateCompany(ctx, company)
+}
+
+func (t traced) UpdateCompany(ctx context.Context, companyId int, company *models.CompanyUpdateRequest,
+	version int) (newVersion int, err error) {
+	ctx, span := t.start(ctx, "UpdateCompany")
+	defer func() { end(span, err) }()
+	return t.next.UpdateCompany(ctx, companyId, company, version)
+}
+
+func (t traced) PatchCompany(ctx context.Context, companyId int, patch models.CompanyPatch,
+	version int) (newVersion int, err error) {
+	ctx, span := t.start(ctx, "PatchCompany")
+	defer func() { end(span, err) }()
+	return t.next.PatchCompany(ctx, companyId, patch, version)
+}
+
+func (t traced) DeleteCompany(ctx context.Context, companyId int, version int) (err error) {
+	ctx, span := t.start(ctx, "DeleteCompany")
+	defer func() { end(span, err) }()
+	return t.next.DeleteCompany(ctx, companyId, version)
+}
+
+func (t traced) RestoreCompany(ctx context.Context, companyId int) (newVersion int, err error) {
+	ctx, span := t.start(ctx, "RestoreCompany")
+	defer func() { end(span, err) }()
+	return t.next.RestoreCompany(ctx, companyId)
+}
+
+func (t traced) PurgeCompany(ctx context.Context, companyId int, version int) (err error) {
+	ctx, span := t.start(ctx, "PurgeCompany")
+	defer func() { end(span, err) }()
+	return t.next.PurgeCompany(ctx, companyId, version)
+}
+
+func (t traced) GetAuditEvents(ctx context.Context, filter models.AuditFilter) (page models.AuditPage, err error) {
+	ctx, span := t.start(ctx, "GetAuditEvents")
+	defer func() { end(span, err) }()
+	return t.next.GetAuditEvents(ctx, filter)
+}
+
+func (t traced) VerifyAuditLog(ctx context.Context) (result models.AuditVerification, err error) {
+	ctx, span := t.start(ctx, "VerifyAuditLog")
+	defer func() { end(span, err) }()
+	return t.next.VerifyAuditLog(ctx)
+}
+
+func (t traced) GetCompanies(ctx context.Context, filter models.CompanyFilter) (page models.CompanyPage, err error) {
+	ctx, span := t.start(ctx, "GetCompanies")
+	defer func() { end(span, err) }()
+	return t.next.GetCompanies(ctx, filter)
+}
+
+func (t traced) GetCompany(ctx context.Context, companyId int) (company models.Company, err error) {
+	ctx, span := t.start(ctx, "GetCompany")
+	defer func() { end(span, err) }()
+	return t.next.GetCompany(ctx, companyId)
+}
+
+func (t traced) SearchCompanies(ctx context.Context, query string,
+	limit int) (companies []models.CompanySearchResult, err error) {
+	ctx, span := t.start(ctx, "SearchCompanies")
+	defer func() { end(span, err) }()
+	return t.next.SearchCompanies(ctx, query, limit)
+}
+
+func (t traced) CreateUser(ctx context.Context, user models.UserRequest) (id string, err error) {
+	ctx, span := t.start(ctx, "CreateUser")
+	defer func() { end(span, err) }()
+	return t.next.CreateUser(ctx, user)
+}
+
+func (t traced) Login(ctx context.Context, ur *models.UserRequest) (u *models.User, err error) {
+	ctx, span := t.start(ctx, "Login")
+	defer func() { end(span, err) }()
+	return t.next.Login(ctx, ur)
+}
+
+func (t traced) CreateToken(claims auth.Claims) (hash string, err error) {
+	return t.next.CreateToken(claims)
+}
+
+func (t traced) CheckAuth(ctx context.Context, header string) (claims auth.Claims, err error) {
+	ctx, span := t.start(ctx, "CheckAuth")
+	defer func() { end(span, err) }()
+	return t.next.CheckAuth(ctx, header)
+}
+
+func (t traced) JWKS() auth.JWKS {
+	return t.next.JWKS()
+}
+
+func (t traced) CreateRefreshToken(ctx context.Context, userId string) (token string, err error) {
+	ctx, span := t.start(ctx, "CreateRefreshToken")
+	defer func() { end(span, err) }()
+	return t.next.CreateRefreshToken(ctx, userId)
+}
+
+func (t traced) Refresh(ctx context.Context, token string) (user *models.User, newToken string, err error) {
+	ctx, span := t.start(ctx, "Refresh")
+	defer func() { end(span, err) }()
+	return t.next.Refresh(ctx, token)
+}
+
+func (t traced) Logout(ctx context.Context, claims auth.Claims, refreshToken string) (err error) {
+	ctx, span := t.start(ctx, "Logout")
+	defer func() { end(span, err) }()
+	return t.next.Logout(ctx, claims, refreshToken)
+}
+
+func (t traced) GetUserRoles(ctx context.Context, userId string) (roles []string, err error) {
+	ctx, span := t.start(ctx, "GetUserRoles")
+	defer func() { end(span, err) }()
+	return t.next.GetUserRoles(ctx, userId)
+}
+
+func (t traced) AssignRole(ctx context.Context, userId string, role string) (roles []string, err error) {
+	ctx, span := t.start(ctx, "AssignRole")
+	defer func() { end(span, err) }()
+	return t.next.AssignRole(ctx, userId, role)
+}
+
+func (t traced) RevokeRole(ctx context.Context, userId string, role string) (roles []string, err error) {
+	ctx, span := t.start(ctx, "RevokeRole")
+	defer func() { end(span, err) }()
+	return t.next.RevokeRole(ctx, userId, role)
+}
+
+func (t traced) CreateApiKey(ctx context.Context,
+	req models.ApiKeyCreateRequest) (key models.ApiKey, secret string, err error) {
+	ctx, span := t.start(ctx, "CreateApiKey")
+	defer func() { end(span, err) }()
+	return t.next.CreateApiKey(ctx, req)
+}
+
+func (t traced) GetApiKeys(ctx context.Context) (keys []models.ApiKey, err error) {
+	ctx, span := t.start(ctx, "GetApiKeys")
+	defer func() { end(span, err) }()
+	return t.next.GetApiKeys(ctx)
+}
+
+func (t traced) RevokeApiKey(ctx context.Context, id string) (key models.ApiKey, err error) {
+	ctx, span := t.start(ctx, "RevokeApiKey")
+	defer func() { end(span, err) }()
+	return t.next.RevokeApiKey(ctx, id)
+}
+
+func (t traced) CheckApiKey(ctx context.Context, header string, ip string) (key models.ApiKey, err error) {
+	ctx, span := t.start(ctx, "CheckApiKey")
+	defer func() { end(span, err) }()
+	return t.next.CheckApiKey(ctx, header, ip)
+}
@@ -0,0 +1,65 @@
+package company_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/company"
+	mock_company "github.com/dkischenko/xm_app/internal/company/mocks"
+	"github.com/dkischenko/xm_app/internal/company/models"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestTraced(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status codes.Code
+		events int
+	}{
+		{name: "[Ok] Call succeeds", status: codes.Unset},
+		{name: "[Ok] Caller error is only recorded", err: fmt.Errorf("error occurs: %w", uerrors.ErrCompanyNotFound),
+			status: codes.Unset, events: 1},
+		{name: "[Err] Internal error fails the span", err: fmt.Errorf("error occurs: %w", uerrors.ErrGetCompany),
+			status: codes.Error, events: 1},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			recorder := tracetest.NewSpanRecorder()
+			previous := otel.GetTracerProvider()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			defer otel.SetTracerProvider(previous)
+
+			var inner trace.SpanContext
+			mockService := mock_company.NewMockIService(ctrl)
+			mockService.EXPECT().GetCompany(gomock.Any(), 7).
+				DoAndReturn(func(ctx context.Context, companyId int) (models.Company, error) {
+					inner = trace.SpanContextFromContext(ctx)
+					return models.Company{}, tcase.err
+				})
+
+			_, err := company.Traced(mockService).GetCompany(context.Background(), 7)
+			assert.Equal(t, tcase.err, err)
+
+			spans := recorder.Ended()
+			if !assert.Len(t, spans, 1) {
+				return
+			}
+			assert.Equal(t, "Service.GetCompany", spans[0].Name())
+			assert.Equal(t, spans[0].SpanContext(), inner, "the call must run in the span")
+			assert.Equal(t, tcase.status, spans[0].Status().Code)
+			assert.Len(t, spans[0].Events(), tcase.events)
+		})
+	}
+}
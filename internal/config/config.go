@@ -7,12 +7,23 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/ilyakaznacheev/cleanenv"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GeoFailOpen   = "open"
+	GeoFailClosed = "closed"
 )
 
 type Config struct {
 	Listen struct {
 		Ip   string `yaml:"ip" env-default:"0.0.0.0" validate:"required,ip"`
 		Port string `yaml:"port" env-default:"8080" validate:"required,numeric"`
+		// TrustedProxies are CIDRs or addresses of reverse proxies whose forwarding
+		// headers tell the client address.
+		TrustedProxies []string `yaml:"trustedProxies" env:"TRUSTED_PROXIES" env-separator:"," validate:"dive,cidr|ip"`
 	} `yaml:"listen"`
 	Storage struct {
 		Host     string `yaml:"host" validate:"required,alpha"`
@@ -20,10 +31,116 @@ type Config struct {
 		Username string `yaml:"username" validate:"required"`
 		Password string `yaml:"password" validate:"required"`
 		Database string `yaml:"database" validate:"required"`
+		// AutoMigrate applies pending schema migrations on startup.
+		AutoMigrate bool `yaml:"autoMigrate" env-default:"false"`
 	} `yaml:"storage"`
 	Auth struct {
 		AccessTokenTTL string `yaml:"accessTokenTTL" validate:"required"`
+		// GeoTokenTTL is the lifetime of the tokens granted to anonymous callers by the access policy.
+		GeoTokenTTL time.Duration `yaml:"geoTokenTTL" env:"GEO_TOKEN_TTL" env-default:"5m" validate:"min=0"`
+		// Issuer and Audience are written to the iss and aud claims of access tokens and
+		// required from the tokens presented. Leeway is the tolerated clock skew.
+		Issuer   string        `yaml:"issuer" env:"TOKEN_ISSUER" env-default:"xm_app"`
+		Audience string        `yaml:"audience" env:"TOKEN_AUDIENCE" env-default:"xm_app"`
+		Leeway   time.Duration `yaml:"leeway" env:"TOKEN_LEEWAY" env-default:"30s" validate:"min=0"`
+		// RefreshTokenTTL is how long a refresh token may be exchanged for new tokens.
+		RefreshTokenTTL string `yaml:"refreshTokenTTL" env-default:"720h"`
+		// Admins are ids of users given the admin role on login in addition to their stored roles,
+		// so that the first administrator can assign roles to others.
+		Admins []string `yaml:"admins" env:"ADMINS" env-separator:","`
+		// Keys and the *.pem files of KeyDir, named by their key id, are the RSA, ECDSA or Ed25519 keys
+		// access tokens are signed and verified with. Without any HS256 with SIGNINKEY is used.
+		Keys   []AuthKey `yaml:"keys" validate:"dive"`
+		KeyDir string    `yaml:"keyDir" env:"KEY_DIR"`
+		// SigningKey is the id of the key new tokens are signed with, it may be omitted
+		// when only one key has its private part.
+		SigningKey string `yaml:"signingKey" env:"SIGNING_KEY"`
 	} `yaml:"auth"`
+	Geo struct {
+		// Provider selects the geo-IP backend: ipapi (the ipapi.co web service),
+		// mmdb (a MaxMind-format database) or csv (a network to country list).
+		Provider string `yaml:"provider" env-default:"ipapi" validate:"oneof=ipapi mmdb csv"`
+		// Path is the database file of the mmdb and csv providers.
+		Path string `yaml:"path" validate:"required_unless=Provider ipapi"`
+		// Timeout, Retries, Cache* and Breaker* tune the client of the remote ipapi provider,
+		// zero values take the defaults of geo.Options.
+		Timeout          time.Duration `yaml:"timeout"`
+		Retries          int           `yaml:"retries"`
+		CacheSize        int           `yaml:"cacheSize" validate:"min=0"`
+		CacheTTL         time.Duration `yaml:"cacheTTL"`
+		BreakerThreshold int           `yaml:"breakerThreshold" validate:"min=0"`
+		BreakerCooldown  time.Duration `yaml:"breakerCooldown"`
+		// FailPolicy decides about callers whose country can't be resolved because
+		// the provider is unavailable: open lets them through, closed denies them.
+		FailPolicy string `yaml:"failPolicy" env-default:"closed" validate:"oneof=open closed"`
+	} `yaml:"geo"`
+	// Policy decides which anonymous callers may write, see the policy package.
+	Policy      Policy `yaml:"policy"`
+	Concurrency struct {
+		// Strict requires If-Match on every company write.
+		Strict bool `yaml:"strict" env-default:"false"`
+	} `yaml:"concurrency"`
+	OpenAPI struct {
+		// ValidateRequests rejects requests not matching the OpenAPI document.
+		ValidateRequests bool `yaml:"validateRequests" env:"OPENAPI_VALIDATE_REQUESTS" env-default:"false"`
+		// ValidateResponses also replaces responses not matching the document with 500, meant for test environments.
+		ValidateResponses bool `yaml:"validateResponses" env:"OPENAPI_VALIDATE_RESPONSES" env-default:"false"`
+	} `yaml:"openapi"`
+	Metrics struct {
+		// Listen is the address of the operations listener serving the Prometheus metrics and the
+		// verbose readiness probe, kept apart from the API.
+		Listen string `yaml:"listen" env:"METRICS_LISTEN" env-default:":9090" validate:"hostname_port"`
+	} `yaml:"metrics"`
+	Tracing struct {
+		// Exporter sends spans to stdout, with OTLP over HTTP to Endpoint or nowhere (none).
+		Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none" validate:"oneof=none stdout otlp"`
+		// Endpoint is the host:port of the OTLP receiver, Insecure sends to it over plain HTTP.
+		Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318" validate:"hostname_port"`
+		Insecure bool   `yaml:"insecure" env:"TRACING_INSECURE" env-default:"true"`
+		// SampleRatio is the share of new traces recorded, traces continued from callers follow their decision.
+		SampleRatio float64 `yaml:"sampleRatio" env:"TRACING_SAMPLE_RATIO" env-default:"1" validate:"min=0,max=1"`
+	} `yaml:"tracing"`
+}
+
+// AuthKey is a PEM key file. Public keys only verify tokens signed before the key was retired.
+type AuthKey struct {
+	Id   string `yaml:"id" validate:"required"`
+	Path string `yaml:"path" validate:"required"`
+}
+
+// Policy is an ordered list of access rules for callers without a token, the first matching rule wins.
+// Without rules the policy allows callers from Cyprus to run every operation.
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+	// Default is the effect when no rule matches: allow or deny.
+	Default string `yaml:"default" env-default:"deny" validate:"oneof=allow deny"`
+}
+
+// PolicyRule matches a request when every condition it sets holds.
+type PolicyRule struct {
+	Name string `yaml:"name"`
+	// Operations the rule applies to, such as company.create; all of them when empty.
+	Operations []string `yaml:"operations"`
+	// Countries are ISO 3166-1 alpha-2 codes of the caller country.
+	Countries []string `yaml:"countries"`
+	// CIDRs are networks the caller address belongs to.
+	CIDRs  []string      `yaml:"cidrs"`
+	Window *PolicyWindow `yaml:"window"`
+	// Effect is allow or deny.
+	Effect string `yaml:"effect"`
+	// GrantToken issues an anonymous access token to allowed callers.
+	GrantToken bool `yaml:"grantToken"`
+}
+
+// PolicyWindow limits a rule to a time of the day, "09:00" to "18:00", on the given days.
+// A window whose end is before its start spans midnight.
+type PolicyWindow struct {
+	// Days are mon, tue, wed, thu, fri, sat and sun; every day when empty.
+	Days []string `yaml:"days"`
+	From string   `yaml:"from"`
+	To   string   `yaml:"to"`
+	// Timezone is an IANA time zone name, UTC when empty.
+	Timezone string `yaml:"timezone"`
 }
 
 func GetConfig(cfgPath string, instance *Config) *Config {
@@ -55,6 +172,18 @@ func GetConfig(cfgPath string, instance *Config) *Config {
 	return instance
 }
 
+// ReadConfig reads and validates the config file, unlike GetConfig it returns the errors.
+func ReadConfig(cfgPath string) (*Config, error) {
+	instance := &Config{}
+	if err := cleanenv.ReadConfig(cfgPath, instance); err != nil {
+		return nil, err
+	}
+	if err := validateConfig(instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
 func validateConfig(cfg *Config) (err error) {
 	valid := v.New()
 	err = valid.Vld.Struct(cfg)
@@ -82,7 +211,58 @@ func populateConfig(cfg *Config) {
 	cfg.Storage.Username = os.Getenv("DB_USERNAME")
 	cfg.Storage.Password = os.Getenv("DB_PASSWORD")
 	cfg.Storage.Database = os.Getenv("DB_DATABASE")
+	cfg.Storage.AutoMigrate = os.Getenv("AUTO_MIGRATE") == "true"
 	cfg.Listen.Ip = os.Getenv("APP_IP")
 	cfg.Listen.Port = os.Getenv("PORT")
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.Listen.TrustedProxies = strings.Split(proxies, ",")
+	}
 	cfg.Auth.AccessTokenTTL = os.Getenv("ACCESSTOKENTTL")
+	if cfg.Auth.RefreshTokenTTL = os.Getenv("REFRESHTOKENTTL"); cfg.Auth.RefreshTokenTTL == "" {
+		cfg.Auth.RefreshTokenTTL = "720h"
+	}
+	if admins := os.Getenv("ADMINS"); admins != "" {
+		cfg.Auth.Admins = strings.Split(admins, ",")
+	}
+	if cfg.Auth.GeoTokenTTL, _ = time.ParseDuration(os.Getenv("GEO_TOKEN_TTL")); cfg.Auth.GeoTokenTTL == 0 {
+		cfg.Auth.GeoTokenTTL = 5 * time.Minute
+	}
+	cfg.Auth.Issuer = os.Getenv("TOKEN_ISSUER")
+	cfg.Auth.Audience = os.Getenv("TOKEN_AUDIENCE")
+	if cfg.Auth.Leeway, _ = time.ParseDuration(os.Getenv("TOKEN_LEEWAY")); os.Getenv("TOKEN_LEEWAY") == "" {
+		cfg.Auth.Leeway = 30 * time.Second
+	}
+	cfg.Auth.KeyDir = os.Getenv("KEY_DIR")
+	cfg.Auth.SigningKey = os.Getenv("SIGNING_KEY")
+	cfg.Concurrency.Strict = os.Getenv("CONCURRENCY_STRICT") == "true"
+	cfg.OpenAPI.ValidateRequests = os.Getenv("OPENAPI_VALIDATE_REQUESTS") == "true"
+	cfg.OpenAPI.ValidateResponses = os.Getenv("OPENAPI_VALIDATE_RESPONSES") == "true"
+	if cfg.Metrics.Listen = os.Getenv("METRICS_LISTEN"); cfg.Metrics.Listen == "" {
+		cfg.Metrics.Listen = ":9090"
+	}
+	if cfg.Tracing.Exporter = os.Getenv("TRACING_EXPORTER"); cfg.Tracing.Exporter == "" {
+		cfg.Tracing.Exporter = "none"
+	}
+	if cfg.Tracing.Endpoint = os.Getenv("TRACING_ENDPOINT"); cfg.Tracing.Endpoint == "" {
+		cfg.Tracing.Endpoint = "localhost:4318"
+	}
+	cfg.Tracing.Insecure = os.Getenv("TRACING_INSECURE") != "false"
+	ratio, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATIO"), 64)
+	if err != nil {
+		ratio = 1
+	}
+	cfg.Tracing.SampleRatio = ratio
+	if cfg.Geo.Provider = os.Getenv("GEO_PROVIDER"); cfg.Geo.Provider == "" {
+		cfg.Geo.Provider = "ipapi"
+	}
+	cfg.Geo.Path = os.Getenv("GEO_PATH")
+	cfg.Geo.Timeout, _ = time.ParseDuration(os.Getenv("GEO_TIMEOUT"))
+	cfg.Geo.Retries, _ = strconv.Atoi(os.Getenv("GEO_RETRIES"))
+	cfg.Geo.CacheSize, _ = strconv.Atoi(os.Getenv("GEO_CACHE_SIZE"))
+	cfg.Geo.CacheTTL, _ = time.ParseDuration(os.Getenv("GEO_CACHE_TTL"))
+	cfg.Geo.BreakerThreshold, _ = strconv.Atoi(os.Getenv("GEO_BREAKER_THRESHOLD"))
+	cfg.Geo.BreakerCooldown, _ = time.ParseDuration(os.Getenv("GEO_BREAKER_COOLDOWN"))
+	if cfg.Geo.FailPolicy = os.Getenv("GEO_FAIL_POLICY"); cfg.Geo.FailPolicy == "" {
+		cfg.Geo.FailPolicy = GeoFailClosed
+	}
 }
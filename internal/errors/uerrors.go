@@ -1,23 +1,152 @@
+// Package uerrors holds the errors of the application. Errors clients may learn
+// about are typed by their Kind, any other error is internal.
 package uerrors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind tells what went wrong, the HTTP layer maps it to a status code.
+type Kind string
+
+const (
+	KindInternal        Kind = "internal"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindValidation      Kind = "validation"
+	KindUnauthenticated Kind = "unauthenticated"
+	KindForbidden       Kind = "forbidden"
+	KindUnavailable     Kind = "unavailable"
+)
+
+// CodeInternal is the code of every internal error, their details are kept from clients.
+const CodeInternal = "internal_error"
+
+// Error is a domain error. Code is stable, so clients can tell errors by it.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	// Detail tells about the particular occurrence, such as the value that was rejected.
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return e.Message
+	}
+	return e.Message + ": " + e.Detail
+}
+
+// Is matches errors of the same code, so an error with a detail is still its sentinel.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail returns a copy of the error carrying the detail.
+func (e *Error) WithDetail(format string, args ...interface{}) *Error {
+	c := *e
+	c.Detail = fmt.Sprintf(format, args...)
+	return &c
+}
+
+func newError(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func NotFound(code string, message string) *Error {
+	return newError(KindNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return newError(KindConflict, code, message)
+}
+
+func Validation(code string, message string) *Error {
+	return newError(KindValidation, code, message)
+}
+
+func Unauthenticated(code string, message string) *Error {
+	return newError(KindUnauthenticated, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return newError(KindForbidden, code, message)
+}
+
+func Unavailable(code string, message string) *Error {
+	return newError(KindUnavailable, code, message)
+}
+
+// As returns the domain error err carries, an internal one if there is none.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Kind: KindInternal, Code: CodeInternal, Message: "internal server error"}
+}
+
+// IsDomain reports whether err carries a domain error.
+func IsDomain(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
 
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// Problem is an RFC 7807 problem details body, extended by the error code and the id of the request.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestId string `json:"request_id,omitempty"`
 }
 
 var (
-	ErrCreateUser            = errors.New("error with creating user due a database issue")
-	ErrFindOneUser           = errors.New("error with finding user")
-	ErrCheckUserPasswordHash = errors.New("error with using wrong password")
-	ErrCreateJWTToken        = errors.New("error with creation of JWT token of user")
-	ErrEmptyToken            = errors.New("error with empty token")
-	ErrParseToken            = errors.New("error with parsing token")
-	ErrCreateCountry         = errors.New("error with creating country due a database issue")
-	ErrCreateCompany         = errors.New("error with creating company due a database issue")
-	ErrGetCompanies          = errors.New("error with getting company list due a database issue")
-	ErrGetCompany            = errors.New("error with getting company due a database issue")
-	ErrUpdateCompany         = errors.New("error with updating company due a database issue")
-	ErrDeleteCompany         = errors.New("error with deleting company due a database issue")
+	ErrCreateUser          = errors.New("error with creating user due a database issue")
+	ErrUserExists          = Conflict("user_exists", "error with taken user name")
+	ErrFindOneUser         = errors.New("error with finding user")
+	ErrUserNotFound        = NotFound("user_not_found", "error with unknown user")
+	ErrUnknownRole         = Validation("unknown_role", "error with unknown role")
+	ErrGetRoles            = errors.New("error with getting user roles due a database issue")
+	ErrUpdateRoles         = errors.New("error with updating user roles due a database issue")
+	ErrInvalidCredentials  = Unauthenticated("invalid_credentials", "error with wrong user name or password")
+	ErrCreateJWTToken      = errors.New("error with creation of JWT token of user")
+	ErrAuthRequired        = Unauthenticated("authorization_required", "error with missing authorization")
+	ErrEmptyToken          = Unauthenticated("missing_token", "error with empty token")
+	ErrAuthScheme          = Unauthenticated("unsupported_scheme", "error with unsupported authorization scheme")
+	ErrParseToken          = Unauthenticated("invalid_token", "error with parsing token")
+	ErrTokenRevoked        = Unauthenticated("token_revoked", "error with revoked token")
+	ErrInvalidRefreshToken = Unauthenticated("invalid_refresh_token", "error with invalid or expired refresh token")
+	ErrRefreshTokenReuse   = Unauthenticated("refresh_token_reused", "error with reused refresh token, its family is revoked")
+	ErrRefreshToken        = errors.New("error with refresh token due a database issue")
+	ErrRevokeToken         = errors.New("error with revoking token due a database issue")
+	ErrInvalidApiKey       = Unauthenticated("invalid_api_key", "error with invalid, expired or revoked API key")
+	ErrApiKeyNotFound      = NotFound("api_key_not_found", "error with unknown API key")
+	ErrUnknownScope        = Validation("unknown_scope", "error with unknown API key scope")
+	ErrApiKeyExpiry        = Validation("invalid_expiry", "error with API key expiring in the past")
+	ErrApiKey              = errors.New("error with API key due a database issue")
+	ErrCountryNotFound     = NotFound("country_not_found", "error with unknown country")
+	ErrGetCountries        = errors.New("error with getting countries due a database issue")
+	ErrCreateCompany       = errors.New("error with creating company due a database issue")
+	ErrGetCompanies        = errors.New("error with getting company list due a database issue")
+	ErrGetCompany          = errors.New("error with getting company due a database issue")
+	ErrCompanyNotFound     = NotFound("company_not_found", "error with unknown company")
+	ErrUpdateCompany       = errors.New("error with updating company due a database issue")
+	ErrDeleteCompany       = errors.New("error with deleting company due a database issue")
+	ErrRestoreCompany      = errors.New("error with restoring company due a database issue")
+	ErrCompanyNotDeleted   = Conflict("company_not_deleted", "error with restoring company that is not deleted")
+	ErrVersionMismatch     = Conflict("version_mismatch", "error with company version mismatch")
+	ErrUnsupportedPatch    = Validation("unsupported_patch", "error with unsupported patch media type")
+	ErrApplyPatch          = Validation("invalid_patch", "error with applying patch to company")
+	ErrValidateCompany     = Validation("invalid_company", "error with validating company data")
+	ErrCreateAuditEvent    = errors.New("error with writing audit event due a database issue")
+	ErrGetAuditEvents      = errors.New("error with getting audit events due a database issue")
+	ErrSearchCompanies     = errors.New("error with searching companies due a database issue")
+	ErrForbidden           = Forbidden("forbidden", "error with missing permission")
+	ErrUnavailable         = Unavailable("unavailable", "error with unavailable database")
 )
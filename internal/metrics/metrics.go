@@ -0,0 +1,176 @@
+// Package metrics collects the Prometheus metrics of the application and serves
+// them in the text exposition format.
+package metrics
+
+import (
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	Path      = "/metrics"
+	namespace = "xm_app"
+)
+
+// Results of logins.
+const (
+	LoginSucceeded = "success"
+	// LoginRejected is a login with an unknown name or a wrong password.
+	LoginRejected = "rejected"
+	LoginFailed   = "error"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of repository calls by method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+	geoLookups = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "geo_lookup_duration_seconds",
+		Help:      "Latency of geo-IP lookups by outcome.",
+		Buckets:   []float64{.0001, .001, .01, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"outcome"})
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Logins by result.",
+	}, []string{"result"})
+	tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_issued_total",
+		Help:      "Access tokens issued by principal type.",
+	}, []string{"principal"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, httpInFlight, queryDuration, geoLookups, logins, tokens,
+	)
+}
+
+// Handler serves the metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Register serves the metrics at Path.
+func Register(router *mux.Router) {
+	router.Handle(Path, Handler()).Methods(http.MethodGet)
+}
+
+// RegisterPool exports the connection statistics of the pool.
+func RegisterPool(pool *pgxpool.Pool) {
+	registry.MustRegister(newPoolCollector(pool))
+}
+
+// RegisterGeo exports the counters of the resilient geo locator.
+func RegisterGeo(m *geo.Metrics) {
+	counter := func(name string, help string, value func(s geo.MetricsSnapshot) int64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help},
+			func() float64 { return float64(value(m.Snapshot())) })
+	}
+	registry.MustRegister(
+		counter("geo_cache_hits_total", "Geo-IP lookups answered from the cache.",
+			func(s geo.MetricsSnapshot) int64 { return s.CacheHits }),
+		counter("geo_provider_requests_total", "Requests sent to the geo-IP provider, retries included.",
+			func(s geo.MetricsSnapshot) int64 { return s.Requests }),
+		counter("geo_provider_retries_total", "Requests to the geo-IP provider that were retries.",
+			func(s geo.MetricsSnapshot) int64 { return s.Retries }),
+		counter("geo_breaker_rejections_total", "Geo-IP lookups rejected by the open circuit breaker.",
+			func(s geo.MetricsSnapshot) int64 { return s.Rejected }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "geo_breaker_open",
+			Help:      "Whether the circuit breaker of the geo-IP provider is open.",
+		}, func() float64 {
+			if m.Breaker() == geo.BreakerOpen {
+				return 1
+			}
+			return 0
+		}),
+	)
+}
+
+// Middleware counts requests and measures their latency by route template, so that
+// requests for different ids share their series. Requests of no route aren't counted.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, err := mux.CurrentRoute(r).GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+		start := time.Now()
+		rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		status := strconv.Itoa(rw.status)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveQuery records a repository call of the method started at start.
+func ObserveQuery(method string, start time.Time) {
+	queryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveGeoLookup records a geo-IP lookup, it is a geo.Observer.
+func ObserveGeoLookup(outcome string, elapsed time.Duration) {
+	geoLookups.WithLabelValues(outcome).Observe(elapsed.Seconds())
+}
+
+// ObserveLogin counts a login with the result.
+func ObserveLogin(result string) {
+	logins.WithLabelValues(result).Inc()
+}
+
+// ObserveToken counts an access token issued to the principal type.
+func ObserveToken(principal auth.PrincipalType) {
+	tokens.WithLabelValues(string(principal)).Inc()
+}
+
+// statusWriter keeps the status of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/dkischenko/xm_app/pkg/auth"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("[Ok] Requests are counted by route template", func(t *testing.T) {
+		router := mux.NewRouter()
+		router.Use(Middleware)
+		router.HandleFunc("/v1/companies/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, float64(1), testutil.ToFloat64(httpInFlight))
+			if mux.Vars(r)["id"] == "2" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}).Methods(http.MethodGet)
+
+		for _, path := range []string{"/v1/companies/1", "/v1/companies/3", "/v1/companies/2", "/unknown"} {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		route := "/v1/companies/{id:[0-9]+}"
+		assert.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues(route, http.MethodGet, "200")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(httpRequests.WithLabelValues(route, http.MethodGet, "404")))
+		assert.Equal(t, float64(0), testutil.ToFloat64(httpInFlight))
+		assert.Equal(t, 2, testutil.CollectAndCount(httpRequests))
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("[Ok] Serve text exposition format", func(t *testing.T) {
+		ObserveLogin(LoginRejected)
+		ObserveToken(auth.PrincipalGeo)
+		ObserveQuery("GetCompany", time.Now())
+		ObserveGeoLookup(geo.OutcomeUnavailable, time.Second)
+		router := mux.NewRouter()
+		Register(router)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Path, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+		body := w.Body.String()
+		for _, series := range []string{
+			`xm_app_logins_total{result="rejected"} 1`,
+			`xm_app_tokens_issued_total{principal="geo"} 1`,
+			`xm_app_db_query_duration_seconds_count{method="GetCompany"} 1`,
+			`xm_app_geo_lookup_duration_seconds_count{outcome="unavailable"} 1`,
+			`go_goroutines`,
+		} {
+			assert.Contains(t, body, series)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+// testMigrator returns a migrator of an empty schema in the database of TEST_DATABASE_URL,
+// the test is skipped when it isn't set. The xm_db schema of that database is dropped.
+func testMigrator(t *testing.T) (*Migrator, *pgxpool.Pool) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	if _, err := pool.Exec(ctx, `DROP SCHEMA IF EXISTS xm_db CASCADE`); err != nil {
+		t.Fatal(err)
+	}
+	l, err := logger.GetLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := NewMigrator(pool, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrator, pool
+}
+
+func TestMigration_ISOCountries(t *testing.T) {
+	testCases := []struct {
+		name     string
+		country  string
+		alpha2   string
+		hasError bool
+	}{
+		{name: "[Ok] ISO name", country: "Cyprus", alpha2: "CY"},
+		{name: "[Ok] Alpha-2 code", country: "cy", alpha2: "CY"},
+		{name: "[Ok] Alpha-3 code", country: "DEU", alpha2: "DE"},
+		{name: "[Ok] Short name", country: "Russia", alpha2: "RU"},
+		{name: "[Ok] Name in other case", country: "united kingdom", alpha2: "GB"},
+		{name: "[Ok] Abbreviation", country: "U.K.", alpha2: "GB"},
+		{name: "[Ok] Leading article", country: "The Netherlands", alpha2: "NL"},
+		{name: "[Ok] Iran", country: "Iran", alpha2: "IR"},
+		{name: "[Ok] Venezuela", country: " Venezuela ", alpha2: "VE"},
+		{name: "[Err] Unknown country", country: "Atlantis", hasError: true},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			migrator, pool := testMigrator(t)
+			ctx := context.Background()
+			if err := migrator.To(ctx, 6); err != nil {
+				t.Fatal(err)
+			}
+			_, err := pool.Exec(ctx, `
+				WITH country AS (INSERT INTO xm_db.countries(name) VALUES ($1) RETURNING id)
+				INSERT INTO xm_db.companies(name, code, country_id, website, phone)
+				SELECT 'Acme', 1, id, 'https://acme.com', '+35722000000' FROM country`, tcase.country)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = migrator.To(ctx, 7)
+			if tcase.hasError {
+				assert.ErrorContains(t, err, tcase.country)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			var alpha2 string
+			err = pool.QueryRow(ctx, `SELECT co.alpha2 FROM xm_db.companies c
+				JOIN xm_db.countries co ON co.id = c.country_id`).Scan(&alpha2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.alpha2, alpha2)
+		})
+	}
+}
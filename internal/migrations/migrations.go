@@ -0,0 +1,300 @@
+// Package migrations keeps the database schema in embedded, versioned
+// up/down SQL migrations and applies them.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockKey is the advisory lock held while migrating, so replicas don't race.
+const lockKey = 7310
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and the time it was applied at, zero if it is pending.
+type Status struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt int64  `json:"applied_at"`
+}
+
+type Migrator struct {
+	logger     *logger.Logger
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool, logger *logger.Logger) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		logger:     logger,
+		pool:       pool,
+		migrations: migrations,
+	}, nil
+}
+
+// Load reads migrations from the sql directory of fsys, ordered by version.
+// Every version must have both up and down files.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, "sql/"+e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		} else if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has different names: %q and %q", version, mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migration %d must have both up and down files", mg.Version)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the version the embedded migrations bring the schema to.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current schema version, zero for an empty database.
+func (m *Migrator) Version(ctx context.Context) (version int, err error) {
+	err = m.pool.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM xm_db.schema_migrations`).Scan(&version)
+	if err != nil {
+		var exists bool
+		if qErr := m.pool.QueryRow(ctx, `SELECT to_regclass('xm_db.schema_migrations') IS NOT NULL`).
+			Scan(&exists); qErr == nil && !exists {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+// Check returns an error unless the schema is at the version of the embedded migrations.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version != m.Latest() {
+		return fmt.Errorf("schema is at version %d, expected %d", version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the last applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		target := 0
+		for _, mg := range m.migrations {
+			if mg.Version < current {
+				target = mg.Version
+			}
+		}
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+// To applies or reverts migrations until the schema is at the given version.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Status returns every known migration with the time it was applied at.
+func (m *Migrator) Status(ctx context.Context) (statuses []Status, err error) {
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied := map[int]int64{}
+		rows, err := conn.Query(ctx, `SELECT version, applied_at FROM xm_db.schema_migrations`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				version   int
+				appliedAt int64
+			)
+			if err := rows.Scan(&version, &appliedAt); err != nil {
+				return err
+			}
+			applied[version] = appliedAt
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, mg := range m.migrations {
+			statuses = append(statuses, Status{Version: mg.Version, Name: mg.Name, AppliedAt: applied[mg.Version]})
+		}
+		return nil
+	})
+	return
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *pgxpool.Conn, current, target int) error {
+	if target > current {
+		for _, mg := range m.migrations {
+			if mg.Version <= current || mg.Version > target {
+				continue
+			}
+			m.logger.Entry.Infof("apply migration %d %s", mg.Version, mg.Name)
+			err := run(ctx, conn, mg.Up, `INSERT INTO xm_db.schema_migrations(version, name, applied_at)
+				VALUES ($1, $2, $3)`, mg.Version, mg.Name, time.Now().Unix())
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if mg.Version > current || mg.Version <= target {
+			continue
+		}
+		m.logger.Entry.Infof("revert migration %d %s", mg.Version, mg.Name)
+		err := run(ctx, conn, mg.Down, `DELETE FROM xm_db.schema_migrations WHERE version = $1`, mg.Version)
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+		}
+	}
+	return nil
+}
+
+// run executes the migration body and records it in one transaction.
+func run(ctx context.Context, conn *pgxpool.Conn, body string, record string, args ...interface{}) error {
+	return conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, body); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, args...)
+		return err
+	})
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			m.logger.Entry.Errorf("failed to release migration lock: %s", err)
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE SCHEMA IF NOT EXISTS xm_db;
+		CREATE TABLE IF NOT EXISTS xm_db.schema_migrations
+		(
+			version    integer PRIMARY KEY,
+			name       varchar not null,
+			applied_at bigint  not null
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func currentVersion(ctx context.Context, conn *pgxpool.Conn) (version int, err error) {
+	err = conn.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM xm_db.schema_migrations`).Scan(&version)
+	return
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
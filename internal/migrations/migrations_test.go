@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Embedded migrations are sequential and reversible", func(t *testing.T) {
+		migrations, err := Load(files)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		for i, mg := range migrations {
+			assert.Equal(t, i+1, mg.Version)
+			assert.NotEmpty(t, mg.Up)
+			assert.NotEmpty(t, mg.Down)
+		}
+	})
+
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{name: "Missing down file", files: fstest.MapFS{
+			"sql/0001_init.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{name: "Unexpected file name", files: fstest.MapFS{
+			"sql/init.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{name: "Names differ", files: fstest.MapFS{
+			"sql/0001_init.up.sql":    {Data: []byte("SELECT 1;")},
+			"sql/0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		}},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := Load(tcase.files)
+			assert.Error(t, err)
+		})
+	}
+}
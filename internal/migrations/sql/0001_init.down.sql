@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS xm_db.companies;
+DROP TABLE IF EXISTS xm_db.countries;
+DROP TABLE IF EXISTS xm_db.users;
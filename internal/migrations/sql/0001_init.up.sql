@@ -1,26 +1,26 @@
-CREATE schema xm_db;
-SET search_path to xm_db, public;
-CREATE TABLE IF NOT EXISTS users
+CREATE SCHEMA IF NOT EXISTS xm_db;
+
+CREATE TABLE IF NOT EXISTS xm_db.users
 (
     id            uuid default gen_random_uuid() PRIMARY KEY,
     username      varchar(100) not null unique,
     password_hash varchar(100) not null
 );
 
-CREATE TABLE IF NOT EXISTS countries
+CREATE TABLE IF NOT EXISTS xm_db.countries
 (
     id         serial PRIMARY KEY,
     name       varchar not null
 );
 
-CREATE TABLE IF NOT EXISTS companies
+CREATE TABLE IF NOT EXISTS xm_db.companies
 (
     id         serial PRIMARY KEY,
     name       varchar not null,
     code       integer not null,
-    country_id     serial    not null references countries (id),
+    country_id     serial    not null references xm_db.countries (id),
     website    varchar not null,
     phone      varchar not null,
     created_at integer default null,
     updated_at integer default null
-);
\ No newline at end of file
+);
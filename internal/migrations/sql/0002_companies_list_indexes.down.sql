@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS xm_db.companies_updated_at_id_idx;
+DROP INDEX IF EXISTS xm_db.companies_created_at_id_idx;
+DROP INDEX IF EXISTS xm_db.companies_code_id_idx;
+DROP INDEX IF EXISTS xm_db.companies_name_id_idx;
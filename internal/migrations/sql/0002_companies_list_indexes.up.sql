@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS companies_name_id_idx ON xm_db.companies (name, id);
+CREATE INDEX IF NOT EXISTS companies_code_id_idx ON xm_db.companies (code, id);
+CREATE INDEX IF NOT EXISTS companies_created_at_id_idx ON xm_db.companies (created_at, id);
+CREATE INDEX IF NOT EXISTS companies_updated_at_id_idx ON xm_db.companies (updated_at, id);
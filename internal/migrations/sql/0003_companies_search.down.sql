@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS xm_db.companies_website_trgm_idx;
+DROP INDEX IF EXISTS xm_db.companies_name_trgm_idx;
+DROP INDEX IF EXISTS xm_db.companies_search_vector_idx;
+
+ALTER TABLE xm_db.companies DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE xm_db.companies
+    ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(website, ''))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS companies_search_vector_idx ON xm_db.companies USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS companies_name_trgm_idx ON xm_db.companies USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS companies_website_trgm_idx ON xm_db.companies USING gin (website gin_trgm_ops);
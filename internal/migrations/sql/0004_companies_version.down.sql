@@ -0,0 +1 @@
+ALTER TABLE xm_db.companies DROP COLUMN IF EXISTS version;
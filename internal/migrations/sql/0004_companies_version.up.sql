@@ -0,0 +1 @@
+ALTER TABLE xm_db.companies ADD COLUMN IF NOT EXISTS version integer not null default 1;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS xm_db.companies_deleted_at_idx;
+
+ALTER TABLE xm_db.companies DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE xm_db.companies ADD COLUMN IF NOT EXISTS deleted_at integer default null;
+
+CREATE INDEX IF NOT EXISTS companies_deleted_at_idx ON xm_db.companies (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS xm_db.audit_events
+(
+    id         bigserial PRIMARY KEY,
+    entity     varchar(20)  not null,
+    entity_id  varchar(100) not null,
+    action     varchar(20)  not null,
+    actor_type varchar(20)  not null,
+    actor_id   varchar(100) not null default '',
+    ip         varchar(45)  not null default '',
+    before     jsonb        default null,
+    after      jsonb        default null,
+    created_at bigint       not null,
+    prev_hash  varchar(64)  not null default '',
+    hash       varchar(64)  not null
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_entity_idx ON xm_db.audit_events (entity, entity_id, id);
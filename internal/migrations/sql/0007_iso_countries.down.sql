@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS xm_db.countries_name_key;
+
+DELETE FROM xm_db.countries co
+WHERE NOT EXISTS (SELECT 1 FROM xm_db.companies c WHERE c.country_id = co.id);
+
+ALTER TABLE xm_db.countries
+    DROP COLUMN alpha2,
+    DROP COLUMN alpha3,
+    DROP COLUMN numeric_code,
+    DROP COLUMN region;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS xm_db.user_roles
+(
+    user_id uuid        not null REFERENCES xm_db.users (id) ON DELETE CASCADE,
+    role    varchar(20) not null CHECK (role IN ('admin', 'editor', 'viewer')),
+    PRIMARY KEY (user_id, role)
+);
+
+-- Every user could write before roles were introduced.
+INSERT INTO xm_db.user_roles (user_id, role)
+SELECT id, 'editor'
+FROM xm_db.users
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS xm_db.revoked_tokens;
+DROP TABLE IF EXISTS xm_db.refresh_tokens;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS xm_db.refresh_tokens
+(
+    id         bigserial PRIMARY KEY,
+    token_hash varchar(64) not null unique,
+    family_id  uuid        not null,
+    user_id    uuid        not null REFERENCES xm_db.users (id) ON DELETE CASCADE,
+    created_at bigint      not null,
+    expires_at bigint      not null,
+    used_at    bigint      default null,
+    revoked_at bigint      default null
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON xm_db.refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS xm_db.revoked_tokens
+(
+    jti        varchar(64) PRIMARY KEY,
+    expires_at bigint      not null
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS xm_db.api_keys;
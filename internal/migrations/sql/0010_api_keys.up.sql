@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS xm_db.api_keys
+(
+    id           uuid         default gen_random_uuid() PRIMARY KEY,
+    name         varchar(100) not null,
+    prefix       varchar(16)  not null unique,
+    key_hash     varchar(64)  not null,
+    scopes       text[]       not null,
+    created_by   varchar(100) not null default '',
+    created_at   bigint       not null,
+    expires_at   bigint       default null,
+    revoked_at   bigint       default null,
+    last_used_at bigint       default null,
+    last_used_ip varchar(45)  default null
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS xm_db.companies_updated_at_sort_idx;
+DROP INDEX IF EXISTS xm_db.companies_created_at_sort_idx;
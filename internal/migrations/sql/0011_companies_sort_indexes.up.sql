@@ -0,0 +1,4 @@
+-- The list sorts on these expressions, so that companies without timestamps come first;
+-- the (created_at, id) and (updated_at, id) indexes only serve the range filters.
+CREATE INDEX IF NOT EXISTS companies_created_at_sort_idx ON xm_db.companies ((coalesce(created_at, 0)), id);
+CREATE INDEX IF NOT EXISTS companies_updated_at_sort_idx ON xm_db.companies ((coalesce(updated_at, 0)), id);
@@ -0,0 +1,153 @@
+// Renders the OpenAPI document of data-spec-url into #docs. Text is only ever set with
+// textContent, so nothing of the document is interpreted as markup.
+(function () {
+  "use strict";
+
+  var methods = ["get", "put", "post", "patch", "delete", "head", "options"];
+  var root = document.getElementById("docs");
+
+  function el(tag, text, className) {
+    var node = document.createElement(tag);
+    if (text !== undefined) {
+      node.textContent = text;
+    }
+    if (className) {
+      node.className = className;
+    }
+    return node;
+  }
+
+  // resolve follows a local $ref such as #/components/responses/NotFound.
+  function resolve(spec, obj) {
+    if (!obj || !obj.$ref || obj.$ref.indexOf("#/") !== 0) {
+      return obj;
+    }
+    return obj.$ref.slice(2).split("/").reduce(function (cur, key) {
+      return cur && cur[key.replace(/~1/g, "/").replace(/~0/g, "~")];
+    }, spec);
+  }
+
+  function refName(obj) {
+    return obj && obj.$ref ? obj.$ref.split("/").pop() : "";
+  }
+
+  function json(value) {
+    return el("pre", JSON.stringify(value, null, 2));
+  }
+
+  function operation(spec, path, method, pathItem, op) {
+    var node = el("details");
+    var head = el("summary");
+    head.appendChild(el("span", method, "method"));
+    head.appendChild(el("code", path));
+    if (op.summary) {
+      head.appendChild(document.createTextNode(" — " + op.summary));
+    }
+    node.appendChild(head);
+    if (op.description) {
+      node.appendChild(el("p", op.description));
+    }
+    if (op.security) {
+      node.appendChild(el("p", "Security: " + op.security.map(function (s) {
+        var names = Object.keys(s);
+        return names.length ? names.join(" + ") : "none";
+      }).join(", ")));
+    }
+
+    var params = (pathItem.parameters || []).concat(op.parameters || []).map(function (p) {
+      return resolve(spec, p);
+    });
+    if (params.length) {
+      node.appendChild(el("h4", "Parameters"));
+      var list = el("ul");
+      params.forEach(function (p) {
+        var item = el("li");
+        item.appendChild(el("code", p.name));
+        item.appendChild(document.createTextNode(" (" + p.in + (p.required ? ", required" : "") + ")" +
+          (p.description ? ": " + p.description : "")));
+        list.appendChild(item);
+      });
+      node.appendChild(list);
+    }
+
+    var body = resolve(spec, op.requestBody);
+    if (body && body.content) {
+      node.appendChild(el("h4", "Request body"));
+      Object.keys(body.content).forEach(function (type) {
+        var schema = body.content[type].schema;
+        node.appendChild(el("p", type + (refName(schema) ? ": " + refName(schema) : "")));
+      });
+    }
+
+    node.appendChild(el("h4", "Responses"));
+    var responses = el("ul");
+    Object.keys(op.responses || {}).forEach(function (status) {
+      var res = resolve(spec, op.responses[status]) || {};
+      var item = el("li");
+      item.appendChild(el("code", status));
+      var text = " " + (res.description || "");
+      Object.keys(res.content || {}).forEach(function (type) {
+        var name = refName(res.content[type].schema);
+        text += " (" + type + (name ? ": " + name : "") + ")";
+      });
+      item.appendChild(document.createTextNode(text));
+      responses.appendChild(item);
+    });
+    node.appendChild(responses);
+    return node;
+  }
+
+  function render(spec) {
+    root.textContent = "";
+    root.appendChild(el("h1", spec.info.title + " " + spec.info.version));
+    if (spec.info.description) {
+      root.appendChild(el("p", spec.info.description));
+    }
+    var link = el("a", "OpenAPI document");
+    link.href = root.dataset.specUrl;
+    root.appendChild(link);
+
+    var tags = {};
+    Object.keys(spec.paths).forEach(function (path) {
+      var pathItem = spec.paths[path];
+      methods.forEach(function (method) {
+        var op = pathItem[method];
+        if (!op) {
+          return;
+        }
+        (op.tags || ["default"]).forEach(function (tag) {
+          (tags[tag] = tags[tag] || []).push(operation(spec, path, method, pathItem, op));
+        });
+      });
+    });
+    Object.keys(tags).forEach(function (tag) {
+      root.appendChild(el("h2", tag));
+      tags[tag].forEach(function (node) {
+        root.appendChild(node);
+      });
+    });
+
+    var schemas = (spec.components && spec.components.schemas) || {};
+    if (Object.keys(schemas).length) {
+      root.appendChild(el("h2", "Schemas"));
+      Object.keys(schemas).forEach(function (name) {
+        var node = el("details");
+        node.appendChild(el("summary", name));
+        node.appendChild(json(schemas[name]));
+        root.appendChild(node);
+      });
+    }
+  }
+
+  fetch(root.dataset.specUrl)
+    .then(function (res) {
+      if (!res.ok) {
+        throw new Error(res.status + " " + res.statusText);
+      }
+      return res.json();
+    })
+    .then(render)
+    .catch(function (err) {
+      root.textContent = "Couldn't load the OpenAPI document: " + err.message;
+    });
+})();
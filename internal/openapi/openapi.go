@@ -0,0 +1,62 @@
+// Package openapi publishes the OpenAPI 3 document of the API and validates
+// requests and responses against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+	// docsScriptPath serves the script rendering the docs page, so it loads nothing from other origins.
+	docsScriptPath = DocsPath + "/docs.js"
+	// docsPolicy keeps what Register serves to scripts and requests of the app itself.
+	docsPolicy = "default-src 'self'; style-src 'self' 'unsafe-inline'; object-src 'none'; base-uri 'none'"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+//go:embed docs.js
+var docsScript []byte
+
+// Spec returns the OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+// Load parses and checks the OpenAPI document.
+func Load(ctx context.Context) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err = doc.Validate(ctx); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Register serves the document at SpecPath and a page browsing it at DocsPath.
+func Register(router *mux.Router) {
+	router.HandleFunc(SpecPath, serve("application/json", spec)).Methods(http.MethodGet)
+	router.HandleFunc(DocsPath, serve("text/html; charset=utf-8", docs)).Methods(http.MethodGet)
+	router.HandleFunc(docsScriptPath, serve("text/javascript; charset=utf-8", docsScript)).Methods(http.MethodGet)
+}
+
+func serve(contentType string, body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}
+}
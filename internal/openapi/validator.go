@@ -0,0 +1,150 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/dkischenko/xm_app/pkg/requestid"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const (
+	codeInvalidRequest       = "invalid_request"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeInvalidResponse      = "invalid_response"
+)
+
+func init() {
+	// Patch documents are JSON, the filter only knows the plain JSON media types.
+	decoder := openapi3filter.RegisteredBodyDecoder("application/json")
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", decoder)
+	openapi3filter.RegisterBodyDecoder("application/json-patch+json", decoder)
+}
+
+// Validator checks requests, and optionally responses, of the operations the document describes.
+// Requests of other routes pass unchecked.
+type Validator struct {
+	logger    *logger.Logger
+	router    routers.Router
+	responses bool
+}
+
+// NewValidator returns a validator of the embedded document. Checking responses buffers
+// them whole and replaces those not matching the document with 500, it is meant for tests.
+func NewValidator(logger *logger.Logger, responses bool) (*Validator, error) {
+	doc, err := Load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{logger: logger, router: router, responses: responses}, nil
+}
+
+// Middleware answers 400 to requests not matching the document, or 415 to bodies of an
+// undocumented media type. Credentials are left to the handlers.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+				MultiError:         true,
+			},
+		}
+		if !supportedMediaType(input) {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType,
+				"the request body has an unsupported media type")
+			return
+		}
+		if err = openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if !v.responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 rec.header,
+			Body:                   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+		})
+		if err != nil {
+			v.logger.Ctx(r.Context()).Errorf("%s %s answered %d not matching the OpenAPI document: %v",
+				r.Method, r.URL.Path, rec.status, err)
+			writeProblem(w, r, http.StatusInternalServerError, codeInvalidResponse, err.Error())
+			return
+		}
+		for key, values := range rec.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+	})
+}
+
+// supportedMediaType reports whether the request has no body or one of a media type the operation accepts.
+func supportedMediaType(input *openapi3filter.RequestValidationInput) bool {
+	body := input.Route.Operation.RequestBody
+	if body == nil || body.Value == nil || input.Request.ContentLength == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(input.Request.Header.Get("Content-Type"))
+	return err == nil && body.Value.Content.Get(mediaType) != nil
+}
+
+// recorder keeps a response to check it before it is written.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(uerrors.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestId: requestid.FromContext(r.Context()),
+	})
+}
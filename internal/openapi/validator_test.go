@@ -0,0 +1,118 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	uerrors "github.com/dkischenko/xm_app/internal/errors"
+	"github.com/dkischenko/xm_app/internal/openapi"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("[Ok] Embedded document is valid", func(t *testing.T) {
+		doc, err := openapi.Load(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotNil(t, doc.Paths.Find("/v1/companies/{id}"))
+	})
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("[Ok] Serve document and docs page", func(t *testing.T) {
+		router := mux.NewRouter()
+		openapi.Register(router)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openapi.SpecPath, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, openapi.Spec(), w.Body.Bytes())
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openapi.DocsPath, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), openapi.SpecPath)
+		assert.NotContains(t, w.Body.String(), "https://")
+		assert.Contains(t, w.Header().Get("Content-Security-Policy"), "default-src 'self'")
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openapi.DocsPath+"/docs.js", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "fetch(")
+	})
+}
+
+func TestValidator_Middleware(t *testing.T) {
+	testCases := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		// response is written by the handler behind the validator.
+		response string
+		status   int
+		code     string
+	}{
+		{name: "[Ok] Valid request", method: http.MethodPost, path: "/v1/users", contentType: "application/json",
+			body: `{"name": "bill", "password": "password"}`, response: `{"id": "c9f44c4a-788a-4d5f-a210-94ccafcc2231", "name": "bill"}`,
+			status: http.StatusOK},
+		{name: "[Ok] Readiness", method: http.MethodGet, path: "/readyz", response: `{"status": "degraded"}`,
+			status: http.StatusOK},
+		{name: "[Ok] Undocumented route", method: http.MethodGet, path: "/undocumented", response: `{}`,
+			status: http.StatusOK},
+		{name: "[Err] Missing field", method: http.MethodPost, path: "/v1/users", contentType: "application/json",
+			body: `{"name": "bill"}`, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "[Err] Wrong path param", method: http.MethodGet, path: "/v1/companies/abc/history",
+			status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "[Err] Wrong query param", method: http.MethodGet, path: "/v1/companies?limit=1000",
+			status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "[Err] Unsupported media type", method: http.MethodPatch, path: "/v1/companies/1",
+			contentType: "text/plain", body: `name=Acme`, status: http.StatusUnsupportedMediaType,
+			code: "unsupported_media_type"},
+		{name: "[Err] Response not matching", method: http.MethodPost, path: "/v1/users", contentType: "application/json",
+			body: `{"name": "bill", "password": "password"}`, response: `{"id": 1}`,
+			status: http.StatusInternalServerError, code: "invalid_response"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			l, _ := logger.GetLogger()
+			v, err := openapi.NewValidator(l, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(tcase.response))
+			})
+
+			req := httptest.NewRequest(tcase.method, tcase.path, strings.NewReader(tcase.body))
+			if tcase.contentType != "" {
+				req.Header.Set("Content-Type", tcase.contentType)
+			}
+			w := httptest.NewRecorder()
+			v.Middleware(next).ServeHTTP(w, req)
+
+			assert.Equal(t, tcase.status, w.Code)
+			if tcase.code == "" {
+				assert.Equal(t, tcase.response, w.Body.String())
+				return
+			}
+			problem := uerrors.Problem{}
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.code, problem.Code)
+		})
+	}
+}
@@ -0,0 +1,319 @@
+// Package policy decides which callers without an access token may run an operation,
+// by their country, network address and the time of the request.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dkischenko/xm_app/internal/config"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	OperationCompanyCreate  = "company.create"
+	OperationCompanyUpdate  = "company.update"
+	OperationCompanyDelete  = "company.delete"
+	OperationCompanyRestore = "company.restore"
+
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Operations are all operations a policy can grant to anonymous callers.
+var Operations = []string{OperationCompanyCreate, OperationCompanyUpdate, OperationCompanyDelete, OperationCompanyRestore}
+
+// DefaultRules apply when the configuration has none.
+var DefaultRules = []config.PolicyRule{{
+	Name:       "cyprus",
+	Countries:  []string{"CY"},
+	Effect:     EffectAllow,
+	GrantToken: true,
+}}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Request is an operation attempted by a caller at a time.
+type Request struct {
+	Operation string
+	IP        net.IP
+	Time      time.Time
+}
+
+// Decision is the outcome of evaluating the policy for a request.
+type Decision struct {
+	Operation  string `json:"operation"`
+	Allowed    bool   `json:"allowed"`
+	GrantToken bool   `json:"grant_token"`
+	// Rule is the name of the matched rule, empty when the default applied.
+	Rule        string `json:"rule,omitempty"`
+	Country     string `json:"country,omitempty"`
+	CountryName string `json:"country_name,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+type Engine struct {
+	logger       *logger.Logger
+	locator      geo.GeoLocator
+	rules        []rule
+	defaultAllow bool
+	// failOpen makes country conditions favour allowing while the geo-IP provider is unavailable.
+	failOpen bool
+}
+
+type rule struct {
+	name       string
+	operations map[string]bool
+	countries  map[string]bool
+	networks   []*net.IPNet
+	window     *window
+	allow      bool
+	grantToken bool
+}
+
+type window struct {
+	days     map[time.Weekday]bool
+	from, to int
+	location *time.Location
+}
+
+// New compiles the policy. Country conditions are checked with the locator; failOpen
+// tells how to treat them while the locator is unavailable.
+func New(cfg config.Policy, locator geo.GeoLocator, failOpen bool, logger *logger.Logger) (*Engine, error) {
+	e := &Engine{
+		logger:   logger,
+		locator:  locator,
+		failOpen: failOpen,
+	}
+
+	switch cfg.Default {
+	case "", EffectDeny:
+	case EffectAllow:
+		e.defaultAllow = true
+	default:
+		return nil, fmt.Errorf("policy: unknown default effect %q", cfg.Default)
+	}
+
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	for i, rc := range rules {
+		r, err := compileRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("policy: rule %d %q: %w", i+1, rc.Name, err)
+		}
+		e.rules = append(e.rules, r)
+	}
+
+	return e, nil
+}
+
+func compileRule(rc config.PolicyRule) (r rule, err error) {
+	r.name, r.grantToken = rc.Name, rc.GrantToken
+	switch rc.Effect {
+	case EffectAllow:
+		r.allow = true
+	case EffectDeny:
+	default:
+		return r, fmt.Errorf("unknown effect %q", rc.Effect)
+	}
+
+	if len(rc.Operations) > 0 {
+		r.operations = map[string]bool{}
+		for _, op := range rc.Operations {
+			if !IsOperation(op) {
+				return r, fmt.Errorf("unknown operation %q", op)
+			}
+			r.operations[op] = true
+		}
+	}
+
+	if len(rc.Countries) > 0 {
+		r.countries = map[string]bool{}
+		for _, c := range rc.Countries {
+			if len(c) != 2 {
+				return r, fmt.Errorf("country %q is not an ISO 3166-1 alpha-2 code", c)
+			}
+			r.countries[strings.ToUpper(c)] = true
+		}
+	}
+
+	for _, cidr := range rc.CIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return r, err
+		}
+		r.networks = append(r.networks, network)
+	}
+
+	if rc.Window != nil {
+		if r.window, err = compileWindow(*rc.Window); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+func compileWindow(wc config.PolicyWindow) (*window, error) {
+	w := &window{location: time.UTC}
+	var err error
+	if wc.Timezone != "" {
+		if w.location, err = time.LoadLocation(wc.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	if w.from, err = parseClock(wc.From); err != nil {
+		return nil, err
+	}
+	if w.to, err = parseClock(wc.To); err != nil {
+		return nil, err
+	}
+	if len(wc.Days) > 0 {
+		w.days = map[time.Weekday]bool{}
+		for _, d := range wc.Days {
+			day, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("unknown day %q", d)
+			}
+			w.days[day] = true
+		}
+	}
+	return w, nil
+}
+
+// parseClock returns the minutes since midnight of a "15:04" time.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not in the 15:04 format", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsOperation reports whether op is one of Operations.
+func IsOperation(op string) bool {
+	for _, known := range Operations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate returns the decision of the first rule matching the request, or the default one.
+// The caller country is looked up only if a rule depends on it.
+func (e *Engine) Evaluate(ctx context.Context, req Request) Decision {
+	d := Decision{Operation: req.Operation}
+
+	var (
+		located   bool
+		locateErr error
+		location  geo.Location
+	)
+	for _, r := range e.rules {
+		if !r.matches(req) {
+			continue
+		}
+
+		unavailable := false
+		if r.countries != nil {
+			if !located {
+				located = true
+				location, locateErr = e.locator.Locate(ctx, req.IP)
+				if locateErr != nil && !errors.Is(locateErr, geo.ErrNotFound) {
+					e.logger.Ctx(ctx).Errorf("can't locate %s: %v", req.IP, locateErr)
+				}
+				d.Country, d.CountryName = location.CountryCode, location.CountryName
+			}
+			switch {
+			case locateErr == nil:
+				if !r.countries[location.CountryCode] {
+					continue
+				}
+			case errors.Is(locateErr, geo.ErrUnavailable):
+				// The country is unknown, assume the one the fail policy favours.
+				if r.allow != e.failOpen {
+					continue
+				}
+				unavailable = true
+			default:
+				continue
+			}
+		}
+
+		d.Allowed, d.GrantToken, d.Rule = r.allow, r.allow && r.grantToken, r.name
+		d.Reason = fmt.Sprintf("matched rule %q", r.name)
+		if unavailable {
+			d.Reason += " while the geo-IP provider is unavailable"
+		}
+		return d
+	}
+
+	d.Allowed = e.defaultAllow
+	d.Reason = "no rule matched, the default applies"
+	return d
+}
+
+// Grants returns the operations the caller at the address may run with a granted token,
+// that is those allowed to it by a rule that grants tokens.
+func (e *Engine) Grants(ctx context.Context, ip net.IP, t time.Time) []string {
+	var operations []string
+	for _, op := range Operations {
+		if d := e.Evaluate(ctx, Request{Operation: op, IP: ip, Time: t}); d.GrantToken {
+			operations = append(operations, op)
+		}
+	}
+	return operations
+}
+
+// Locate returns the location of the address as the policy sees it.
+func (e *Engine) Locate(ctx context.Context, ip net.IP) (geo.Location, error) {
+	return e.locator.Locate(ctx, ip)
+}
+
+func (r rule) matches(req Request) bool {
+	if r.operations != nil && !r.operations[req.Operation] {
+		return false
+	}
+	if len(r.networks) > 0 && !r.inNetworks(req.IP) {
+		return false
+	}
+	return r.window == nil || r.window.contains(req.Time)
+}
+
+func (r rule) inNetworks(ip net.IP) bool {
+	for _, network := range r.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w window) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if w.from <= w.to {
+		return minute >= w.from && minute < w.to && w.onDay(day)
+	}
+	// The window spans midnight, its early morning part belongs to the day before.
+	if minute >= w.from {
+		return w.onDay(day)
+	}
+	return minute < w.to && w.onDay((day+6)%7)
+}
+
+func (w window) onDay(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/internal/config"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type stubLocator struct {
+	calls    int
+	location geo.Location
+	err      error
+}
+
+func (s *stubLocator) Locate(ctx context.Context, ip net.IP) (geo.Location, error) {
+	s.calls++
+	return s.location, s.err
+}
+
+var (
+	cyprus = geo.Location{CountryCode: "CY", CountryName: "Cyprus"}
+	greece = geo.Location{CountryCode: "GR", CountryName: "Greece"}
+	// monday is a Monday noon in UTC.
+	monday = time.Date(2022, time.May, 2, 12, 0, 0, 0, time.UTC)
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	rules := config.Policy{Rules: []config.PolicyRule{
+		{Name: "office", CIDRs: []string{"10.0.0.0/8"}, Effect: EffectAllow},
+		{Name: "no-night-deletes", Operations: []string{OperationCompanyDelete}, Countries: []string{"CY"},
+			Window: &config.PolicyWindow{From: "22:00", To: "06:00", Timezone: "Asia/Nicosia"}, Effect: EffectDeny},
+		{Name: "cyprus-workdays", Countries: []string{"cy"}, Effect: EffectAllow, GrantToken: true,
+			Window: &config.PolicyWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "08:00", To: "20:00",
+				Timezone: "Asia/Nicosia"}},
+	}}
+
+	testCases := []struct {
+		name      string
+		operation string
+		ip        string
+		at        time.Time
+		location  geo.Location
+		geoErr    error
+		failOpen  bool
+		allowed   bool
+		grant     bool
+		rule      string
+	}{
+		{name: "CIDR matches without locating", operation: OperationCompanyCreate, ip: "10.1.2.3", at: monday,
+			geoErr: geo.ErrUnavailable, allowed: true, rule: "office"},
+		{name: "Country in window", operation: OperationCompanyDelete, ip: "31.153.0.1", at: monday, location: cyprus,
+			allowed: true, grant: true, rule: "cyprus-workdays"},
+		{name: "Country out of window", operation: OperationCompanyCreate, ip: "31.153.0.1",
+			at: monday.Add(-24 * time.Hour), location: cyprus},
+		{name: "Overnight window after midnight", operation: OperationCompanyDelete, ip: "31.153.0.1",
+			at: time.Date(2022, time.May, 3, 0, 30, 0, 0, time.UTC), location: cyprus, rule: "no-night-deletes"},
+		{name: "Overnight window does not cover other operations", operation: OperationCompanyCreate,
+			ip: "31.153.0.1", at: time.Date(2022, time.May, 3, 0, 30, 0, 0, time.UTC), location: cyprus},
+		{name: "Other country", operation: OperationCompanyCreate, ip: "31.153.0.1", at: monday, location: greece},
+		{name: "Unknown location", operation: OperationCompanyCreate, ip: "31.153.0.1", at: monday,
+			geoErr: geo.ErrNotFound},
+		{name: "Provider down, fail closed", operation: OperationCompanyCreate, ip: "31.153.0.1", at: monday,
+			geoErr: geo.ErrUnavailable},
+		{name: "Provider down, fail open", operation: OperationCompanyCreate, ip: "31.153.0.1", at: monday,
+			geoErr: geo.ErrUnavailable, failOpen: true, allowed: true, grant: true, rule: "cyprus-workdays"},
+		{name: "Provider down, fail open skips deny rules", operation: OperationCompanyDelete, ip: "31.153.0.1",
+			at: time.Date(2022, time.May, 2, 23, 0, 0, 0, time.UTC), geoErr: geo.ErrUnavailable, failOpen: true},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			l, _ := logger.GetLogger()
+			locator := &stubLocator{location: tcase.location, err: tcase.geoErr}
+			e, err := New(rules, locator, tcase.failOpen, l)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			d := e.Evaluate(context.Background(), Request{Operation: tcase.operation, IP: net.ParseIP(tcase.ip), Time: tcase.at})
+			assert.Equal(t, tcase.allowed, d.Allowed)
+			assert.Equal(t, tcase.grant, d.GrantToken)
+			assert.Equal(t, tcase.rule, d.Rule)
+			assert.LessOrEqual(t, locator.calls, 1)
+		})
+	}
+}
+
+func TestEngine_Default(t *testing.T) {
+	l, _ := logger.GetLogger()
+	locator := &stubLocator{location: cyprus}
+	e, err := New(config.Policy{}, locator, false, l)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	d := e.Evaluate(context.Background(), Request{Operation: OperationCompanyRestore, IP: net.ParseIP("31.153.0.1"), Time: monday})
+	assert.Equal(t, Decision{Operation: OperationCompanyRestore, Allowed: true, GrantToken: true, Rule: "cyprus",
+		Country: "CY", CountryName: "Cyprus", Reason: `matched rule "cyprus"`}, d)
+
+	e, err = New(config.Policy{Default: EffectAllow, Rules: []config.PolicyRule{
+		{Name: "greece", Countries: []string{"GR"}, Effect: EffectDeny},
+	}}, locator, false, l)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	d = e.Evaluate(context.Background(), Request{Operation: OperationCompanyCreate, IP: net.ParseIP("31.153.0.1"), Time: monday})
+	assert.True(t, d.Allowed)
+	assert.False(t, d.GrantToken)
+	assert.Empty(t, d.Rule)
+}
+
+func TestEngine_Grants(t *testing.T) {
+	l, _ := logger.GetLogger()
+	e, err := New(config.Policy{Rules: []config.PolicyRule{
+		{Name: "no-deletes", Operations: []string{OperationCompanyDelete}, Effect: EffectDeny},
+		{Name: "office", CIDRs: []string{"10.0.0.0/8"}, Effect: EffectAllow},
+		{Name: "cyprus-creates", Operations: []string{OperationCompanyCreate, OperationCompanyRestore},
+			Countries: []string{"CY"}, Effect: EffectAllow, GrantToken: true},
+	}}, &stubLocator{location: cyprus}, false, l)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	assert.Equal(t, []string{OperationCompanyCreate, OperationCompanyRestore},
+		e.Grants(context.Background(), net.ParseIP("31.153.0.1"), monday))
+	assert.Empty(t, e.Grants(context.Background(), net.ParseIP("10.1.2.3"), monday))
+}
+
+func TestNew_Errors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy config.Policy
+		err    string
+	}{
+		{name: "Unknown default", policy: config.Policy{Default: "maybe"}, err: `unknown default effect "maybe"`},
+		{name: "Unknown effect", policy: config.Policy{Rules: []config.PolicyRule{{Name: "r"}}}, err: `unknown effect ""`},
+		{name: "Unknown operation", policy: config.Policy{Rules: []config.PolicyRule{
+			{Name: "r", Effect: EffectAllow, Operations: []string{"company.read"}}}}, err: `unknown operation "company.read"`},
+		{name: "Wrong country", policy: config.Policy{Rules: []config.PolicyRule{
+			{Name: "r", Effect: EffectAllow, Countries: []string{"Cyprus"}}}}, err: "not an ISO 3166-1 alpha-2 code"},
+		{name: "Wrong CIDR", policy: config.Policy{Rules: []config.PolicyRule{
+			{Name: "r", Effect: EffectAllow, CIDRs: []string{"10.0.0.0"}}}}, err: "invalid CIDR address"},
+		{name: "Wrong time", policy: config.Policy{Rules: []config.PolicyRule{
+			{Name: "r", Effect: EffectAllow, Window: &config.PolicyWindow{From: "8am", To: "20:00"}}}},
+			err: `time "8am" is not in the 15:04 format`},
+		{name: "Wrong day", policy: config.Policy{Rules: []config.PolicyRule{
+			{Name: "r", Effect: EffectAllow, Window: &config.PolicyWindow{Days: []string{"monday"}, From: "08:00", To: "20:00"}}}},
+			err: `unknown day "monday"`},
+		{name: "Wrong timezone", policy: config.Policy{Rules: []config.PolicyRule{
+			{Name: "r", Effect: EffectAllow, Window: &config.PolicyWindow{From: "08:00", To: "20:00", Timezone: "Mars/Olympus"}}}},
+			err: "Mars/Olympus"},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			l, _ := logger.GetLogger()
+			_, err := New(tcase.policy, &stubLocator{}, false, l)
+			assert.Error(t, err)
+			if err != nil {
+				assert.Contains(t, err.Error(), tcase.err)
+			}
+		})
+	}
+}
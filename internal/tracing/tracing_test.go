@@ -0,0 +1,64 @@
+package tracing_test
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/internal/tracing"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{name: "[Ok] No exporter", exporter: tracing.ExporterNone},
+		{name: "[Ok] OTLP exporter", exporter: tracing.ExporterOTLP},
+		{name: "[Err] Unknown exporter", exporter: "jaeger", wantErr: true},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			previous := otel.GetTracerProvider()
+			defer otel.SetTracerProvider(previous)
+
+			shutdown, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tcase.exporter,
+				Endpoint: "localhost:4318", Insecure: true, SampleRatio: 1})
+			if tcase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	t.Run("[Ok] Continue the trace of the caller", func(t *testing.T) {
+		shutdown, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer shutdown(context.Background())
+
+		var traceId trace.TraceID
+		router := mux.NewRouter()
+		router.Use(otelmux.Middleware(tracing.ServiceName))
+		router.HandleFunc("/v1/companies/{id}", func(w http.ResponseWriter, r *http.Request) {
+			traceId = trace.SpanContextFromContext(r.Context()).TraceID()
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/companies/7", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceId.String())
+	})
+}
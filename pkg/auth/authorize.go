@@ -1,7 +1,11 @@
 package auth
 
+import "context"
+
 //go:generate mockgen -source=authorize.go -destination=mocks/authorize_mock.go
 type Authorize interface {
-	CreateJWT(userId string) (string, error)
-	ParseJWT(token string) (string, error)
+	CreateJWT(claims Claims) (string, error)
+	ParseJWT(ctx context.Context, token string) (claims Claims, err error)
+	// JWKS returns the public keys tokens are verified with.
+	JWKS() JWKS
 }
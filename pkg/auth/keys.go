@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing keys.
+const minRSABits = 2048
+
+// Key is a key tokens are signed or verified with, identified in token headers by its kid.
+type Key struct {
+	Id     string
+	method jwt.SigningMethod
+	// sign is the private key, nil for keys kept only to verify tokens they signed before.
+	sign interface{}
+	// verify is the public key, or the secret of HMAC keys.
+	verify interface{}
+}
+
+// Algorithm returns the JWS algorithm of the key, such as RS256.
+func (k Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// CanSign reports whether the key has its private part.
+func (k Key) CanSign() bool {
+	return k.sign != nil
+}
+
+// HMACKey returns a key signing with HS256. Its secret can't be published, so
+// only this service can verify the tokens.
+func HMACKey(id string, secret []byte) Key {
+	return Key{Id: id, method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+// ParseKey parses a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1) or public key (PKIX).
+// The algorithm follows from the key type: RS256 for RSA, ES256, ES384 or ES512 for
+// the P-256, P-384 and P-521 curves and EdDSA for Ed25519.
+func ParseKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("key %q: no PEM data found", id)
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	key := Key{Id: id}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.sign, parsed = signer, signer.Public()
+	}
+	key.verify = parsed
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return Key{}, fmt.Errorf("key %q: RSA keys must have at least %d bits", id, minRSABits)
+		}
+		key.method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			key.method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.method = jwt.SigningMethodES512
+		default:
+			return Key{}, fmt.Errorf("key %q: unsupported curve %s", id, pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return Key{}, fmt.Errorf("key %q: unsupported key type %T", id, pub)
+	}
+	return key, nil
+}
+
+// LoadKeyFile reads a PEM key file, see ParseKey.
+func LoadKeyFile(id string, path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	return ParseKey(id, data)
+}
+
+// LoadKeyDir reads the *.pem files of the directory, each one is a key whose id is the file name without extension.
+func LoadKeyDir(dir string) ([]Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]Key, 0, len(paths))
+	for _, path := range paths {
+		key, err := LoadKeyFile(strings.TrimSuffix(filepath.Base(path), ".pem"), path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeySet holds the active signing key and every key tokens are still verified with.
+// Replacing the keys rotates them while the service runs.
+type KeySet struct {
+	mu     sync.RWMutex
+	keys   map[string]Key
+	active string
+}
+
+// NewKeySet returns a key set signing with the key whose id is active. An empty active
+// id selects the only key that can sign.
+func NewKeySet(active string, keys ...Key) (*KeySet, error) {
+	ks := &KeySet{}
+	if err := ks.Replace(active, keys...); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Replace swaps the keys of the set. Tokens signed by keys left in the set stay valid,
+// so a retired key should be kept until the tokens it signed expire.
+func (ks *KeySet) Replace(active string, keys ...Key) error {
+	byId := make(map[string]Key, len(keys))
+	for _, key := range keys {
+		if _, ok := byId[key.Id]; ok {
+			return fmt.Errorf("duplicate key id %q", key.Id)
+		}
+		byId[key.Id] = key
+	}
+
+	if active == "" {
+		signers := 0
+		for _, key := range keys {
+			if key.CanSign() {
+				active = key.Id
+				signers++
+			}
+		}
+		switch {
+		case signers == 0:
+			return errors.New("no key can sign")
+		case signers > 1:
+			return errors.New("several keys can sign, the active one has to be chosen")
+		}
+	}
+	if key, ok := byId[active]; !ok {
+		return fmt.Errorf("unknown active key %q", active)
+	} else if !key.CanSign() {
+		return fmt.Errorf("active key %q has no private key", active)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys, ks.active = byId, active
+	return nil
+}
+
+// Active returns the key new tokens are signed with.
+func (ks *KeySet) Active() Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[ks.active]
+}
+
+// Key returns the key with the id.
+func (ks *KeySet) Key(id string) (Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[id]
+	return key, ok
+}
+
+// JWK is a public key in the JSON Web Key format, see RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set ordered by id. HMAC keys are secret and left out.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.Id, Use: "sig", Alg: key.Algorithm()}
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBase64(pub.N.Bytes())
+			jwk.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty, jwk.Crv = "EC", pub.Curve.Params().Name
+			jwk.X = encodeBase64(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = encodeBase64(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = encodeBase64(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func privatePEM(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicPEM(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestParseKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	weakKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		alg     string
+		canSign bool
+		wantErr bool
+	}{
+		{name: "[Ok] RSA PKCS#8", data: privatePEM(t, rsaKey), alg: "RS256", canSign: true},
+		{name: "[Ok] RSA PKCS#1", alg: "RS256", canSign: true,
+			data: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})},
+		{name: "[Ok] ECDSA P-256", data: privatePEM(t, ecKey), alg: "ES256", canSign: true},
+		{name: "[Ok] Ed25519", data: privatePEM(t, edKey), alg: "EdDSA", canSign: true},
+		{name: "[Ok] Public key", data: publicPEM(t, rsaKey.Public()), alg: "RS256"},
+		{name: "[Err] Weak RSA key", data: privatePEM(t, weakKey), wantErr: true},
+		{name: "[Err] Not PEM", data: []byte("secret"), wantErr: true},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			key, err := ParseKey("k1", tcase.data)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			assert.Equal(t, tcase.alg, key.Algorithm())
+			assert.Equal(t, tcase.canSign, key.CanSign())
+		})
+	}
+}
+
+func TestManager_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	oldKey, _ := ParseKey("2022-01", privatePEM(t, rsaKey))
+	newKey, _ := ParseKey("2022-06", privatePEM(t, edKey))
+
+	keys, err := NewKeySet("", oldKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	m, _ := NewManager(Options{TokenTTL: time.Hour, Keys: keys}, nil)
+	oldToken, err := m.CreateJWT(Claims{Principal: User("user"), Roles: []string{"editor"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// The retired key is kept for verification only.
+	retired, _ := ParseKey("2022-01", publicPEM(t, rsaKey.Public()))
+	if err := keys.Replace("2022-06", retired, newKey); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	newToken, _ := m.CreateJWT(Claims{Principal: User("user"), Roles: []string{"editor"}})
+	token, _, _ := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	assert.Equal(t, "2022-06", token.Header["kid"])
+	assert.Equal(t, "EdDSA", token.Header["alg"])
+
+	for _, tokenString := range []string{oldToken, newToken} {
+		claims, err := m.ParseJWT(ctx, tokenString)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		assert.Equal(t, User("user"), claims.Principal)
+	}
+
+	// Dropping the retired key invalidates the tokens it signed.
+	if err := keys.Replace("", newKey); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, err = m.ParseJWT(ctx, oldToken)
+	assert.Error(t, err)
+}
+
+func TestManager_ParseJWTRejectsAlgorithmOfOtherKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key, _ := ParseKey("k1", privatePEM(t, rsaKey))
+	keys, _ := NewKeySet("", key)
+	m, _ := NewManager(Options{TokenTTL: time.Hour, Keys: keys}, nil)
+
+	// A token signed with HS256 and the public key as the secret must not pass for an RS256 one.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": "user", "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "k1"
+	forged, _ := token.SignedString(publicPEM(t, rsaKey.Public()))
+	_, err := m.ParseJWT(context.Background(), forged)
+	assert.Error(t, err)
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"user_id": "user"})
+	unknown.Header["kid"] = "k2"
+	signed, _ := unknown.SignedString(rsaKey)
+	_, err = m.ParseJWT(context.Background(), signed)
+	assert.Error(t, err)
+}
+
+func TestNewKeySet(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a, _ := ParseKey("a", privatePEM(t, rsaKey))
+	b, _ := ParseKey("b", privatePEM(t, ecKey))
+	public, _ := ParseKey("c", publicPEM(t, ecKey.Public()))
+
+	_, err := NewKeySet("", a, b)
+	assert.Error(t, err, "several signing keys need an active one")
+	_, err = NewKeySet("c", a, public)
+	assert.Error(t, err, "a public key can't sign")
+	_, err = NewKeySet("", public)
+	assert.Error(t, err, "no key can sign")
+	_, err = NewKeySet("a", a, a)
+	assert.Error(t, err, "duplicate key id")
+	ks, err := NewKeySet("b", a, b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	assert.Equal(t, "b", ks.Active().Id)
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"rsa.pem": privatePEM(t, rsaKey),
+		"ec.pem":  publicPEM(t, ecKey.Public()),
+		"ed.pem":  privatePEM(t, edKey),
+		"notes":   []byte("not a key"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	keys, err := LoadKeyDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ks, err := NewKeySet("ed", append(keys, HMACKey("secret", []byte("secret")))...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	set := ks.JWKS()
+	if assert.Len(t, set.Keys, 3) {
+		assert.Equal(t, JWK{Kty: "EC", Kid: "ec", Use: "sig", Alg: "ES256", Crv: "P-256",
+			X: encodeBase64(ecKey.X.FillBytes(make([]byte, 32))),
+			Y: encodeBase64(ecKey.Y.FillBytes(make([]byte, 32)))}, set.Keys[0])
+		assert.Equal(t, JWK{Kty: "OKP", Kid: "ed", Use: "sig", Alg: "EdDSA", Crv: "Ed25519",
+			X: encodeBase64(edKey.Public().(ed25519.PublicKey))}, set.Keys[1])
+		assert.Equal(t, "RSA", set.Keys[2].Kty)
+		assert.Equal(t, "AQAB", set.Keys[2].E)
+		assert.Equal(t, encodeBase64(rsaKey.N.Bytes()), set.Keys[2].N)
+	}
+}
@@ -1,53 +1,293 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"os"
+	"strings"
 	"time"
 )
 
-type Manager struct {
-	signinKey []byte
-	tokenTTL  time.Duration
+// DefaultIssuer is the issuer and audience of tokens when Options set none.
+const DefaultIssuer = "xm_app"
+
+var (
+	// ErrRevoked is returned for tokens on the denylist.
+	ErrRevoked = errors.New("token is revoked")
+	// ErrDenylist is returned when the denylist can't be checked, the token may be valid.
+	ErrDenylist = errors.New("can't check the token denylist")
+)
+
+// PrincipalType tells what kind of caller a token was issued to.
+type PrincipalType string
+
+const (
+	// PrincipalUser is a registered user, the subject is the user id.
+	PrincipalUser PrincipalType = "user"
+	// PrincipalGeo is an anonymous caller let in by the access policy, the subject is
+	// its country or, when that is unknown, its address.
+	PrincipalGeo PrincipalType = "geo"
+)
+
+// Principal is whom a token is issued to.
+type Principal struct {
+	Type    PrincipalType
+	Subject string
+}
+
+// User returns the principal of the user with the id.
+func User(id string) Principal {
+	return Principal{Type: PrincipalUser, Subject: id}
 }
 
-func NewManager(tokenTTL time.Duration) (*Manager, error) {
-	var key string
-	if key = os.Getenv("SIGNINKEY"); key == "" {
-		return nil, errors.New("empty signin key passed")
+// Geo returns the principal of an anonymous caller identified by its country or address.
+func Geo(subject string) Principal {
+	return Principal{Type: PrincipalGeo, Subject: subject}
+}
+
+// UserId returns the id of a user principal, ok is false for anonymous ones.
+func (p Principal) UserId() (id string, ok bool) {
+	if p.Type != PrincipalUser {
+		return "", false
 	}
+	return p.Subject, true
+}
 
-	return &Manager{signinKey: []byte(key), tokenTTL: tokenTTL}, nil
+// Claims are the claims of an access token.
+type Claims struct {
+	Principal Principal
+	// Roles are the roles of a user principal.
+	Roles []string
+	// Scope are the operations a geo principal may run.
+	Scope []string
+	// Country or IP binds a geo principal to the country code or the address it was issued to.
+	Country string
+	IP      string
+	// TokenId is the unique id (jti) of the token, IssuedAt and ExpiresAt its validity period,
+	// all of them are set by the manager.
+	TokenId   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
-func (m *Manager) CreateJWT(userId string) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["exp"] = time.Now().Add(m.tokenTTL).Unix()
-	claims["iss_at"] = time.Now().Unix()
-	claims["user_id"] = userId
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(m.signinKey)
+// Allows reports whether the scope of the token covers the operation.
+func (c Claims) Allows(operation string) bool {
+	for _, op := range c.Scope {
+		if op == operation {
+			return true
+		}
+	}
+	return false
 }
 
-func (m *Manager) ParseJWT(tokenString string) (user_id string, err error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+func (c Claims) validate() error {
+	switch c.Principal.Type {
+	case PrincipalUser:
+		if len(c.Scope) > 0 || c.Country != "" || c.IP != "" {
+			return errors.New("user tokens can't be scoped or bound")
+		}
+	case PrincipalGeo:
+		if len(c.Scope) == 0 || len(c.Roles) > 0 {
+			return errors.New("geo tokens need a scope instead of roles")
+		}
+		if (c.Country == "") == (c.IP == "") {
+			return errors.New("geo tokens need to be bound to either a country or an address")
 		}
+	default:
+		return fmt.Errorf("unknown token principal %q", c.Principal.Type)
+	}
+	if c.Principal.Subject == "" {
+		return errors.New("token has no subject")
+	}
+	return nil
+}
+
+// tokenClaims is the JSON form of Claims, the registered claims of RFC 7519 and ours.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Principal PrincipalType `json:"principal"`
+	Roles     []string      `json:"roles,omitempty"`
+	// Scope is a space separated list, as in RFC 8693.
+	Scope   string `json:"scope,omitempty"`
+	Country string `json:"country,omitempty"`
+	IP      string `json:"ip,omitempty"`
+}
+
+// Denylist tells whether a token was revoked before its expiry.
+type Denylist interface {
+	IsTokenRevoked(ctx context.Context, jti string) (revoked bool, err error)
+}
+
+// Options configure the tokens of a manager.
+type Options struct {
+	TokenTTL time.Duration
+	// GeoTokenTTL is the lifetime of tokens of geo principals, TokenTTL when zero.
+	GeoTokenTTL time.Duration
+	// Issuer is written to the iss claim and required from verified tokens, DefaultIssuer when empty.
+	Issuer string
+	// Audience is written to the aud claim and required from verified tokens, DefaultIssuer when empty.
+	Audience string
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+	// Keys sign and verify the tokens. Without keys tokens are signed with HS256 and the SIGNINKEY secret.
+	Keys *KeySet
+}
 
-		return m.signinKey, nil
-	})
+type Manager struct {
+	opts     Options
+	keys     *KeySet
+	denylist Denylist
+}
 
+// NewManager returns a manager issuing tokens with the options. A nil denylist disables revocation checks.
+func NewManager(opts Options, denylist Denylist) (*Manager, error) {
+	keys := opts.Keys
+	if keys == nil {
+		var key string
+		if key = os.Getenv("SIGNINKEY"); key == "" {
+			return nil, errors.New("empty signin key passed")
+		}
+		var err error
+		if keys, err = NewKeySet("", HMACKey("", []byte(key))); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Issuer == "" {
+		opts.Issuer = DefaultIssuer
+	}
+	if opts.Audience == "" {
+		opts.Audience = DefaultIssuer
+	}
+	if opts.GeoTokenTTL == 0 {
+		opts.GeoTokenTTL = opts.TokenTTL
+	}
+
+	return &Manager{opts: opts, keys: keys, denylist: denylist}, nil
+}
+
+// CreateJWT issues a token with the claims, the token id and validity period are set here.
+func (m *Manager) CreateJWT(c Claims) (string, error) {
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	jti, err := newTokenId()
 	if err != nil {
 		return "", err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid || claims["user_id"] == nil {
-		return "", fmt.Errorf("error get user claims from token")
+	ttl := m.opts.TokenTTL
+	if c.Principal.Type == PrincipalGeo {
+		ttl = m.opts.GeoTokenTTL
+	}
+	now := time.Now()
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.opts.Issuer,
+			Subject:   c.Principal.Subject,
+			Audience:  jwt.ClaimStrings{m.opts.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+		Principal: c.Principal.Type,
+		Roles:     c.Roles,
+		Scope:     strings.Join(c.Scope, " "),
+		Country:   c.Country,
+		IP:        c.IP,
+	}
+	key := m.keys.Active()
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.Id != "" {
+		token.Header["kid"] = key.Id
+	}
+	return token.SignedString(key.sign)
+}
+
+// ParseJWT verifies the token and returns its claims. Besides the signature it checks
+// the issuer, the audience and the validity period. It returns ErrRevoked for tokens on the denylist
+// and ErrDenylist when the denylist can't be checked.
+func (m *Manager) ParseJWT(ctx context.Context, tokenString string) (c Claims, err error) {
+	claims := &tokenClaims{}
+	// The claims are validated below, with the leeway the parser doesn't support.
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	if _, err = parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys.Key(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// The algorithm is the one of the key, a token can't choose another to verify with.
+		if token.Method.Alg() != key.Algorithm() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.verify, nil
+	}); err != nil {
+		return c, err
+	}
+	if err = m.validate(claims, time.Now()); err != nil {
+		return c, err
+	}
+
+	c = Claims{
+		Principal: Principal{Type: claims.Principal, Subject: claims.Subject},
+		Roles:     claims.Roles,
+		Scope:     strings.Fields(claims.Scope),
+		Country:   claims.Country,
+		IP:        claims.IP,
+		TokenId:   claims.ID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if err = c.validate(); err != nil {
+		return Claims{}, err
+	}
+
+	if m.denylist != nil {
+		revoked, err := m.denylist.IsTokenRevoked(ctx, c.TokenId)
+		if err != nil {
+			return Claims{}, fmt.Errorf("%w: %v", ErrDenylist, err)
+		}
+		if revoked {
+			return Claims{}, ErrRevoked
+		}
+	}
+
+	return c, nil
+}
+
+func (m *Manager) validate(claims *tokenClaims, now time.Time) error {
+	switch {
+	case !claims.VerifyIssuer(m.opts.Issuer, true):
+		return fmt.Errorf("unexpected token issuer %q", claims.Issuer)
+	case !claims.VerifyAudience(m.opts.Audience, true):
+		return fmt.Errorf("token is not meant for %q", m.opts.Audience)
+	case !claims.VerifyExpiresAt(now.Add(-m.opts.Leeway), true):
+		return errors.New("token is expired")
+	case !claims.VerifyNotBefore(now.Add(m.opts.Leeway), false):
+		return errors.New("token is not valid yet")
+	case !claims.VerifyIssuedAt(now.Add(m.opts.Leeway), true):
+		return errors.New("token is issued in the future")
+	case claims.ID == "":
+		return errors.New("token has no id")
 	}
+	return nil
+}
 
-	return claims["user_id"].(string), nil
+// JWKS returns the public keys tokens are verified with.
+func (m *Manager) JWKS() JWKS {
+	return m.keys.JWKS()
+}
+
+func newTokenId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
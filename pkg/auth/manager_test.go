@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManager_CreateJWT(t *testing.T) {
+	keys, _ := NewKeySet("", HMACKey("k1", []byte("secret")))
+	m, _ := NewManager(Options{TokenTTL: time.Hour, Issuer: "xm", Audience: "companies", Keys: keys}, nil)
+
+	tokenString, err := m.CreateJWT(Claims{Principal: Geo("Cyprus"), Scope: []string{"company.create"}, Country: "CY"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	token, _, _ := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "xm", claims["iss"])
+	assert.Equal(t, "Cyprus", claims["sub"])
+	assert.Equal(t, []interface{}{"companies"}, claims["aud"])
+	assert.Equal(t, "geo", claims["principal"])
+	for _, name := range []string{"iat", "nbf", "exp", "jti"} {
+		assert.Contains(t, claims, name)
+	}
+	assert.NotContains(t, claims, "user_id")
+
+	parsed, err := m.ParseJWT(context.Background(), tokenString)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	assert.Equal(t, Geo("Cyprus"), parsed.Principal)
+	_, ok := parsed.Principal.UserId()
+	assert.False(t, ok)
+
+	_, err = m.CreateJWT(Claims{Principal: Principal{Subject: "user"}})
+	assert.Error(t, err)
+}
+
+func TestManager_CreateGeoJWT(t *testing.T) {
+	keys, _ := NewKeySet("", HMACKey("k1", []byte("secret")))
+	m, _ := NewManager(Options{TokenTTL: time.Hour, GeoTokenTTL: 5 * time.Minute, Keys: keys}, nil)
+
+	tokenString, err := m.CreateJWT(Claims{Principal: Geo("31.153.0.1"), Scope: []string{"company.create", "company.delete"},
+		IP: "31.153.0.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	claims, err := m.ParseJWT(context.Background(), tokenString)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	assert.True(t, claims.Allows("company.delete"))
+	assert.False(t, claims.Allows("company.restore"))
+	assert.Equal(t, "31.153.0.1", claims.IP)
+	assert.Equal(t, 5*time.Minute, claims.ExpiresAt.Sub(claims.IssuedAt))
+
+	invalid := []Claims{
+		{Principal: Geo("Cyprus"), Country: "CY"},
+		{Principal: Geo("Cyprus"), Scope: []string{"company.create"}},
+		{Principal: Geo("Cyprus"), Scope: []string{"company.create"}, Country: "CY", IP: "31.153.0.1"},
+		{Principal: Geo("Cyprus"), Scope: []string{"company.create"}, Country: "CY", Roles: []string{"editor"}},
+		{Principal: User("user"), Scope: []string{"company.create"}},
+	}
+	for _, c := range invalid {
+		_, err := m.CreateJWT(c)
+		assert.Error(t, err, "%+v", c)
+	}
+}
+
+func TestManager_ParseJWTValidatesClaims(t *testing.T) {
+	now := time.Now()
+	valid := func() tokenClaims {
+		return tokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "xm",
+				Subject:   "c9f44c4a-788a-4d5f-a210-94ccafcc2231",
+				Audience:  jwt.ClaimStrings{"companies"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				NotBefore: jwt.NewNumericDate(now),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ID:        "jti",
+			},
+			Principal: PrincipalUser,
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		change  func(c *tokenClaims)
+		wantErr bool
+	}{
+		{name: "[Ok] Valid", change: func(c *tokenClaims) {}},
+		{name: "[Ok] Expired within leeway", change: func(c *tokenClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second))
+		}},
+		{name: "[Ok] Issued within leeway", change: func(c *tokenClaims) {
+			c.IssuedAt = jwt.NewNumericDate(now.Add(10 * time.Second))
+			c.NotBefore = c.IssuedAt
+		}},
+		{name: "[Err] Expired", change: func(c *tokenClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+		}, wantErr: true},
+		{name: "[Err] Not valid yet", change: func(c *tokenClaims) {
+			c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute))
+		}, wantErr: true},
+		{name: "[Err] Issued in the future", change: func(c *tokenClaims) {
+			c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute))
+		}, wantErr: true},
+		{name: "[Err] Without expiry", change: func(c *tokenClaims) { c.ExpiresAt = nil }, wantErr: true},
+		{name: "[Err] Other issuer", change: func(c *tokenClaims) { c.Issuer = "other" }, wantErr: true},
+		{name: "[Err] Without issuer", change: func(c *tokenClaims) { c.Issuer = "" }, wantErr: true},
+		{name: "[Err] Other audience", change: func(c *tokenClaims) {
+			c.Audience = jwt.ClaimStrings{"other"}
+		}, wantErr: true},
+		{name: "[Err] Without subject", change: func(c *tokenClaims) { c.Subject = "" }, wantErr: true},
+		{name: "[Err] Without id", change: func(c *tokenClaims) { c.ID = "" }, wantErr: true},
+		{name: "[Err] Unknown principal", change: func(c *tokenClaims) { c.Principal = "" }, wantErr: true},
+	}
+
+	keys, _ := NewKeySet("", HMACKey("k1", []byte("secret")))
+	m, _ := NewManager(Options{TokenTTL: time.Hour, Issuer: "xm", Audience: "companies",
+		Leeway: 30 * time.Second, Keys: keys}, nil)
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			claims := valid()
+			tcase.change(&claims)
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = "k1"
+			signed, _ := token.SignedString([]byte("secret"))
+
+			c, err := m.ParseJWT(context.Background(), signed)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			id, ok := c.Principal.UserId()
+			assert.True(t, ok)
+			assert.Equal(t, "c9f44c4a-788a-4d5f-a210-94ccafcc2231", id)
+		})
+	}
+}
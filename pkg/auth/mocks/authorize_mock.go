@@ -5,8 +5,10 @@
 package mock_auth
 
 import (
+	context "context"
 	reflect "reflect"
 
+	auth "github.com/dkischenko/xm_app/pkg/auth"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,31 +36,45 @@ func (m *MockAuthorize) EXPECT() *MockAuthorizeMockRecorder {
 }
 
 // CreateJWT mocks base method.
-func (m *MockAuthorize) CreateJWT(userId string) (string, error) {
+func (m *MockAuthorize) CreateJWT(claims auth.Claims) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateJWT", userId)
+	ret := m.ctrl.Call(m, "CreateJWT", claims)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateJWT indicates an expected call of CreateJWT.
-func (mr *MockAuthorizeMockRecorder) CreateJWT(userId interface{}) *gomock.Call {
+func (mr *MockAuthorizeMockRecorder) CreateJWT(claims interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJWT", reflect.TypeOf((*MockAuthorize)(nil).CreateJWT), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJWT", reflect.TypeOf((*MockAuthorize)(nil).CreateJWT), claims)
+}
+
+// JWKS mocks base method.
+func (m *MockAuthorize) JWKS() auth.JWKS {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(auth.JWKS)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockAuthorizeMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockAuthorize)(nil).JWKS))
 }
 
 // ParseJWT mocks base method.
-func (m *MockAuthorize) ParseJWT(token string) (string, error) {
+func (m *MockAuthorize) ParseJWT(ctx context.Context, token string) (auth.Claims, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ParseJWT", token)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "ParseJWT", ctx, token)
+	ret0, _ := ret[0].(auth.Claims)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ParseJWT indicates an expected call of ParseJWT.
-func (mr *MockAuthorizeMockRecorder) ParseJWT(token interface{}) *gomock.Call {
+func (mr *MockAuthorizeMockRecorder) ParseJWT(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseJWT", reflect.TypeOf((*MockAuthorize)(nil).ParseJWT), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseJWT", reflect.TypeOf((*MockAuthorize)(nil).ParseJWT), ctx, token)
 }
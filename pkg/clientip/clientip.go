@@ -0,0 +1,146 @@
+// Package clientip resolves the address of the client behind trusted reverse proxies.
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerForwarded     = "Forwarded"
+	headerXForwardedFor = "X-Forwarded-For"
+	headerXRealIP       = "X-Real-IP"
+)
+
+type ipKey struct{}
+
+// WithIP returns a copy of ctx carrying the client address.
+func WithIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, ipKey{}, ip)
+}
+
+// FromContext returns the client address stored in ctx, nil if there is none.
+func FromContext(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(ipKey{}).(net.IP)
+	return ip
+}
+
+// Resolver takes the client address from forwarding headers, but only as far as
+// they were written by trusted proxies.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver returns a resolver trusting the given networks, either CIDRs or single addresses.
+// Without trusted networks forwarding headers are ignored.
+func NewResolver(trusted []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, t := range trusted {
+		if !strings.Contains(t, "/") {
+			ip := net.ParseIP(t)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: t}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(t)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+// Middleware stores the resolved client address in the request context.
+func (r *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(WithIP(req.Context(), r.Resolve(req))))
+	})
+}
+
+// Resolve returns the client address of the request. Starting from the peer address
+// it walks the forwarding chain right to left and returns the first hop that is not
+// a trusted proxy. Forwarded takes precedence over X-Forwarded-For, X-Real-IP is
+// used when neither is present. It returns nil if the peer address can't be parsed.
+func (r *Resolver) Resolve(req *http.Request) net.IP {
+	ip := parseHost(req.RemoteAddr)
+	if ip == nil || !r.isTrusted(ip) {
+		return ip
+	}
+
+	var hops []string
+	switch {
+	case len(req.Header.Values(headerForwarded)) > 0:
+		hops = forwardedFor(req.Header.Values(headerForwarded))
+	case len(req.Header.Values(headerXForwardedFor)) > 0:
+		hops = splitList(req.Header.Values(headerXForwardedFor))
+	case req.Header.Get(headerXRealIP) != "":
+		hops = []string{strings.TrimSpace(req.Header.Get(headerXRealIP))}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHost(hops[i])
+		if hop == nil {
+			// An obfuscated or malformed hop can't be followed, the proxy that wrote it is the client.
+			return ip
+		}
+		ip = hop
+		if !r.isTrusted(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func (r *Resolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for parameters of the Forwarded header elements, see RFC 7239.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		hop := ""
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				hop = strings.Trim(value, `"`)
+			}
+		}
+		// An element without for still stands for a hop, an unknown one.
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// splitList splits comma separated header values, which may repeat, into their elements.
+func splitList(values []string) []string {
+	var elements []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			elements = append(elements, strings.TrimSpace(e))
+		}
+	}
+	return elements
+}
+
+// parseHost parses an address with an optional port, IPv6 ones may be in brackets.
+func parseHost(s string) net.IP {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+}
@@ -0,0 +1,89 @@
+package clientip
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		ip         string
+	}{
+		{name: "No proxy", remoteAddr: "31.153.0.1:5000", ip: "31.153.0.1"},
+		{name: "Untrusted peer headers are ignored", remoteAddr: "31.153.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"8.8.8.8"}}, ip: "31.153.0.1"},
+		{name: "Trusted peer without headers", remoteAddr: "10.0.0.2:5000", ip: "10.0.0.2"},
+		{name: "X-Forwarded-For", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"31.153.0.1"}}, ip: "31.153.0.1"},
+		{name: "X-Forwarded-For spoofed by client", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"8.8.8.8, 31.153.0.1"}}, ip: "31.153.0.1"},
+		{name: "X-Forwarded-For through trusted hops", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"8.8.8.8, 31.153.0.1", "192.168.1.1, 10.0.0.3"}},
+			ip:      "31.153.0.1"},
+		{name: "X-Forwarded-For of trusted hops only", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"192.168.1.1, 10.0.0.3"}}, ip: "192.168.1.1"},
+		{name: "X-Forwarded-For with malformed hop", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"31.153.0.1, garbage, 10.0.0.3"}}, ip: "10.0.0.3"},
+		{name: "X-Real-IP", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"X-Real-Ip": {"31.153.0.1"}}, ip: "31.153.0.1"},
+		{name: "Forwarded wins over X-Forwarded-For", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{
+				"Forwarded":       {`for=31.153.0.1;proto=https, for="10.0.0.3:8080"`},
+				"X-Forwarded-For": {"8.8.8.8"},
+			}, ip: "31.153.0.1"},
+		{name: "Forwarded IPv6", remoteAddr: "[fd00::1]:5000",
+			headers: map[string][]string{"Forwarded": {`For="[2001:db8:cafe::17]:4711"`}}, ip: "2001:db8:cafe::17"},
+		{name: "Forwarded obfuscated", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"Forwarded": {"for=_hidden, for=10.0.0.3"}}, ip: "10.0.0.3"},
+		{name: "Forwarded element without for", remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{"Forwarded": {"for=31.153.0.1, proto=https"}}, ip: "10.0.0.2"},
+		{name: "Malformed peer", remoteAddr: "pipe", ip: "<nil>"},
+	}
+
+	r, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tcase.remoteAddr
+			for name, values := range tcase.headers {
+				for _, v := range values {
+					req.Header.Add(name, v)
+				}
+			}
+			assert.Equal(t, tcase.ip, r.Resolve(req).String())
+		})
+	}
+}
+
+func TestResolver_Middleware(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var ip net.IP
+	h := r.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ip = FromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.2:5000"
+	req.Header.Set("X-Forwarded-For", "31.153.0.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "31.153.0.1", ip.String())
+}
+
+func TestNewResolver_Errors(t *testing.T) {
+	for _, trusted := range []string{"10.0.0.0/33", "proxy"} {
+		_, err := NewResolver([]string{trusted})
+		assert.Error(t, err)
+	}
+}
@@ -3,14 +3,25 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 const protocol = "postgres://"
 
-func NewClient(ctx context.Context, host, port, username, password, database string) (dbpool *pgxpool.Pool, err error) {
+// NewClient connects to the database. The logger, if not nil, is told about every query.
+func NewClient(ctx context.Context, host, port, username, password, database string,
+	logger pgx.Logger) (dbpool *pgxpool.Pool, err error) {
 	dsn := fmt.Sprintf("%s%s:%s@%s:%s/%s", protocol, username, password, host, port, database)
-	dbpool, err = pgxpool.Connect(ctx, dsn)
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres: %v", err)
+	}
+	if logger != nil {
+		config.ConnConfig.Logger = logger
+		config.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+	dbpool, err = pgxpool.ConnectConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %v", err)
 	}
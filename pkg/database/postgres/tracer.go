@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+const tracerName = "github.com/dkischenko/xm_app/pkg/database/postgres"
+
+// Tracer is a pgx logger turning the queries of traced requests into spans. pgx tells
+// about a query once it is done, so its span is backdated by the reported duration.
+// Statements are recorded without their arguments.
+type Tracer struct {
+	tracer trace.Tracer
+	now    func() time.Time
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{tracer: otel.Tracer(tracerName), now: time.Now}
+}
+
+func (t *Tracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if msg != "Query" && msg != "Exec" && msg != "CopyFrom" {
+		return
+	}
+	// Queries outside requests, such as migrations, would each start a trace of their own.
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	end := t.now()
+	elapsed, _ := data["time"].(time.Duration)
+	sql, _ := data["sql"].(string)
+	operation := "COPY"
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	_, span := t.tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(end.Add(-elapsed)), trace.WithAttributes(semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(operation), semconv.DBStatementKey.String(strings.TrimSpace(sql))))
+	if err, ok := data["err"].(error); ok {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
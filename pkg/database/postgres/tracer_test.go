@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+	"time"
+)
+
+func TestTracer_Log(t *testing.T) {
+	now := time.Unix(1650995663, 0)
+	sql := `
+		SELECT id FROM xm_db.companies WHERE id = $1
+	`
+	testCases := []struct {
+		name   string
+		traced bool
+		msg    string
+		data   map[string]interface{}
+		spans  int
+		status codes.Code
+	}{
+		{name: "[Ok] Query", traced: true, msg: "Query",
+			data:  map[string]interface{}{"sql": sql, "args": []interface{}{7}, "time": 5 * time.Millisecond},
+			spans: 1, status: codes.Unset},
+		{name: "[Ok] Failed exec", traced: true, msg: "Exec",
+			data:  map[string]interface{}{"sql": sql, "err": errors.New("deadlock detected"), "time": 5 * time.Millisecond},
+			spans: 1, status: codes.Error},
+		{name: "[Ok] Query outside a trace", msg: "Query",
+			data: map[string]interface{}{"sql": sql, "time": 5 * time.Millisecond}},
+		{name: "[Ok] Other message", traced: true, msg: "Dialing PostgreSQL server",
+			data: map[string]interface{}{"host": "localhost"}},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracer := &Tracer{tracer: provider.Tracer(tracerName), now: func() time.Time { return now }}
+			ctx := context.Background()
+			if tcase.traced {
+				ctx, _ = provider.Tracer("test").Start(ctx, "request")
+			}
+
+			tracer.Log(ctx, pgx.LogLevelInfo, tcase.msg, tcase.data)
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, tcase.spans)
+			if tcase.spans == 0 {
+				return
+			}
+			span := spans[0]
+			assert.Equal(t, "SELECT", span.Name())
+			assert.Equal(t, now.Add(-5*time.Millisecond), span.StartTime())
+			assert.Equal(t, now, span.EndTime())
+			assert.True(t, span.Parent().IsValid())
+			assert.Contains(t, span.Attributes(),
+				attribute.String("db.statement", "SELECT id FROM xm_db.companies WHERE id = $1"))
+			assert.Equal(t, tcase.status, span.Status().Code)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Breaker stops calling a provider after threshold consecutive ErrUnavailable
+// failures and rejects lookups with ErrUnavailable for the cooldown. Then it lets
+// a single probe through: its success closes the breaker, a failure reopens it.
+// Lookups ended by the caller's context count neither as failures nor as successes.
+type Breaker struct {
+	next      GeoLocator
+	threshold int
+	cooldown  time.Duration
+	metrics   *Metrics
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewBreaker(next GeoLocator, threshold int, cooldown time.Duration, metrics *Metrics) *Breaker {
+	return &Breaker{
+		next:      next,
+		threshold: threshold,
+		cooldown:  cooldown,
+		metrics:   metrics,
+		now:       time.Now,
+	}
+}
+
+func (b *Breaker) Locate(ctx context.Context, ip net.IP) (Location, error) {
+	if !b.allow() {
+		b.metrics.inc(&b.metrics.rejected)
+		return Location{}, ErrUnavailable
+	}
+
+	loc, err := b.next.Locate(ctx, ip)
+	if ctx.Err() != nil {
+		b.release()
+		return Location{}, ctx.Err()
+	}
+	b.record(errors.Is(err, ErrUnavailable))
+	if errors.Is(err, ErrUnavailable) {
+		b.metrics.inc(&b.metrics.failures)
+	}
+	return loc, err
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	b.metrics.setBreaker(BreakerHalfOpen)
+	return true
+}
+
+// release lets another probe through after one was abandoned.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		b.metrics.setBreaker(BreakerClosed)
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+		b.metrics.setBreaker(BreakerOpen)
+	}
+}
@@ -0,0 +1,121 @@
+package geo
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"golang.org/x/sync/singleflight"
+	"net"
+	"sync"
+	"time"
+)
+
+// Cache keeps the locations of up to size recently looked up addresses for ttl.
+// Unknown addresses are cached as well, failures are not. Concurrent lookups of
+// the same address share a single call of the next locator. The shared call doesn't
+// end with the context of the caller that started it but after timeout, while every
+// caller stops waiting for it when its own context ends.
+type Cache struct {
+	next    GeoLocator
+	size    int
+	ttl     time.Duration
+	timeout time.Duration
+	metrics *Metrics
+	now     func() time.Time
+	group   singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds *cacheEntry, the most recently used first.
+	lru *list.List
+}
+
+type cacheEntry struct {
+	key       string
+	loc       Location
+	err       error
+	expiresAt time.Time
+}
+
+func NewCache(next GeoLocator, size int, ttl time.Duration, timeout time.Duration, metrics *Metrics) *Cache {
+	return &Cache{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		timeout: timeout,
+		metrics: metrics,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+func (c *Cache) Locate(ctx context.Context, ip net.IP) (Location, error) {
+	c.metrics.inc(&c.metrics.lookups)
+	key := ip.String()
+	if e, ok := c.get(key); ok {
+		c.metrics.inc(&c.metrics.cacheHits)
+		return e.loc, e.err
+	}
+
+	result := c.group.DoChan(key, func() (interface{}, error) {
+		lookupCtx, cancel := context.WithTimeout(detached{ctx}, c.timeout)
+		defer cancel()
+		loc, err := c.next.Locate(lookupCtx, ip)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			c.put(key, loc, err)
+		}
+		return loc, err
+	})
+	select {
+	case r := <-result:
+		return r.Val.(Location), r.Err
+	case <-ctx.Done():
+		return Location{}, ctx.Err()
+	}
+}
+
+// detached keeps the values of a context, such as its span, but not its deadline or cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+func (c *Cache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.now().After(e.expiresAt) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+func (c *Cache) put(key string, loc Location, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &cacheEntry{key: key, loc: loc, err: err, expiresAt: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
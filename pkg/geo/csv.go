@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CSV locates addresses with a list of networks loaded into memory.
+// Every record is "network,country_code[,country_name]", for example
+// "31.153.0.0/16,CY,Cyprus". An optional header row starting with
+// "network" and lines starting with "#" are skipped.
+// The most specific network containing the address wins.
+type CSV struct {
+	// networks maps a prefix length to the locations of networks of that length,
+	// keyed by the network address.
+	networks map[int]map[string]Location
+	// prefixes are the prefix lengths present, the longest first.
+	prefixes []int
+}
+
+func OpenCSV(path string) (*CSV, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("geo: open csv: %w", err)
+	}
+	defer f.Close()
+
+	return ReadCSV(f)
+}
+
+func ReadCSV(r io.Reader) (*CSV, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	c := &CSV{networks: map[int]map[string]Location{}}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geo: read csv: %w", err)
+		}
+		if first && strings.EqualFold(record[0], "network") {
+			continue
+		}
+		if len(record) < 2 {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("geo: csv line %d: expected network and country code", line)
+		}
+
+		_, network, err := net.ParseCIDR(record[0])
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("geo: csv line %d: %w", line, err)
+		}
+		loc := Location{CountryCode: strings.ToUpper(record[1])}
+		if len(record) > 2 {
+			loc.CountryName = record[2]
+		}
+		c.add(network, loc)
+	}
+
+	return c, nil
+}
+
+func (c *CSV) add(network *net.IPNet, loc Location) {
+	ones, bits := network.Mask.Size()
+	// IPv4 networks are stored in the 16-byte form, like the looked up addresses.
+	prefix := ones + 128 - bits
+	if _, ok := c.networks[prefix]; !ok {
+		c.networks[prefix] = map[string]Location{}
+		c.prefixes = append(c.prefixes, prefix)
+		sort.Sort(sort.Reverse(sort.IntSlice(c.prefixes)))
+	}
+	c.networks[prefix][string(network.IP.To16())] = loc
+}
+
+func (c *CSV) Locate(_ context.Context, ip net.IP) (Location, error) {
+	if !isPublic(ip) {
+		return Location{}, ErrNotFound
+	}
+
+	ip = ip.To16()
+	for _, prefix := range c.prefixes {
+		key := ip.Mask(net.CIDRMask(prefix, 128))
+		if loc, ok := c.networks[prefix][string(key)]; ok {
+			return loc, nil
+		}
+	}
+	return Location{}, ErrNotFound
+}
@@ -0,0 +1,134 @@
+// Package geo resolves IP addresses to countries.
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	ProviderIPAPI = "ipapi"
+	ProviderMMDB  = "mmdb"
+	ProviderCSV   = "csv"
+)
+
+var (
+	// ErrNotFound is returned for addresses without a known location,
+	// including private, loopback and other non-public ones.
+	ErrNotFound = errors.New("geo: location not found")
+	// ErrUnavailable is returned when the provider can't answer right now,
+	// so the same lookup may succeed later.
+	ErrUnavailable = errors.New("geo: provider unavailable")
+)
+
+// Location is the country an IP address belongs to.
+type Location struct {
+	// CountryCode is the ISO 3166-1 alpha-2 code.
+	CountryCode string
+	CountryName string
+}
+
+//go:generate mockgen -source=geo.go -destination=mocks/geo_mock.go
+type GeoLocator interface {
+	Locate(ctx context.Context, ip net.IP) (Location, error)
+}
+
+// Options configure the locator returned by New. Zero values take the defaults.
+type Options struct {
+	Provider string
+	// Path is the database file of the mmdb and csv providers.
+	Path string
+	// Timeout bounds a single request to a remote provider.
+	Timeout time.Duration
+	// Retries is the number of times a failed request is repeated, a negative value disables retries.
+	Retries int
+	// CacheSize is the number of addresses whose locations are kept in memory.
+	CacheSize int
+	CacheTTL  time.Duration
+	// BreakerThreshold is the number of consecutive failures opening the circuit breaker.
+	BreakerThreshold int
+	// BreakerCooldown is how long the open breaker rejects lookups before probing the provider again.
+	BreakerCooldown time.Duration
+	Metrics         *Metrics
+	// Observe, if set, is told about every lookup.
+	Observe Observer
+}
+
+const (
+	defaultTimeout          = 2 * time.Second
+	defaultRetries          = 2
+	defaultCacheSize        = 10000
+	defaultCacheTTL         = time.Hour
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// New returns the locator of the provider. The mmdb and csv providers read a local
+// database. The remote ipapi provider is wrapped in a cache, a circuit breaker and
+// retries, so it fails fast with ErrUnavailable while the provider is down.
+func New(opts Options) (GeoLocator, error) {
+	locator, err := open(opts)
+	if err != nil || opts.Observe == nil {
+		return locator, err
+	}
+	return NewObserved(locator, opts.Observe), nil
+}
+
+func open(opts Options) (GeoLocator, error) {
+	if opts.Metrics == nil {
+		opts.Metrics = &Metrics{}
+	}
+
+	switch opts.Provider {
+	case ProviderIPAPI:
+		opts.setDefaults()
+		// Requests to the third-party service are traced, but the trace context isn't sent along.
+		transport := otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()))
+		var locator GeoLocator = NewIPAPI(DefaultIPAPIURL, &http.Client{Timeout: opts.Timeout, Transport: transport})
+		retry := NewRetry(locator, opts.Retries, opts.Timeout, opts.Metrics)
+		locator = NewBreaker(retry, opts.BreakerThreshold, opts.BreakerCooldown, opts.Metrics)
+		return NewCache(locator, opts.CacheSize, opts.CacheTTL, retry.budget(), opts.Metrics), nil
+	case ProviderMMDB:
+		return OpenMMDB(opts.Path)
+	case ProviderCSV:
+		return OpenCSV(opts.Path)
+	default:
+		return nil, fmt.Errorf("geo: unknown provider %q", opts.Provider)
+	}
+}
+
+func (o *Options) setDefaults() {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.Retries == 0 {
+		o.Retries = defaultRetries
+	} else if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.CacheSize <= 0 {
+		o.CacheSize = defaultCacheSize
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = defaultCacheTTL
+	}
+	if o.BreakerThreshold <= 0 {
+		o.BreakerThreshold = defaultBreakerThreshold
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = defaultBreakerCooldown
+	}
+}
+
+// isPublic reports whether the address can have a geographical location at all.
+func isPublic(ip net.IP) bool {
+	return ip != nil && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsMulticast()
+}
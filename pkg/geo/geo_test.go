@@ -0,0 +1,92 @@
+package geo_test
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/pkg/geo"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSV_Locate(t *testing.T) {
+	data := `network,country_code,country_name
+# Cyprus
+31.153.0.0/16,CY,Cyprus
+31.153.128.0/17,gr,Greece
+2a02:587::/32,GR,Greece
+`
+	locator, err := geo.ReadCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		name string
+		ip   string
+		want geo.Location
+		err  error
+	}{
+		{name: "IPv4 network", ip: "31.153.0.1", want: geo.Location{CountryCode: "CY", CountryName: "Cyprus"}},
+		{name: "Most specific network", ip: "31.153.200.1", want: geo.Location{CountryCode: "GR", CountryName: "Greece"}},
+		{name: "IPv6 network", ip: "2a02:587:1::1", want: geo.Location{CountryCode: "GR", CountryName: "Greece"}},
+		{name: "Unknown address", ip: "8.8.8.8", err: geo.ErrNotFound},
+		{name: "Private address", ip: "10.0.0.1", err: geo.ErrNotFound},
+		{name: "Loopback address", ip: "::1", err: geo.ErrNotFound},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			loc, err := locator.Locate(context.Background(), net.ParseIP(tcase.ip))
+			assert.ErrorIs(t, err, tcase.err)
+			assert.Equal(t, tcase.want, loc)
+		})
+	}
+}
+
+func TestReadCSVErr(t *testing.T) {
+	_, err := geo.ReadCSV(strings.NewReader("31.153.0.0/16,CY\nnot-a-network,CY\n"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 2")
+	}
+}
+
+func TestIPAPI_Locate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/31.153.0.2/json/":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/31.153.0.1/json/":
+			_, _ = w.Write([]byte(`{"ip": "31.153.0.1", "country_code": "CY", "country_name": "Cyprus"}`))
+		default:
+			_, _ = w.Write([]byte(`{"error": true, "reason": "Invalid IP Address"}`))
+		}
+	}))
+	defer server.Close()
+
+	locator := geo.NewIPAPI(server.URL+"/", server.Client())
+	loc, err := locator.Locate(context.Background(), net.ParseIP("31.153.0.1"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	assert.Equal(t, geo.Location{CountryCode: "CY", CountryName: "Cyprus"}, loc)
+
+	_, err = locator.Locate(context.Background(), net.ParseIP("8.8.8.8"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Invalid IP Address")
+	}
+
+	_, err = locator.Locate(context.Background(), net.ParseIP("31.153.0.2"))
+	assert.ErrorIs(t, err, geo.ErrUnavailable)
+
+	_, err = locator.Locate(context.Background(), net.ParseIP("127.0.0.1"))
+	assert.ErrorIs(t, err, geo.ErrNotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = locator.Locate(ctx, net.ParseIP("31.153.0.1"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, geo.ErrUnavailable)
+}
@@ -0,0 +1,80 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+const (
+	DefaultIPAPIURL = "https://ipapi.co/"
+	HeaderKey       = "User-Agent"
+	HeaderValue     = "ipapi.co/#go-v1.18"
+)
+
+// IPAPI locates addresses with the ipapi.co web service.
+type IPAPI struct {
+	baseURL string
+	client  *http.Client
+}
+
+type ipapiData struct {
+	CountryCode string `json:"country_code"`
+	CountryName string `json:"country_name"`
+	Error       bool   `json:"error"`
+	Reason      string `json:"reason"`
+}
+
+func NewIPAPI(baseURL string, client *http.Client) *IPAPI {
+	return &IPAPI{
+		baseURL: baseURL,
+		client:  client,
+	}
+}
+
+func (a *IPAPI) Locate(ctx context.Context, ip net.IP) (Location, error) {
+	if !isPublic(ip) {
+		return Location{}, ErrNotFound
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+ip.String()+"/json/", nil)
+	if err != nil {
+		return Location{}, err
+	}
+	req.Header.Set(HeaderKey, HeaderValue)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		// The caller giving up says nothing about the provider.
+		if ctx.Err() != nil {
+			return Location{}, ctx.Err()
+		}
+		return Location{}, fmt.Errorf("%w: ipapi request: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return Location{}, fmt.Errorf("%w: ipapi responded with status %d", ErrUnavailable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Location{}, fmt.Errorf("geo: ipapi responded with status %d", resp.StatusCode)
+	}
+
+	data := ipapiData{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		if ctx.Err() != nil {
+			return Location{}, ctx.Err()
+		}
+		return Location{}, fmt.Errorf("%w: ipapi response: %v", ErrUnavailable, err)
+	}
+	if data.Error {
+		return Location{}, fmt.Errorf("geo: ipapi: %s", data.Reason)
+	}
+	if data.CountryCode == "" {
+		return Location{}, ErrNotFound
+	}
+
+	return Location{CountryCode: data.CountryCode, CountryName: data.CountryName}, nil
+}
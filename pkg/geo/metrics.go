@@ -0,0 +1,70 @@
+package geo
+
+import "sync/atomic"
+
+// States of the circuit breaker reported by Metrics.Breaker.
+const (
+	BreakerClosed = "closed"
+	// BreakerOpen rejects lookups until the cooldown is over and a probe succeeds.
+	BreakerOpen = "open"
+	// BreakerHalfOpen lets a single probe through.
+	BreakerHalfOpen = "half_open"
+)
+
+// Metrics counts lookups of the resilient locator. It is safe for concurrent use.
+type Metrics struct {
+	lookups   int64
+	cacheHits int64
+	requests  int64
+	retries   int64
+	failures  int64
+	rejected  int64
+	breaker   atomic.Value
+}
+
+// MetricsSnapshot is a point-in-time copy of Metrics.
+type MetricsSnapshot struct {
+	// Lookups is the number of addresses asked for.
+	Lookups int64 `json:"lookups"`
+	// CacheHits is the number of lookups answered from the cache.
+	CacheHits    int64   `json:"cache_hits"`
+	CacheHitRate float64 `json:"cache_hit_rate"`
+	// Requests is the number of requests sent to the provider, retries included.
+	Requests int64 `json:"requests"`
+	Retries  int64 `json:"retries"`
+	// Failures is the number of lookups that failed with the provider unavailable.
+	Failures int64 `json:"failures"`
+	// Rejected is the number of lookups rejected by the open circuit breaker.
+	Rejected int64 `json:"rejected"`
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Lookups:   atomic.LoadInt64(&m.lookups),
+		CacheHits: atomic.LoadInt64(&m.cacheHits),
+		Requests:  atomic.LoadInt64(&m.requests),
+		Retries:   atomic.LoadInt64(&m.retries),
+		Failures:  atomic.LoadInt64(&m.failures),
+		Rejected:  atomic.LoadInt64(&m.rejected),
+	}
+	if s.Lookups > 0 {
+		s.CacheHitRate = float64(s.CacheHits) / float64(s.Lookups)
+	}
+	return s
+}
+
+// Breaker returns the state of the circuit breaker, closed when the locator has none.
+func (m *Metrics) Breaker() string {
+	if state, ok := m.breaker.Load().(string); ok {
+		return state
+	}
+	return BreakerClosed
+}
+
+func (m *Metrics) setBreaker(state string) {
+	m.breaker.Store(state)
+}
+
+func (m *Metrics) inc(counter *int64) {
+	atomic.AddInt64(counter, 1)
+}
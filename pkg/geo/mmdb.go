@@ -0,0 +1,48 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+)
+
+// MMDB locates addresses with a MaxMind-format database, such as GeoLite2 Country.
+type MMDB struct {
+	reader *maxminddb.Reader
+}
+
+type mmdbRecord struct {
+	Country struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+}
+
+func OpenMMDB(path string) (*MMDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("geo: open mmdb: %w", err)
+	}
+	return &MMDB{reader: reader}, nil
+}
+
+func (m *MMDB) Locate(_ context.Context, ip net.IP) (Location, error) {
+	if !isPublic(ip) {
+		return Location{}, ErrNotFound
+	}
+
+	record := mmdbRecord{}
+	if err := m.reader.Lookup(ip, &record); err != nil {
+		return Location{}, fmt.Errorf("geo: mmdb lookup: %w", err)
+	}
+	if record.Country.IsoCode == "" {
+		return Location{}, ErrNotFound
+	}
+
+	return Location{CountryCode: record.Country.IsoCode, CountryName: record.Country.Names["en"]}, nil
+}
+
+func (m *MMDB) Close() error {
+	return m.reader.Close()
+}
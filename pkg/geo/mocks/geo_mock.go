@@ -0,0 +1,52 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: geo.go
+
+// Package mock_geo is a generated GoMock package.
+package mock_geo
+
+import (
+	context "context"
+	net "net"
+	reflect "reflect"
+
+	geo "github.com/dkischenko/xm_app/pkg/geo"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockGeoLocator is a mock of GeoLocator interface.
+type MockGeoLocator struct {
+	ctrl     *gomock.Controller
+	recorder *MockGeoLocatorMockRecorder
+}
+
+// MockGeoLocatorMockRecorder is the mock recorder for MockGeoLocator.
+type MockGeoLocatorMockRecorder struct {
+	mock *MockGeoLocator
+}
+
+// NewMockGeoLocator creates a new mock instance.
+func NewMockGeoLocator(ctrl *gomock.Controller) *MockGeoLocator {
+	mock := &MockGeoLocator{ctrl: ctrl}
+	mock.recorder = &MockGeoLocatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockGeoLocator) EXPECT() *MockGeoLocatorMockRecorder {
+	return m.recorder
+}
+
+// Locate mocks base method.
+func (m *MockGeoLocator) Locate(ctx context.Context, ip net.IP) (geo.Location, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Locate", ctx, ip)
+	ret0, _ := ret[0].(geo.Location)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Locate indicates an expected call of Locate.
+func (mr *MockGeoLocatorMockRecorder) Locate(ctx, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Locate", reflect.TypeOf((*MockGeoLocator)(nil).Locate), ctx, ip)
+}
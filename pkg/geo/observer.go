@@ -0,0 +1,58 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// Outcomes of lookups told to an Observer.
+const (
+	OutcomeFound       = "found"
+	OutcomeNotFound    = "not_found"
+	OutcomeUnavailable = "unavailable"
+	OutcomeError       = "error"
+)
+
+// Observer is told the outcome and duration of every lookup.
+type Observer func(outcome string, elapsed time.Duration)
+
+// Observed tells the observer about every lookup of the locator.
+type Observed struct {
+	next    GeoLocator
+	observe Observer
+}
+
+func NewObserved(next GeoLocator, observe Observer) *Observed {
+	return &Observed{next: next, observe: observe}
+}
+
+func (o *Observed) Locate(ctx context.Context, ip net.IP) (Location, error) {
+	start := time.Now()
+	loc, err := o.next.Locate(ctx, ip)
+	o.observe(outcome(err), time.Since(start))
+	return loc, err
+}
+
+// Close closes the locator if it holds resources.
+func (o *Observed) Close() error {
+	if closer, ok := o.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeFound
+	case errors.Is(err, ErrNotFound):
+		return OutcomeNotFound
+	case errors.Is(err, ErrUnavailable):
+		return OutcomeUnavailable
+	default:
+		return OutcomeError
+	}
+}
@@ -0,0 +1,227 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stubLocator struct {
+	calls int64
+	delay time.Duration
+	err   func(call int64) error
+}
+
+func (s *stubLocator) Locate(ctx context.Context, ip net.IP) (Location, error) {
+	call := atomic.AddInt64(&s.calls, 1)
+	time.Sleep(s.delay)
+	if s.err != nil {
+		if err := s.err(call); err != nil {
+			return Location{}, err
+		}
+	}
+	return Location{CountryCode: "CY", CountryName: "Cyprus"}, nil
+}
+
+func unavailable(int64) error {
+	return fmt.Errorf("%w: connection refused", ErrUnavailable)
+}
+
+func TestCache(t *testing.T) {
+	ip := net.ParseIP("31.153.0.1")
+
+	t.Run("Hit until expired", func(t *testing.T) {
+		now := time.Unix(1650995663, 0)
+		stub := &stubLocator{}
+		metrics := &Metrics{}
+		cache := NewCache(stub, 10, time.Minute, time.Second, metrics)
+		cache.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			loc, err := cache.Locate(context.Background(), ip)
+			assert.NoError(t, err)
+			assert.Equal(t, "CY", loc.CountryCode)
+		}
+		now = now.Add(2 * time.Minute)
+		_, _ = cache.Locate(context.Background(), ip)
+
+		assert.Equal(t, int64(2), stub.calls)
+		assert.Equal(t, MetricsSnapshot{Lookups: 4, CacheHits: 2, CacheHitRate: 0.5}, metrics.Snapshot())
+	})
+
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		stub := &stubLocator{}
+		cache := NewCache(stub, 2, time.Minute, time.Second, &Metrics{})
+		for _, addr := range []string{"31.153.0.1", "31.153.0.2", "31.153.0.1", "31.153.0.3", "31.153.0.1"} {
+			_, _ = cache.Locate(context.Background(), net.ParseIP(addr))
+		}
+		assert.Equal(t, int64(3), stub.calls)
+		_, _ = cache.Locate(context.Background(), net.ParseIP("31.153.0.2"))
+		assert.Equal(t, int64(4), stub.calls)
+	})
+
+	t.Run("Failures are not cached", func(t *testing.T) {
+		stub := &stubLocator{err: unavailable}
+		cache := NewCache(stub, 10, time.Minute, time.Second, &Metrics{})
+		_, err := cache.Locate(context.Background(), ip)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		_, _ = cache.Locate(context.Background(), ip)
+		assert.Equal(t, int64(2), stub.calls)
+	})
+
+	t.Run("Concurrent lookups share a call", func(t *testing.T) {
+		stub := &stubLocator{delay: 50 * time.Millisecond}
+		cache := NewCache(stub, 10, time.Minute, time.Second, &Metrics{})
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = cache.Locate(context.Background(), ip)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int64(1), stub.calls)
+	})
+
+	t.Run("Caller hanging up does not fail the others", func(t *testing.T) {
+		stub := &stubLocator{delay: 50 * time.Millisecond}
+		cache := NewCache(stub, 10, time.Minute, time.Second, &Metrics{})
+		ctx, cancel := context.WithCancel(context.Background())
+		first := make(chan error)
+		go func() {
+			_, err := cache.Locate(ctx, ip)
+			first <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		assert.ErrorIs(t, <-first, context.Canceled)
+
+		loc, err := cache.Locate(context.Background(), ip)
+		assert.NoError(t, err)
+		assert.Equal(t, "CY", loc.CountryCode)
+		assert.Equal(t, int64(1), stub.calls)
+	})
+}
+
+func TestBreaker(t *testing.T) {
+	ip := net.ParseIP("31.153.0.1")
+	now := time.Unix(1650995663, 0)
+	failing := true
+	stub := &stubLocator{err: func(int64) error {
+		if failing {
+			return unavailable(0)
+		}
+		return nil
+	}}
+	metrics := &Metrics{}
+	breaker := NewBreaker(stub, 2, time.Minute, metrics)
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		_, err := breaker.Locate(context.Background(), ip)
+		assert.ErrorIs(t, err, ErrUnavailable)
+	}
+	assert.Equal(t, int64(2), stub.calls, "open breaker must not call the provider")
+	assert.Equal(t, int64(2), metrics.Snapshot().Rejected)
+	assert.Equal(t, BreakerOpen, metrics.Breaker())
+
+	now = now.Add(2 * time.Minute)
+	_, err := breaker.Locate(context.Background(), ip)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, int64(3), stub.calls, "failed probe must reopen the breaker")
+	_, _ = breaker.Locate(context.Background(), ip)
+	assert.Equal(t, int64(3), stub.calls)
+	assert.Equal(t, BreakerOpen, metrics.Breaker())
+
+	now = now.Add(2 * time.Minute)
+	failing = false
+	for i := 0; i < 2; i++ {
+		_, err = breaker.Locate(context.Background(), ip)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(5), stub.calls)
+	assert.Equal(t, BreakerClosed, metrics.Breaker())
+
+	failing = true
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = breaker.Locate(ctx, ip)
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+	assert.Equal(t, BreakerClosed, metrics.Breaker(), "callers hanging up must not open the breaker")
+}
+
+func TestRetry(t *testing.T) {
+	ip := net.ParseIP("31.153.0.1")
+
+	t.Run("Succeeds after transient failure", func(t *testing.T) {
+		stub := &stubLocator{err: func(call int64) error {
+			if call == 1 {
+				return unavailable(call)
+			}
+			return nil
+		}}
+		metrics := &Metrics{}
+		loc, err := NewRetry(stub, 2, time.Second, metrics).Locate(context.Background(), ip)
+		assert.NoError(t, err)
+		assert.Equal(t, "CY", loc.CountryCode)
+		assert.Equal(t, int64(1), metrics.Snapshot().Retries)
+	})
+
+	t.Run("Gives up after retries", func(t *testing.T) {
+		stub := &stubLocator{err: unavailable}
+		_, err := NewRetry(stub, 2, time.Second, &Metrics{}).Locate(context.Background(), ip)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, int64(3), stub.calls)
+	})
+
+	t.Run("Does not retry unknown address", func(t *testing.T) {
+		stub := &stubLocator{err: func(int64) error { return ErrNotFound }}
+		_, err := NewRetry(stub, 2, time.Second, &Metrics{}).Locate(context.Background(), ip)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, int64(1), stub.calls)
+	})
+
+	t.Run("Attempt timing out is a failure", func(t *testing.T) {
+		stub := &stubLocator{err: func(int64) error { return context.DeadlineExceeded }}
+		_, err := NewRetry(stub, 1, time.Second, &Metrics{}).Locate(context.Background(), ip)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, int64(2), stub.calls)
+	})
+
+	t.Run("Caller hanging up is not retried", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stub := &stubLocator{err: func(int64) error {
+			cancel()
+			return context.Canceled
+		}}
+		_, err := NewRetry(stub, 2, time.Second, &Metrics{}).Locate(ctx, ip)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, int64(1), stub.calls)
+	})
+}
+
+func TestObserved(t *testing.T) {
+	ip := net.ParseIP("31.153.0.1")
+	var outcomes []string
+	observe := func(outcome string, elapsed time.Duration) {
+		outcomes = append(outcomes, outcome)
+	}
+	errs := []error{nil, ErrNotFound, unavailable(0), fmt.Errorf("geo: bad response")}
+	stub := &stubLocator{err: func(call int64) error { return errs[call-1] }}
+	observed := NewObserved(stub, observe)
+
+	for range errs {
+		_, _ = observed.Locate(context.Background(), ip)
+	}
+	assert.Equal(t, []string{OutcomeFound, OutcomeNotFound, OutcomeUnavailable, OutcomeError}, outcomes)
+	assert.NoError(t, observed.Close())
+}
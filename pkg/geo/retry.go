@@ -0,0 +1,65 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const retryBackoff = 100 * time.Millisecond
+
+// Retry repeats lookups failed with ErrUnavailable, with a growing pause in between.
+// Every attempt is bounded by its own timeout, an attempt running out of it fails with
+// ErrUnavailable, while the caller's context ending stops the lookup with its error.
+type Retry struct {
+	next    GeoLocator
+	retries int
+	timeout time.Duration
+	metrics *Metrics
+}
+
+func NewRetry(next GeoLocator, retries int, timeout time.Duration, metrics *Metrics) *Retry {
+	return &Retry{
+		next:    next,
+		retries: retries,
+		timeout: timeout,
+		metrics: metrics,
+	}
+}
+
+func (r *Retry) Locate(ctx context.Context, ip net.IP) (loc Location, err error) {
+	for attempt := 0; ; attempt++ {
+		r.metrics.inc(&r.metrics.requests)
+		loc, err = r.attempt(ctx, ip)
+		if ctx.Err() != nil {
+			return Location{}, ctx.Err()
+		}
+		if !errors.Is(err, ErrUnavailable) || attempt == r.retries {
+			return loc, err
+		}
+
+		r.metrics.inc(&r.metrics.retries)
+		select {
+		case <-ctx.Done():
+			return Location{}, ctx.Err()
+		case <-time.After(retryBackoff << attempt):
+		}
+	}
+}
+
+func (r *Retry) attempt(ctx context.Context, ip net.IP) (Location, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	loc, err := r.next.Locate(attemptCtx, ip)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return Location{}, fmt.Errorf("%w: no answer within %s", ErrUnavailable, r.timeout)
+	}
+	return loc, err
+}
+
+// budget is the longest a lookup can take with every retry and pause.
+func (r *Retry) budget() time.Duration {
+	return time.Duration(r.retries+1)*r.timeout + retryBackoff*(1<<r.retries-1)
+}
@@ -0,0 +1,228 @@
+// Package health serves the liveness and readiness probes of the process.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+// Statuses of checks and of the process.
+const (
+	StatusOk = "ok"
+	// StatusDegraded is a failing check that is not critical, the process stays ready.
+	StatusDegraded = "degraded"
+	StatusFailing  = "failing"
+	// StatusShuttingDown is the process draining before it stops.
+	StatusShuttingDown = "shutting_down"
+)
+
+const (
+	defaultTimeout = 2 * time.Second
+	// defaultCacheTTL is how long the results of the checks are reused, so that frequent
+	// probes don't load the dependencies.
+	defaultCacheTTL = time.Second
+)
+
+// Check probes a dependency. A failing critical check makes the process not ready,
+// other checks only degrade it.
+type Check struct {
+	Name     string
+	Critical bool
+	// Timeout bounds the probe, defaultTimeout when zero.
+	Timeout time.Duration
+	Probe   func(ctx context.Context) error
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// LastError is the latest failure of the check, kept after it recovers.
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt int64  `json:"last_error_at,omitempty"`
+}
+
+// Report is the state of the process, Checks are only filled in verbose mode.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Checker runs the checks for readiness probes, at most once per cache TTL. It is safe
+// for concurrent use.
+type Checker struct {
+	checks   []Check
+	now      func() time.Time
+	ttl      time.Duration
+	draining int32
+
+	// runMu makes concurrent probes wait for a single run of the checks.
+	runMu    sync.Mutex
+	cached   []Result
+	cachedAt time.Time
+
+	mu       sync.Mutex
+	failures map[string]failure
+}
+
+type failure struct {
+	err string
+	at  time.Time
+}
+
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{
+		checks:   checks,
+		now:      time.Now,
+		ttl:      defaultCacheTTL,
+		failures: make(map[string]failure),
+	}
+}
+
+// Drain makes the process not ready for good, so that load balancers stop sending
+// it requests before it shuts down.
+func (c *Checker) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+// Check reports the readiness of the process with the results of the checks, reusing
+// those of the previous call while they are fresher than the cache TTL.
+func (c *Checker) Check() Report {
+	results := c.results()
+	report := Report{Status: StatusOk, Checks: results}
+	for _, result := range results {
+		if result.Status == StatusFailing {
+			report.Status = StatusFailing
+			break
+		}
+		if result.Status == StatusDegraded {
+			report.Status = StatusDegraded
+		}
+	}
+	if atomic.LoadInt32(&c.draining) == 1 {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// results runs the checks concurrently unless the cached results are fresh. The results are
+// shared by every probe, so the checks don't run with the context of the probe that happened
+// to come first: its client hanging up would fail them for all the others.
+func (c *Checker) results() []Result {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	if c.cached != nil && c.now().Sub(c.cachedAt) < c.ttl {
+		return append([]Result(nil), c.cached...)
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i := range c.checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.run(c.checks[i])
+		}(i)
+	}
+	wg.Wait()
+	c.cached, c.cachedAt = results, c.now()
+	return append([]Result(nil), results...)
+}
+
+func (c *Checker) run(check Check) Result {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := c.now()
+	err := check.Probe(ctx)
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusOk,
+		Critical:  check.Critical,
+		LatencyMs: float64(c.now().Sub(start).Microseconds()) / 1000,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = StatusDegraded
+		if check.Critical {
+			result.Status = StatusFailing
+		}
+		c.failures[check.Name] = failure{err: result.Error, at: start}
+	}
+	if last, ok := c.failures[check.Name]; ok {
+		result.LastError = last.err
+		result.LastErrorAt = last.at.Unix()
+	}
+	return result
+}
+
+// Register serves the liveness probe at LivePath and the readiness probe at ReadyPath.
+func (c *Checker) Register(router *mux.Router) {
+	router.HandleFunc(LivePath, c.Live).Methods(http.MethodGet)
+	router.HandleFunc(ReadyPath, c.Ready).Methods(http.MethodGet)
+}
+
+// RegisterVerbose serves the probes like Register, with the readiness probe listing the
+// checks on request. Their errors tell about the dependencies, such as the address of the
+// database, so it is meant for a listener only operators reach.
+func (c *Checker) RegisterVerbose(router *mux.Router) {
+	router.HandleFunc(LivePath, c.Live).Methods(http.MethodGet)
+	router.HandleFunc(ReadyPath, c.ReadyVerbose).Methods(http.MethodGet)
+}
+
+// Live answers 200 as long as the process serves requests, it checks nothing else.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOk})
+}
+
+// Ready answers 200 when every critical check passes and 503 when one fails or the
+// process is shutting down.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	c.ready(w, r, false)
+}
+
+// ReadyVerbose answers like Ready, the verbose query parameter adds every check to the
+// body with its latency and latest error.
+func (c *Checker) ReadyVerbose(w http.ResponseWriter, r *http.Request) {
+	_, verbose := r.URL.Query()["verbose"]
+	c.ready(w, r, verbose)
+}
+
+func (c *Checker) ready(w http.ResponseWriter, r *http.Request, verbose bool) {
+	report := c.Check()
+	status := http.StatusOK
+	if report.Status == StatusFailing || report.Status == StatusShuttingDown {
+		status = http.StatusServiceUnavailable
+	}
+	if !verbose {
+		report.Checks = nil
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,174 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(err *error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return *err
+	}
+}
+
+func TestChecker_Ready(t *testing.T) {
+	var dbErr, geoErr error
+	testCases := []struct {
+		name    string
+		dbErr   error
+		geoErr  error
+		drain   bool
+		verbose bool
+		public  bool
+		code    int
+		status  string
+	}{
+		{name: "[Ok] Every check passes", code: http.StatusOK, status: StatusOk},
+		{name: "[Ok] Non-critical check fails", geoErr: errors.New("circuit breaker is open"),
+			code: http.StatusOK, status: StatusDegraded},
+		{name: "[Err] Critical check fails", dbErr: errors.New("connection refused"),
+			geoErr: errors.New("circuit breaker is open"), code: http.StatusServiceUnavailable, status: StatusFailing},
+		{name: "[Err] Shutting down", drain: true, code: http.StatusServiceUnavailable, status: StatusShuttingDown},
+		{name: "[Ok] Verbose", verbose: true, code: http.StatusOK, status: StatusOk},
+		{name: "[Ok] Verbose ignored on the public router", dbErr: errors.New("dial tcp 10.0.0.5:5432: connection refused"),
+			verbose: true, public: true, code: http.StatusServiceUnavailable, status: StatusFailing},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			dbErr, geoErr = tcase.dbErr, tcase.geoErr
+			checker := NewChecker(
+				Check{Name: "postgres", Critical: true, Probe: probe(&dbErr)},
+				Check{Name: "geo", Probe: probe(&geoErr)},
+			)
+			if tcase.drain {
+				checker.Drain()
+			}
+			router := mux.NewRouter()
+			if tcase.public {
+				checker.Register(router)
+			} else {
+				checker.RegisterVerbose(router)
+			}
+			path := ReadyPath
+			if tcase.verbose {
+				path += "?verbose"
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, tcase.code, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			report := Report{}
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tcase.status, report.Status)
+			if !tcase.verbose || tcase.public {
+				assert.NotContains(t, w.Body.String(), "10.0.0.5")
+				assert.Empty(t, report.Checks)
+				return
+			}
+			assert.Len(t, report.Checks, 2)
+			assert.Equal(t, "postgres", report.Checks[0].Name)
+			assert.True(t, report.Checks[0].Critical)
+		})
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	t.Run("[Ok] Keeps the last error after recovery", func(t *testing.T) {
+		now := time.Unix(1650995663, 0)
+		var err error = errors.New("connection refused")
+		checker := NewChecker(Check{Name: "postgres", Critical: true, Probe: func(ctx context.Context) error {
+			now = now.Add(3 * time.Millisecond)
+			return err
+		}})
+		checker.now = func() time.Time { return now }
+		checker.ttl = 0
+
+		report := checker.Check()
+		assert.Equal(t, Result{Name: "postgres", Status: StatusFailing, Critical: true, LatencyMs: 3,
+			Error: "connection refused", LastError: "connection refused", LastErrorAt: 1650995663}, report.Checks[0])
+
+		err = nil
+		report = checker.Check()
+		assert.Equal(t, StatusOk, report.Status)
+		assert.Equal(t, Result{Name: "postgres", Status: StatusOk, Critical: true, LatencyMs: 3,
+			LastError: "connection refused", LastErrorAt: 1650995663}, report.Checks[0])
+	})
+
+	t.Run("[Err] Probe exceeds its timeout", func(t *testing.T) {
+		checker := NewChecker(Check{Name: "postgres", Critical: true, Timeout: time.Millisecond,
+			Probe: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}})
+
+		report := checker.Check()
+		assert.Equal(t, StatusFailing, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	})
+}
+
+func TestChecker_CheckCaches(t *testing.T) {
+	now := time.Unix(1650995663, 0)
+	probes := 0
+	checker := NewChecker(Check{Name: "postgres", Critical: true, Probe: func(ctx context.Context) error {
+		probes++
+		return nil
+	}})
+	checker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, StatusOk, checker.Check().Status)
+	}
+	assert.Equal(t, 1, probes)
+
+	now = now.Add(defaultCacheTTL)
+	checker.Drain()
+	assert.Equal(t, StatusShuttingDown, checker.Check().Status)
+	assert.Equal(t, 2, probes)
+}
+
+func TestChecker_ReadyDetachedFromProbe(t *testing.T) {
+	checker := NewChecker(Check{Name: "postgres", Critical: true, Probe: func(ctx context.Context) error {
+		return ctx.Err()
+	}})
+	router := mux.NewRouter()
+	checker.Register(router)
+
+	// The first probe hangs up, the cached results must not fail the next ones.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil).WithContext(ctx))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestChecker_Live(t *testing.T) {
+	t.Run("[Ok] Alive while shutting down", func(t *testing.T) {
+		checker := NewChecker(Check{Name: "postgres", Critical: true, Probe: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}})
+		checker.Drain()
+		router := mux.NewRouter()
+		checker.Register(router)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, LivePath, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+	})
+}
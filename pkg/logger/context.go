@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/pkg/clientip"
+	"github.com/dkischenko/xm_app/pkg/requestid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+)
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// Ctx returns the entry of the logger ctx carries, the one of l if it carries none.
+func (l *Logger) Ctx(ctx context.Context) *logrus.Entry {
+	if cl, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return cl.Entry
+	}
+	return l.Entry
+}
+
+// ContextWithFields returns a copy of ctx carrying a logger that adds the fields to the lines
+// of the logger of ctx, or of l if ctx carries none.
+func (l *Logger) ContextWithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return NewContext(ctx, &Logger{Entry: l.Ctx(ctx).WithFields(fields)})
+}
+
+// Middleware stores a logger in the request context that adds the request id, route, method
+// and client address to its lines. It runs after the requestid and clientip middlewares.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := logrus.Fields{"request_id": requestid.FromContext(r.Context()), "method": r.Method}
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				fields["route"] = template
+			}
+		}
+		if ip := clientip.FromContext(r.Context()); ip != nil {
+			fields["ip"] = ip.String()
+		} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			fields["ip"] = host
+		}
+		next.ServeHTTP(w, r.WithContext(l.ContextWithFields(r.Context(), fields)))
+	})
+}
@@ -0,0 +1,74 @@
+package logger_test
+
+import (
+	"context"
+	"github.com/dkischenko/xm_app/pkg/clientip"
+	"github.com/dkischenko/xm_app/pkg/logger"
+	"github.com/dkischenko/xm_app/pkg/requestid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogger_Ctx(t *testing.T) {
+	t.Run("[Ok] Fall back without a request logger", func(t *testing.T) {
+		nullLogger, hook := test.NewNullLogger()
+		l := &logger.Logger{Entry: logrus.NewEntry(nullLogger)}
+
+		l.Ctx(context.Background()).Info("started")
+		assert.Empty(t, hook.LastEntry().Data)
+	})
+
+	t.Run("[Ok] Fields add up", func(t *testing.T) {
+		nullLogger, hook := test.NewNullLogger()
+		l := &logger.Logger{Entry: logrus.NewEntry(nullLogger)}
+
+		ctx := l.ContextWithFields(context.Background(), logrus.Fields{"request_id": "abc"})
+		ctx = l.ContextWithFields(ctx, logrus.Fields{"principal": "user:7"})
+		l.Ctx(ctx).Info("company created")
+		assert.Equal(t, logrus.Fields{"request_id": "abc", "principal": "user:7"}, hook.LastEntry().Data)
+	})
+}
+
+func TestLogger_Middleware(t *testing.T) {
+	testCases := []struct {
+		name   string
+		ip     net.IP
+		fields logrus.Fields
+	}{
+		{name: "[Ok] Client address", ip: net.ParseIP("31.153.0.1"), fields: logrus.Fields{"request_id": "abc",
+			"route": "/v1/companies/{id:[0-9]+}", "method": http.MethodGet, "ip": "31.153.0.1"}},
+		{name: "[Ok] Peer address", fields: logrus.Fields{"request_id": "abc",
+			"route": "/v1/companies/{id:[0-9]+}", "method": http.MethodGet, "ip": "192.168.0.1"}},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			nullLogger, hook := test.NewNullLogger()
+			l := &logger.Logger{Entry: logrus.NewEntry(nullLogger)}
+			router := mux.NewRouter()
+			router.Use(requestid.Middleware, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tcase.ip != nil {
+						r = r.WithContext(clientip.WithIP(r.Context(), tcase.ip))
+					}
+					next.ServeHTTP(w, r)
+				})
+			}, l.Middleware)
+			router.HandleFunc("/v1/companies/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+				l.Ctx(r.Context()).Info("get company")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/companies/7", nil)
+			req.RemoteAddr = "192.168.0.1:5000"
+			req.Header.Set(requestid.Header, "abc")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tcase.fields, hook.LastEntry().Data)
+		})
+	}
+}
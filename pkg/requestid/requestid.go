@@ -0,0 +1,62 @@
+// Package requestid gives every request an id, so that its responses and log lines can be told apart.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header carries the id of a request, from the client or a proxy and back in the response.
+const Header = "X-Request-ID"
+
+// maxLength bounds ids taken from the request.
+const maxLength = 128
+
+type idKey struct{}
+
+// WithId returns a copy of ctx carrying the request id.
+func WithId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the request id stored in ctx, an empty string if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// Middleware stores the id of the request in its context and echoes it in the response.
+// The id of the request header is kept if it is printable ASCII, otherwise a new one is generated.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithId(r.Context(), id)))
+	})
+}
+
+// New returns a random request id.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package requestid
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "Id of the client is kept", header: "3f1c0a7e-9b2d", keep: true},
+		{name: "Missing id is generated"},
+		{name: "Id with spaces is replaced", header: "evil id"},
+		{name: "Too long id is replaced", header: strings.Repeat("a", maxLength+1)},
+	}
+
+	for _, tcase := range testCases {
+		t.Run(tcase.name, func(t *testing.T) {
+			var id string
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id = FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tcase.header != "" {
+				req.Header.Set(Header, tcase.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, w.Header().Get(Header))
+			assert.Equal(t, tcase.keep, id == tcase.header)
+		})
+	}
+}